@@ -0,0 +1,39 @@
+package certs
+
+import (
+	"context"
+	"database/sql"
+	"url-shortener-api/db"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// PostgresCache stores ACME account keys and certificates in the acme_cache table so that
+// every replica shares them instead of each one requesting its own certificates
+type PostgresCache struct{}
+
+// Get returns the cached data for key, or autocert.ErrCacheMiss
+func (PostgresCache) Get(ctx context.Context, key string) ([]byte, error) {
+	var data []byte
+	err := db.DB.GetContext(ctx, &data, "SELECT data FROM acme_cache WHERE key = $1", key)
+	if err == sql.ErrNoRows {
+		return nil, autocert.ErrCacheMiss
+	}
+	return data, err
+}
+
+// Put stores data under key, replacing any existing entry
+func (PostgresCache) Put(ctx context.Context, key string, data []byte) error {
+	_, err := db.DB.ExecContext(ctx, `
+		INSERT INTO acme_cache (key, data, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (key) DO UPDATE SET data = EXCLUDED.data, updated_at = EXCLUDED.updated_at
+	`, key, data)
+	return err
+}
+
+// Delete removes key from the cache
+func (PostgresCache) Delete(ctx context.Context, key string) error {
+	_, err := db.DB.ExecContext(ctx, "DELETE FROM acme_cache WHERE key = $1", key)
+	return err
+}
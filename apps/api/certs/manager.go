@@ -0,0 +1,73 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"url-shortener-api/db"
+	"url-shortener-api/utils"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// ErrHostNotAllowed is returned for hosts that are neither the short domain nor a verified custom domain
+var ErrHostNotAllowed = errors.New("certs: host is not a verified domain")
+
+// HostPolicy only allows certificates for the short domain and verified custom domains, so
+// arbitrary SNI names can't be used to exhaust the CA's rate limits
+func HostPolicy(ctx context.Context, host string) error {
+	host = strings.ToLower(host)
+	if shortDomain := utils.ShortDomain(); shortDomain != "" && host == shortDomain {
+		return nil
+	}
+
+	var verified bool
+	err := db.DB.GetContext(ctx, &verified,
+		"SELECT EXISTS (SELECT 1 FROM domains WHERE hostname = $1 AND verified_at IS NOT NULL)", host)
+	if err != nil {
+		return err
+	}
+	if !verified {
+		return ErrHostNotAllowed
+	}
+	return nil
+}
+
+// NewManager builds an ACME manager that issues certificates on demand using the configured
+// directory. ACME_CA_CERT trusts an extra root, such as the one of a local Pebble test server.
+func NewManager() (*autocert.Manager, error) {
+	client := &acme.Client{DirectoryURL: utils.AppConfig.ACMEDirectoryURL}
+
+	if utils.AppConfig.ACMECACert != "" {
+		pem, err := os.ReadFile(utils.AppConfig.ACMECACert)
+		if err != nil {
+			return nil, fmt.Errorf("reading ACME CA certificate: %w", err)
+		}
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", utils.AppConfig.ACMECACert)
+		}
+
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots}
+		client.HTTPClient = &http.Client{Transport: transport, Timeout: 30 * time.Second}
+	}
+
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      PostgresCache{},
+		HostPolicy: HostPolicy,
+		Client:     client,
+		Email:      utils.AppConfig.ACMEEmail,
+	}, nil
+}
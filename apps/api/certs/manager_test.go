@@ -0,0 +1,84 @@
+package certs
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"url-shortener-api/utils"
+)
+
+// useConfig swaps in a config for the duration of a test
+func useConfig(t *testing.T, cfg utils.Config) {
+	t.Helper()
+	previous := utils.AppConfig
+	utils.AppConfig = cfg
+	t.Cleanup(func() { utils.AppConfig = previous })
+}
+
+func TestNewManagerTrustsACMECACert(t *testing.T) {
+	// The test server stands in for a local Pebble directory with its own root certificate
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "pebble.minica.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	useConfig(t, utils.Config{ACMEDirectoryURL: server.URL, ACMECACert: caFile})
+	manager, err := NewManager()
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+	if manager.Client.DirectoryURL != server.URL {
+		t.Errorf("DirectoryURL = %q, want %q", manager.Client.DirectoryURL, server.URL)
+	}
+
+	resp, err := manager.Client.HTTPClient.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request to directory with ACME_CA_CERT trusted failed: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestNewManagerWithoutCACertUsesSystemRoots(t *testing.T) {
+	useConfig(t, utils.Config{ACMEDirectoryURL: "https://acme.example/directory"})
+	manager, err := NewManager()
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+	if manager.Client.HTTPClient != nil {
+		t.Error("HTTPClient is set without ACME_CA_CERT, want the default client")
+	}
+}
+
+func TestNewManagerRejectsInvalidCACert(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(caFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	useConfig(t, utils.Config{ACMECACert: caFile})
+	if _, err := NewManager(); err == nil {
+		t.Error("NewManager() error = nil, want an error for a file without certificates")
+	}
+
+	useConfig(t, utils.Config{ACMECACert: filepath.Join(t.TempDir(), "missing.pem")})
+	if _, err := NewManager(); err == nil {
+		t.Error("NewManager() error = nil, want an error for a missing file")
+	}
+}
+
+func TestHostPolicyAllowsShortDomain(t *testing.T) {
+	useConfig(t, utils.Config{ShortBaseURL: "https://Sho.rt"})
+	if err := HostPolicy(context.Background(), "SHO.RT"); err != nil {
+		t.Errorf("HostPolicy(short domain) = %v, want nil", err)
+	}
+}
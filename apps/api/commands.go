@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"url-shortener-api/db"
+)
+
+// runCommand runs an operator command given on the command line instead of starting the
+// server, and reports whether there was one. Admins are granted this way rather than by
+// email address, since signing up doesn't prove the address belongs to the user.
+func runCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	switch args[0] {
+	case "promote-admin", "demote-admin":
+		if len(args) != 2 {
+			log.Fatalf("usage: %s %s <email>", os.Args[0], args[0])
+		}
+		db.ConnectSQLX()
+		if err := setAdmin(args[1], args[0] == "promote-admin"); err != nil {
+			log.Fatalf("%s failed: %v", args[0], err)
+		}
+	default:
+		log.Fatalf("unknown command %q, expected promote-admin or demote-admin", args[0])
+	}
+	return true
+}
+
+// setAdmin grants or revokes admin rights of the user with the given email
+func setAdmin(email string, isAdmin bool) error {
+	result, err := db.DB.Exec("UPDATE users SET is_admin = $1 WHERE LOWER(email) = LOWER($2)", isAdmin, email)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("no user with email %s", email)
+	}
+	log.Printf("Set is_admin=%t for %s", isAdmin, email)
+	return nil
+}
@@ -0,0 +1,22 @@
+CREATE TABLE failed_attempts (
+    scope TEXT NOT NULL,
+    key TEXT NOT NULL,
+    failures INT NOT NULL DEFAULT 0,
+    last_failure TIMESTAMP NOT NULL DEFAULT NOW(),
+    locked_until TIMESTAMP,
+    PRIMARY KEY (scope, key)
+);
+
+CREATE TABLE audit_log (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    action TEXT NOT NULL,
+    actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    target TEXT,
+    ip TEXT,
+    user_agent TEXT,
+    success BOOLEAN NOT NULL DEFAULT TRUE,
+    details TEXT,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX idx_audit_log_action_created_at ON audit_log (action, created_at DESC);
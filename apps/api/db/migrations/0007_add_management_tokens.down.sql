@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_links_management_token_hash;
+ALTER TABLE links DROP COLUMN IF EXISTS management_token_hash;
@@ -0,0 +1,4 @@
+-- Anonymous links are managed with a per-link secret instead of being editable by anyone.
+-- Existing anonymous links have no secret and can only be managed by admins.
+ALTER TABLE links ADD COLUMN management_token_hash TEXT;
+CREATE UNIQUE INDEX idx_links_management_token_hash ON links (management_token_hash);
@@ -0,0 +1,3 @@
+ALTER TABLE links DROP COLUMN IF EXISTS screened_at;
+ALTER TABLE links DROP COLUMN IF EXISTS flagged_reason;
+DROP TABLE IF EXISTS blocklist_entries;
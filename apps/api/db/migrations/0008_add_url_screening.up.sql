@@ -0,0 +1,12 @@
+CREATE TABLE blocklist_entries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    kind TEXT NOT NULL CHECK (kind IN ('domain', 'regex')),
+    pattern TEXT NOT NULL,
+    reason TEXT,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE (kind, pattern)
+);
+
+ALTER TABLE links ADD COLUMN flagged_reason TEXT;
+ALTER TABLE links ADD COLUMN screened_at TIMESTAMP;
@@ -0,0 +1 @@
+ALTER TABLE links ADD COLUMN preview_mode BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_links_domain_slug;
+DROP INDEX IF EXISTS idx_links_default_domain_slug;
+DELETE FROM links WHERE domain_id IS NOT NULL;
+ALTER TABLE links DROP COLUMN IF EXISTS domain_id;
+ALTER TABLE links ADD CONSTRAINT links_slug_key UNIQUE (slug);
+DROP TABLE IF EXISTS domains;
@@ -0,0 +1,19 @@
+CREATE TABLE domains (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    hostname TEXT UNIQUE NOT NULL,
+    workspace_id UUID NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    verification_token TEXT NOT NULL,
+    verified_at TIMESTAMP,
+    default_redirect_url TEXT,
+    not_found_url TEXT,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX idx_domains_workspace_id ON domains (workspace_id);
+
+-- Slugs are unique per domain instead of globally. Links without a domain use the default short domain.
+ALTER TABLE links ADD COLUMN domain_id UUID REFERENCES domains(id) ON DELETE CASCADE;
+ALTER TABLE links DROP CONSTRAINT IF EXISTS links_slug_key;
+CREATE UNIQUE INDEX idx_links_default_domain_slug ON links (slug) WHERE domain_id IS NULL;
+CREATE UNIQUE INDEX idx_links_domain_slug ON links (domain_id, slug) WHERE domain_id IS NOT NULL;
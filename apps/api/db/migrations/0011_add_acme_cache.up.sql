@@ -0,0 +1,6 @@
+-- Shared storage for ACME account keys and issued certificates, so every replica serves the same certs
+CREATE TABLE acme_cache (
+    key TEXT PRIMARY KEY,
+    data BYTEA NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,17 @@
+-- Targeting rules send visitors to different destinations. Rules are evaluated in position order
+-- and the link's original URL is the fallback.
+CREATE TABLE link_rules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    link_id UUID NOT NULL REFERENCES links(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('country', 'device', 'language', 'time')),
+    match_values TEXT[] NOT NULL DEFAULT '{}',
+    start_time TEXT,
+    end_time TEXT,
+    timezone TEXT,
+    destination TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE (link_id, position)
+);
+
+ALTER TABLE click_events ADD COLUMN rule_id UUID REFERENCES link_rules(id) ON DELETE SET NULL;
@@ -0,0 +1,15 @@
+-- Weighted destinations for A/B tests. When a link has variants they replace the original URL.
+CREATE TABLE link_variants (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    link_id UUID NOT NULL REFERENCES links(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    name TEXT,
+    destination TEXT NOT NULL,
+    weight INT NOT NULL CHECK (weight > 0),
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX idx_link_variants_link_id ON link_variants (link_id);
+
+ALTER TABLE click_events ADD COLUMN variant_id UUID REFERENCES link_variants(id) ON DELETE SET NULL;
+CREATE INDEX idx_click_events_variant_id ON click_events (variant_id) WHERE variant_id IS NOT NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE links DROP COLUMN IF EXISTS one_time;
+ALTER TABLE links DROP COLUMN IF EXISTS max_clicks;
@@ -0,0 +1,3 @@
+-- Usage limits. one_time links are limited to a single click and never reveal their destination before it.
+ALTER TABLE links ADD COLUMN max_clicks INT CHECK (max_clicks > 0);
+ALTER TABLE links ADD COLUMN one_time BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,3 @@
+ALTER TABLE links DROP COLUMN IF EXISTS forward_path;
+ALTER TABLE links DROP COLUMN IF EXISTS query_precedence;
+ALTER TABLE links DROP COLUMN IF EXISTS forward_query;
@@ -0,0 +1,6 @@
+-- forward_query merges the incoming query string into the destination; query_precedence decides
+-- which side wins when both set the same parameter. forward_path makes a prefix link that
+-- appends extra path segments after the slug to the destination.
+ALTER TABLE links ADD COLUMN forward_query BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE links ADD COLUMN query_precedence TEXT NOT NULL DEFAULT 'destination' CHECK (query_precedence IN ('destination', 'incoming'));
+ALTER TABLE links ADD COLUMN forward_path BOOLEAN NOT NULL DEFAULT FALSE;
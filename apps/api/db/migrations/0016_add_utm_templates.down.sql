@@ -0,0 +1,5 @@
+ALTER TABLE click_events DROP COLUMN IF EXISTS utm_campaign;
+ALTER TABLE click_events DROP COLUMN IF EXISTS utm_medium;
+ALTER TABLE click_events DROP COLUMN IF EXISTS utm_source;
+ALTER TABLE links DROP COLUMN IF EXISTS utm_template_id;
+DROP TABLE IF EXISTS utm_templates;
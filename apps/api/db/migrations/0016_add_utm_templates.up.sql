@@ -0,0 +1,26 @@
+-- Reusable UTM parameter sets. Templates with a workspace are shared with its members,
+-- templates without one belong to the user who created them.
+CREATE TABLE utm_templates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    workspace_id UUID REFERENCES workspaces(id) ON DELETE CASCADE,
+    utm_source TEXT,
+    utm_medium TEXT,
+    utm_campaign TEXT,
+    utm_term TEXT,
+    utm_content TEXT,
+    created_at TIMESTAMP DEFAULT NOW(),
+    CHECK (user_id IS NOT NULL OR workspace_id IS NOT NULL)
+);
+
+CREATE INDEX idx_utm_templates_user_id ON utm_templates (user_id);
+CREATE INDEX idx_utm_templates_workspace_id ON utm_templates (workspace_id);
+
+-- Applied to the destination at redirect time; the stored URL is left untouched
+ALTER TABLE links ADD COLUMN utm_template_id UUID REFERENCES utm_templates(id) ON DELETE SET NULL;
+
+-- Campaign values of the final destination, for analytics
+ALTER TABLE click_events ADD COLUMN utm_source TEXT;
+ALTER TABLE click_events ADD COLUMN utm_medium TEXT;
+ALTER TABLE click_events ADD COLUMN utm_campaign TEXT;
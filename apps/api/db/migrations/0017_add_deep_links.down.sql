@@ -0,0 +1,4 @@
+ALTER TABLE links DROP COLUMN IF EXISTS android_store_url;
+ALTER TABLE links DROP COLUMN IF EXISTS android_app_url;
+ALTER TABLE links DROP COLUMN IF EXISTS ios_store_url;
+ALTER TABLE links DROP COLUMN IF EXISTS ios_app_url;
@@ -0,0 +1,6 @@
+-- Per-platform app targets. App URLs may be custom schemes (myapp://...) or universal/app links;
+-- store URLs are used when a custom scheme doesn't open because the app isn't installed.
+ALTER TABLE links ADD COLUMN ios_app_url TEXT;
+ALTER TABLE links ADD COLUMN ios_store_url TEXT;
+ALTER TABLE links ADD COLUMN android_app_url TEXT;
+ALTER TABLE links ADD COLUMN android_store_url TEXT;
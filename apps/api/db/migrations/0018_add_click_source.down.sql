@@ -0,0 +1 @@
+ALTER TABLE click_events DROP COLUMN IF EXISTS source;
@@ -0,0 +1,2 @@
+-- Where a click came from when the short URL carries a marker, e.g. 'qr' for ?src=qr
+ALTER TABLE click_events ADD COLUMN source TEXT;
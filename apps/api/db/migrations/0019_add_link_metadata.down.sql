@@ -0,0 +1,5 @@
+ALTER TABLE links DROP COLUMN IF EXISTS metadata_fetched_at;
+ALTER TABLE links DROP COLUMN IF EXISTS meta_site_name;
+ALTER TABLE links DROP COLUMN IF EXISTS meta_image;
+ALTER TABLE links DROP COLUMN IF EXISTS meta_description;
+ALTER TABLE links DROP COLUMN IF EXISTS meta_title;
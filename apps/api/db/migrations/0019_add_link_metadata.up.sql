@@ -0,0 +1,6 @@
+-- Page metadata scraped from the destination (<title>, description, Open Graph and Twitter tags)
+ALTER TABLE links ADD COLUMN meta_title TEXT;
+ALTER TABLE links ADD COLUMN meta_description TEXT;
+ALTER TABLE links ADD COLUMN meta_image TEXT;
+ALTER TABLE links ADD COLUMN meta_site_name TEXT;
+ALTER TABLE links ADD COLUMN metadata_fetched_at TIMESTAMP;
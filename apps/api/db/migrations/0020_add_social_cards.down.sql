@@ -0,0 +1,3 @@
+ALTER TABLE links DROP COLUMN IF EXISTS og_image;
+ALTER TABLE links DROP COLUMN IF EXISTS og_description;
+ALTER TABLE links DROP COLUMN IF EXISTS og_title;
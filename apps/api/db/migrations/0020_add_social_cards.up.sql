@@ -0,0 +1,4 @@
+-- Per-link Open Graph overrides served to link preview crawlers
+ALTER TABLE links ADD COLUMN og_title TEXT;
+ALTER TABLE links ADD COLUMN og_description TEXT;
+ALTER TABLE links ADD COLUMN og_image TEXT;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS favicon_jobs;
+DROP TABLE IF EXISTS favicons;
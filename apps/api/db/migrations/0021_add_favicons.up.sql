@@ -0,0 +1,33 @@
+-- Favicons downloaded from destination sites, one per host, served from /api/favicons/:domain
+CREATE TABLE favicons (
+    domain TEXT PRIMARY KEY,
+    content_type TEXT NOT NULL,
+    data BYTEA NOT NULL,
+    hash TEXT NOT NULL,
+    source_url TEXT NOT NULL,
+    fetched_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Pending favicon downloads; a host is queued at most once
+CREATE TABLE favicon_jobs (
+    domain TEXT PRIMARY KEY,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    run_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_favicon_jobs_run_at ON favicon_jobs(run_at);
+
+-- Stop hotlinking third-party favicon services and queue those hosts for download instead
+UPDATE links SET favicon_url = NULL
+WHERE favicon_url LIKE 'https://www.google.com/s2/favicons%'
+   OR favicon_url LIKE 'https://icons.duckduckgo.com/%'
+   OR favicon_url ~ '^https://[^/]+/favicon\.ico$';
+
+INSERT INTO favicon_jobs (domain)
+SELECT DISTINCT lower(substring(original from '^[a-zA-Z][a-zA-Z0-9+.-]*://(?:[^@/]*@)?([^/:?#]+)'))
+FROM links
+WHERE favicon_url IS NULL
+  AND substring(original from '^[a-zA-Z][a-zA-Z0-9+.-]*://(?:[^@/]*@)?([^/:?#]+)') IS NOT NULL
+ON CONFLICT (domain) DO NOTHING;
@@ -0,0 +1,5 @@
+ALTER TABLE links DROP COLUMN IF EXISTS health_checked_at;
+ALTER TABLE links DROP COLUMN IF EXISTS health_failures;
+ALTER TABLE links DROP COLUMN IF EXISTS health_status_code;
+ALTER TABLE links DROP COLUMN IF EXISTS health_status;
+DROP TABLE IF EXISTS link_checks;
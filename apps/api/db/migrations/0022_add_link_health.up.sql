@@ -0,0 +1,20 @@
+-- Results of periodic destination health checks
+CREATE TABLE link_checks (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    link_id UUID NOT NULL REFERENCES links(id) ON DELETE CASCADE,
+    checked_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    healthy BOOLEAN NOT NULL,
+    status_code INTEGER,
+    latency_ms INTEGER NOT NULL,
+    redirect_chain TEXT[] NOT NULL DEFAULT '{}',
+    error TEXT
+);
+
+CREATE INDEX idx_link_checks_link_id_checked_at ON link_checks(link_id, checked_at DESC);
+CREATE INDEX idx_link_checks_checked_at ON link_checks(checked_at);
+
+-- Latest health of each link's destination; health_failures counts consecutive failed checks
+ALTER TABLE links ADD COLUMN health_status TEXT;
+ALTER TABLE links ADD COLUMN health_status_code INTEGER;
+ALTER TABLE links ADD COLUMN health_failures INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE links ADD COLUMN health_checked_at TIMESTAMP;
@@ -0,0 +1,3 @@
+ALTER TABLE links DROP COLUMN IF EXISTS expired_event_at;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
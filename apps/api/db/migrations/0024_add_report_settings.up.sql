@@ -0,0 +1,15 @@
+-- Per-user opt-in to scheduled performance report emails
+CREATE TABLE report_settings (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    -- 'off', 'weekly' or 'monthly'
+    frequency TEXT NOT NULL DEFAULT 'off',
+    -- Limits the report to one workspace; NULL covers every workspace the user belongs to
+    workspace_id UUID REFERENCES workspaces(id) ON DELETE SET NULL,
+    -- End of the last period a report was sent for
+    last_period_end TIMESTAMP,
+    last_sent_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_report_settings_frequency ON report_settings(frequency) WHERE frequency <> 'off';
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_domains_hostname;
+DROP INDEX IF EXISTS idx_domains_workspace_hostname;
+DROP INDEX IF EXISTS idx_domains_verified_hostname;
+DELETE FROM domains WHERE verified_at IS NULL;
+ALTER TABLE domains ADD CONSTRAINT domains_hostname_key UNIQUE (hostname);
@@ -0,0 +1,6 @@
+-- Hostnames are only unique once verified, so an unverified claim can't lock out the real owner.
+-- A workspace still can't add the same hostname twice.
+ALTER TABLE domains DROP CONSTRAINT IF EXISTS domains_hostname_key;
+CREATE UNIQUE INDEX idx_domains_verified_hostname ON domains (hostname) WHERE verified_at IS NOT NULL;
+CREATE UNIQUE INDEX idx_domains_workspace_hostname ON domains (workspace_id, hostname);
+CREATE INDEX idx_domains_hostname ON domains (hostname);
@@ -0,0 +1,7 @@
+-- Redeemed proof-of-work challenges, shared by all replicas so each can only be used once
+CREATE TABLE used_challenges (
+    key_hash TEXT PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_used_challenges_expires_at ON used_challenges (expires_at);
@@ -0,0 +1,10 @@
+-- Pending destination metadata scrapes for new links, worked through like favicon_jobs
+CREATE TABLE metadata_jobs (
+    link_id UUID PRIMARY KEY REFERENCES links(id) ON DELETE CASCADE,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    run_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_metadata_jobs_run_at ON metadata_jobs(run_at);
@@ -0,0 +1,10 @@
+-- Each user has at most one personal workspace. Duplicates left by concurrent creation are
+-- kept, with their links and members, as ordinary workspaces.
+UPDATE workspaces w SET personal = FALSE, name = w.name || ' (duplicate)'
+WHERE w.personal AND EXISTS (
+    SELECT 1 FROM workspaces o
+    WHERE o.personal AND o.created_by = w.created_by
+      AND (COALESCE(o.created_at, 'epoch'), o.id) < (COALESCE(w.created_at, 'epoch'), w.id)
+);
+
+CREATE UNIQUE INDEX idx_workspaces_personal_created_by ON workspaces (created_by) WHERE personal;
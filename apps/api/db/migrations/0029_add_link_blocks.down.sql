@@ -0,0 +1,2 @@
+ALTER TABLE links DROP COLUMN IF EXISTS blocked_reason;
+ALTER TABLE links DROP COLUMN IF EXISTS blocked_at;
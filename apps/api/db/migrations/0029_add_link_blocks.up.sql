@@ -0,0 +1,17 @@
+-- Links taken down by an admin or by URL screening. Unlike disabled, which the owner controls,
+-- a block can only be lifted by an admin.
+ALTER TABLE links ADD COLUMN blocked_at TIMESTAMP;
+ALTER TABLE links ADD COLUMN blocked_reason TEXT;
+
+-- Carry over links whose latest admin or screening action disabled them and that are still disabled
+UPDATE links l
+SET blocked_at = COALESCE(a.created_at, NOW()), blocked_reason = a.details
+FROM (
+    SELECT DISTINCT ON (target) target, action, details, created_at
+    FROM audit_log
+    WHERE action IN ('admin.link.update', 'screening.disable') AND success
+    ORDER BY target, created_at DESC
+) a
+WHERE l.id::text = a.target
+  AND l.disabled
+  AND (a.action = 'screening.disable' OR a.details LIKE 'disabled=true%');
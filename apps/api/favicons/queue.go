@@ -0,0 +1,187 @@
+package favicons
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"log"
+	"strings"
+	"time"
+	"url-shortener-api/db"
+	"url-shortener-api/models"
+	"url-shortener-api/utils"
+)
+
+// Queue tuning. Failed hosts are retried with a growing delay and dropped after maxAttempts,
+// leaving their links without an icon.
+const (
+	maxAttempts  = 5
+	pollInterval = 30 * time.Second
+	claimTimeout = 5 * time.Minute
+	staleAfter   = 30 * 24 * time.Hour
+)
+
+// hostPattern extracts the lowercase host of links.original in SQL, matching utils.GetDomainFromURL
+const hostPattern = `lower(substring(original from '^[a-zA-Z][a-zA-Z0-9+.-]*://(?:[^@/]*@)?([^/:?#]+)'))`
+
+// wake nudges the worker when a job is queued so new links don't wait for the next poll
+var wake = make(chan struct{}, 1)
+
+// Lookup returns the stored favicon of a host
+func Lookup(ctx context.Context, domain string) (*models.Favicon, error) {
+	var favicon models.Favicon
+	err := db.DB.GetContext(ctx, &favicon, "SELECT * FROM favicons WHERE domain = $1", strings.ToLower(domain))
+	if err != nil {
+		return nil, err
+	}
+	return &favicon, nil
+}
+
+// Resolve returns the favicon URL to store on a new link to domain. Hosts without a fresh
+// icon are queued for download; their links get the URL once it has been fetched.
+func Resolve(ctx context.Context, domain string) *string {
+	domain = strings.ToLower(domain)
+	if domain == "" {
+		return nil
+	}
+
+	var fetchedAt time.Time
+	err := db.DB.GetContext(ctx, &fetchedAt, "SELECT fetched_at FROM favicons WHERE domain = $1", domain)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error looking up favicon for %s: %v", domain, err)
+		return nil
+	}
+
+	if err == sql.ErrNoRows || time.Since(fetchedAt) > staleAfter {
+		Enqueue(ctx, domain)
+	}
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	faviconURL := utils.FaviconURL(domain)
+	return &faviconURL
+}
+
+// Enqueue queues a host for favicon download. Hosts already queued are left alone.
+func Enqueue(ctx context.Context, domain string) {
+	_, err := db.DB.ExecContext(ctx,
+		"INSERT INTO favicon_jobs (domain) VALUES ($1) ON CONFLICT (domain) DO NOTHING",
+		strings.ToLower(domain))
+	if err != nil {
+		log.Printf("Error queueing favicon for %s: %v", domain, err)
+		return
+	}
+
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// StartWorker processes queued favicon downloads until ctx is cancelled
+func StartWorker(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			// Drain everything that is due before waiting again
+			for processNext(ctx) {
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-wake:
+			}
+		}
+	}()
+}
+
+// processNext downloads the favicon of the next due host. It reports whether a job was found.
+func processNext(ctx context.Context) bool {
+	// Claiming pushes run_at forward, so a worker that dies mid-job lets it be retried later
+	var job struct {
+		Domain   string `db:"domain"`
+		Attempts int    `db:"attempts"`
+	}
+	err := db.DB.GetContext(ctx, &job, `
+		UPDATE favicon_jobs
+		SET attempts = attempts + 1, run_at = $1
+		WHERE domain = (
+			SELECT domain FROM favicon_jobs
+			WHERE run_at <= NOW()
+			ORDER BY run_at
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING domain, attempts
+	`, time.Now().Add(claimTimeout))
+	if err == sql.ErrNoRows {
+		return false
+	} else if err != nil {
+		log.Printf("Error claiming favicon job: %v", err)
+		return false
+	}
+
+	found, err := fetchIcon(ctx, job.Domain)
+	if err == nil {
+		err = store(ctx, job.Domain, found)
+	}
+	if err == nil {
+		return true
+	}
+
+	if job.Attempts >= maxAttempts {
+		log.Printf("Giving up on favicon for %s after %d attempts: %v", job.Domain, job.Attempts, err)
+		if _, dbErr := db.DB.ExecContext(ctx, "DELETE FROM favicon_jobs WHERE domain = $1", job.Domain); dbErr != nil {
+			log.Printf("Error removing favicon job for %s: %v", job.Domain, dbErr)
+		}
+		return true
+	}
+
+	retryAt := time.Now().Add(time.Duration(job.Attempts*job.Attempts) * time.Minute)
+	_, dbErr := db.DB.ExecContext(ctx,
+		"UPDATE favicon_jobs SET run_at = $1, last_error = $2 WHERE domain = $3",
+		retryAt, err.Error(), job.Domain)
+	if dbErr != nil {
+		log.Printf("Error rescheduling favicon job for %s: %v", job.Domain, dbErr)
+	}
+	return true
+}
+
+// store saves a downloaded icon, finishes its job and points the host's links without an icon at it
+func store(ctx context.Context, domain string, found *icon) error {
+	sum := sha256.Sum256(found.data)
+
+	tx, err := db.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO favicons (domain, content_type, data, hash, source_url, fetched_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (domain) DO UPDATE
+		SET content_type = EXCLUDED.content_type, data = EXCLUDED.data, hash = EXCLUDED.hash,
+			source_url = EXCLUDED.source_url, fetched_at = EXCLUDED.fetched_at
+	`, domain, found.contentType, found.data, hex.EncodeToString(sum[:]), found.sourceURL)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM favicon_jobs WHERE domain = $1", domain); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE links SET favicon_url = $1 WHERE favicon_url IS NULL AND "+hostPattern+" = $2",
+		utils.FaviconURL(domain), domain)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,427 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"url-shortener-api/db"
+	"url-shortener-api/health"
+	"url-shortener-api/middleware"
+	"url-shortener-api/models"
+	"url-shortener-api/screening"
+	"url-shortener-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// impersonationTTL is how long an admin impersonation token stays valid
+const impersonationTTL = time.Hour
+
+// parsePagination reads limit and offset query params with sane bounds
+func parsePagination(c *gin.Context) (int, int) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+	if limit > 200 {
+		limit = 200
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	return limit, offset
+}
+
+// likeEscaper escapes the LIKE wildcards so search terms match literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// containsPattern returns an ILIKE pattern matching values that contain term
+func containsPattern(term string) string {
+	return "%" + likeEscaper.Replace(term) + "%"
+}
+
+// AuditImpersonation records every change made through an impersonation token, so support
+// sessions leave a trail of what the admin did as the user
+func AuditImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		// The auth middleware runs inside c.Next, so the impersonator is known by now
+		if middleware.GetImpersonatorID(c) == nil {
+			return
+		}
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return
+		}
+
+		status := c.Writer.Status()
+		writeAuditLog(c, "impersonation.request", c.Request.Method+" "+c.Request.URL.Path,
+			status < http.StatusBadRequest, fmt.Sprintf("status=%d", status))
+	}
+}
+
+// AdminListUsers lists users, optionally filtered by an email search term
+func AdminListUsers(c *gin.Context) {
+	limit, offset := parsePagination(c)
+	search := containsPattern(c.Query("q"))
+
+	users := make([]models.AdminUser, 0)
+	err := db.DB.Select(&users, `
+		SELECT
+			u.id, u.email, u.created_at, u.is_admin, u.suspended_at,
+			COUNT(l.id) as link_count,
+			COALESCE(SUM(l.clicks), 0) as total_clicks
+		FROM users u
+		LEFT JOIN links l ON l.user_id = u.id
+		WHERE u.email ILIKE $1
+		GROUP BY u.id
+		ORDER BY u.created_at DESC
+		LIMIT $2 OFFSET $3
+	`, search, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+// AdminSuspendUser suspends a user account, blocking login and existing tokens
+func AdminSuspendUser(c *gin.Context) {
+	setUserSuspension(c, true)
+}
+
+// AdminUnsuspendUser lifts a user suspension
+func AdminUnsuspendUser(c *gin.Context) {
+	setUserSuspension(c, false)
+}
+
+func setUserSuspension(c *gin.Context, suspend bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	var req models.SuspendUserRequest
+	_ = c.ShouldBindJSON(&req)
+
+	adminID := middleware.GetUserID(c)
+	if suspend && *adminID == id {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Admins cannot suspend themselves"})
+		return
+	}
+
+	var suspendedAt *time.Time
+	action := "admin.user.unsuspend"
+	if suspend {
+		now := time.Now()
+		suspendedAt = &now
+		action = "admin.user.suspend"
+	}
+
+	result, err := db.DB.Exec("UPDATE users SET suspended_at = $1 WHERE id = $2", suspendedAt, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	details := ""
+	if req.Reason != nil {
+		details = *req.Reason
+	}
+	writeAuditLog(c, action, id.String(), true, details)
+
+	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully", "suspended": suspend})
+}
+
+// AdminListLinks lists every link in the system, optionally filtered by slug or destination
+func AdminListLinks(c *gin.Context) {
+	limit, offset := parsePagination(c)
+	search := containsPattern(c.Query("q"))
+
+	links := make([]models.Link, 0)
+	err := db.DB.Select(&links, `
+		SELECT `+linkListColumns+`
+		FROM links
+		WHERE slug ILIKE $1 OR original ILIKE $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`, search, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve links"})
+		return
+	}
+
+	c.JSON(http.StatusOK, links)
+}
+
+// AdminUpdateLink blocks or unblocks any link regardless of ownership
+func AdminUpdateLink(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid link ID format"})
+		return
+	}
+
+	var req models.AdminUpdateLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	// Disabling blocks the link so its owner can't enable it again; enabling lifts the block
+	var blockedAt *time.Time
+	var blockedReason *string
+	now := time.Now()
+	if *req.Disabled {
+		blockedAt = &now
+		blockedReason = req.Reason
+	}
+
+	result, err := db.DB.Exec(
+		"UPDATE links SET disabled = $1, blocked_at = $2, blocked_reason = $3, last_updated = $4 WHERE id = $5",
+		*req.Disabled, blockedAt, blockedReason, now, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update link"})
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Link not found"})
+		return
+	}
+
+	details := fmt.Sprintf("disabled=%t", *req.Disabled)
+	if req.Reason != nil {
+		details += "; " + *req.Reason
+	}
+	writeAuditLog(c, "admin.link.update", id.String(), true, details)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Link updated successfully"})
+}
+
+// AdminGetStats returns system-wide user, link and click totals
+func AdminGetStats(c *gin.Context) {
+	var stats models.SystemStats
+	err := db.DB.Get(&stats, `
+		SELECT
+			(SELECT COUNT(*) FROM users) as total_users,
+			(SELECT COUNT(*) FROM users WHERE suspended_at IS NOT NULL) as suspended_users,
+			(SELECT COUNT(*) FROM links) as total_links,
+			(SELECT COUNT(*) FROM links WHERE user_id IS NULL) as anonymous_links,
+			(SELECT COUNT(*) FROM links WHERE disabled) as disabled_links,
+			(SELECT COUNT(*) FROM click_events) as total_clicks,
+			(SELECT COUNT(*) FROM click_events WHERE timestamp >= NOW() - INTERVAL '24 hours') as clicks_last_24h,
+			(SELECT COUNT(*) FROM click_events WHERE timestamp >= NOW() - INTERVAL '7 days') as clicks_last_7d,
+			(SELECT COUNT(DISTINCT ip) FROM click_events) as unique_visitors
+	`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get system stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// AdminImpersonateUser issues a short-lived token that acts as the given user for support
+func AdminImpersonateUser(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	var user models.User
+	err = db.DB.Get(&user, "SELECT id, email, created_at, is_admin, suspended_at FROM users WHERE id = $1", id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	adminID := middleware.GetUserID(c)
+	expiresAt := time.Now().Add(impersonationTTL)
+
+	// Impersonation tokens never carry the admin claim, even for admin targets
+	claims := middleware.JWTClaims{
+		UserID:         user.ID,
+		Email:          user.Email,
+		ImpersonatorID: adminID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(utils.AppConfig.JWTSecret))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	writeAuditLog(c, "admin.user.impersonate", user.ID.String(), true, "")
+
+	c.JSON(http.StatusOK, models.ImpersonationResponse{
+		Token:     token,
+		User:      user,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// AdminGetAuditLog lists recent audit log entries, optionally filtered by action
+func AdminGetAuditLog(c *gin.Context) {
+	limit, offset := parsePagination(c)
+
+	entries := make([]models.AuditLogEntry, 0)
+	var err error
+	if action := c.Query("action"); action != "" {
+		err = db.DB.Select(&entries, `
+			SELECT * FROM audit_log
+			WHERE action = $1
+			ORDER BY created_at DESC
+			LIMIT $2 OFFSET $3
+		`, action, limit, offset)
+	} else {
+		err = db.DB.Select(&entries, `
+			SELECT * FROM audit_log
+			ORDER BY created_at DESC
+			LIMIT $1 OFFSET $2
+		`, limit, offset)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve audit log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// AdminListBlocklist lists the URL screening blocklist
+func AdminListBlocklist(c *gin.Context) {
+	entries := make([]models.BlocklistEntry, 0)
+	err := db.DB.Select(&entries, "SELECT * FROM blocklist_entries ORDER BY created_at DESC")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve blocklist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// AdminCreateBlocklistEntry adds a domain or regex to the URL screening blocklist
+func AdminCreateBlocklistEntry(c *gin.Context) {
+	var req models.CreateBlocklistEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	pattern := strings.TrimSpace(req.Pattern)
+	if req.Kind == "domain" {
+		pattern = strings.TrimSuffix(strings.ToLower(pattern), ".")
+	} else if _, err := regexp.Compile(pattern); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid regular expression"})
+		return
+	}
+
+	entry := models.BlocklistEntry{
+		ID:        uuid.New(),
+		Kind:      req.Kind,
+		Pattern:   pattern,
+		Reason:    req.Reason,
+		CreatedBy: middleware.GetUserID(c),
+		CreatedAt: time.Now(),
+	}
+
+	_, err := db.DB.Exec(`
+		INSERT INTO blocklist_entries (id, kind, pattern, reason, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, entry.ID, entry.Kind, entry.Pattern, entry.Reason, entry.CreatedBy, entry.CreatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Blocklist entry already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create blocklist entry"})
+		return
+	}
+
+	if err := screening.Blocklist.Reload(c.Request.Context()); err != nil {
+		fmt.Printf("Error reloading blocklist: %v\n", err)
+	}
+	writeAuditLog(c, "admin.blocklist.create", entry.Kind+":"+entry.Pattern, true, "")
+
+	c.JSON(http.StatusCreated, entry)
+}
+
+// AdminDeleteBlocklistEntry removes an entry from the URL screening blocklist
+func AdminDeleteBlocklistEntry(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid blocklist entry ID format"})
+		return
+	}
+
+	var entry models.BlocklistEntry
+	err = db.DB.Get(&entry, "DELETE FROM blocklist_entries WHERE id = $1 RETURNING *", id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Blocklist entry not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete blocklist entry"})
+		return
+	}
+
+	if err := screening.Blocklist.Reload(c.Request.Context()); err != nil {
+		fmt.Printf("Error reloading blocklist: %v\n", err)
+	}
+	writeAuditLog(c, "admin.blocklist.delete", entry.Kind+":"+entry.Pattern, true, "")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Blocklist entry deleted successfully"})
+}
+
+// AdminRecheckLinks queues a screening recheck of every link instead of waiting for the next run
+func AdminRecheckLinks(c *gin.Context) {
+	queued := screening.RequestRecheck()
+	writeAuditLog(c, "admin.screening.recheck", "", true, "")
+
+	if !queued {
+		c.JSON(http.StatusAccepted, gin.H{"message": "A recheck is already queued"})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "Recheck queued"})
+}
+
+// AdminCheckLinkHealth queues a check of every active link instead of waiting for the next run
+func AdminCheckLinkHealth(c *gin.Context) {
+	queued := health.RequestRun()
+	writeAuditLog(c, "admin.health.check", "", true, "")
+
+	if !queued {
+		c.JSON(http.StatusAccepted, gin.H{"message": "A health check is already queued"})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "Health check queued"})
+}
@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"url-shortener-api/middleware"
+	"url-shortener-api/models"
+	"url-shortener-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func TestContainsPattern(t *testing.T) {
+	tests := []struct {
+		term string
+		want string
+	}{
+		{"", "%%"},
+		{"example.com", "%example.com%"},
+		{"100%", `%100\%%`},
+		{"my_link", `%my\_link%`},
+		{`back\slash`, `%back\\slash%`},
+		{`%_\`, `%\%\_\\%`},
+	}
+
+	for _, tt := range tests {
+		if got := containsPattern(tt.term); got != tt.want {
+			t.Errorf("containsPattern(%q) = %q, want %q", tt.term, got, tt.want)
+		}
+	}
+}
+
+const testJWTSecret = "test-secret"
+
+var (
+	testAdminID = uuid.MustParse("aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa")
+	testUserID  = uuid.MustParse("bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb")
+	testLinkID  = uuid.MustParse("cccccccc-cccc-cccc-cccc-cccccccccccc")
+)
+
+// testToken signs a session token the way login and impersonation do
+func testToken(t *testing.T, claims middleware.JWTClaims) string {
+	t.Helper()
+	utils.AppConfig.JWTSecret = testJWTSecret
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Hour))
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testJWTSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// adminRouter mirrors how routes.SetupRoutes mounts the routes under test
+func adminRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	api := r.Group("/api")
+	api.Use(AuditImpersonation())
+	api.PATCH("/links/:id", middleware.OptionalJWTAuth(), UpdateLink)
+	api.POST("/workspaces", middleware.JWTAuth(), func(c *gin.Context) { c.Status(http.StatusCreated) })
+
+	admin := api.Group("/admin", middleware.JWTAuth(), middleware.AdminAuth())
+	admin.POST("/users/:id/suspend", AdminSuspendUser)
+	admin.POST("/users/:id/unsuspend", AdminUnsuspendUser)
+	admin.POST("/users/:id/impersonate", AdminImpersonateUser)
+	admin.PATCH("/links/:id", AdminUpdateLink)
+	return r
+}
+
+func serve(r *gin.Engine, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// accounts answers the account lookups made by the auth middleware and admin handlers
+type accounts struct {
+	suspended map[uuid.UUID]bool
+	admins    map[uuid.UUID]bool
+}
+
+func (a accounts) respond(query string, args []driver.Value) fakeResult {
+	switch {
+	case strings.Contains(query, "suspended_at IS NOT NULL FROM users"):
+		return row([]string{"suspended"}, a.suspended[uuid.MustParse(args[0].(string))])
+	case strings.Contains(query, "SELECT is_admin FROM users"):
+		return row([]string{"is_admin"}, a.admins[uuid.MustParse(args[0].(string))])
+	case strings.Contains(query, "FROM users WHERE id"):
+		id := uuid.MustParse(args[0].(string))
+		return row([]string{"id", "email", "created_at", "is_admin", "suspended_at"},
+			id.String(), "user@example.com", time.Now(), a.admins[id], nil)
+	case strings.HasPrefix(strings.TrimSpace(query), "UPDATE"), strings.Contains(query, "INSERT INTO audit_log"):
+		return fakeResult{affected: 1}
+	}
+	return fakeResult{}
+}
+
+func TestAdminRoutesAuthorization(t *testing.T) {
+	target := "/api/admin/users/" + testUserID.String() + "/suspend"
+
+	tests := []struct {
+		name     string
+		claims   middleware.JWTClaims
+		accounts accounts
+		want     int
+	}{
+		{"admin", middleware.JWTClaims{UserID: testAdminID, IsAdmin: true},
+			accounts{admins: map[uuid.UUID]bool{testAdminID: true}}, http.StatusOK},
+		{"no admin claim", middleware.JWTClaims{UserID: testAdminID},
+			accounts{admins: map[uuid.UUID]bool{testAdminID: true}}, http.StatusForbidden},
+		{"admin revoked since login", middleware.JWTClaims{UserID: testAdminID, IsAdmin: true},
+			accounts{}, http.StatusForbidden},
+		{"suspended admin", middleware.JWTClaims{UserID: testAdminID, IsAdmin: true},
+			accounts{admins: map[uuid.UUID]bool{testAdminID: true}, suspended: map[uuid.UUID]bool{testAdminID: true}}, http.StatusForbidden},
+		{"impersonation token", middleware.JWTClaims{UserID: testAdminID, IsAdmin: true, ImpersonatorID: &testAdminID},
+			accounts{admins: map[uuid.UUID]bool{testAdminID: true}}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFakeDB(t, tt.accounts.respond)
+			w := serve(adminRouter(), http.MethodPost, target, testToken(t, tt.claims), "")
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if updated := len(fake.executed("UPDATE users SET suspended_at")) > 0; updated != (tt.want == http.StatusOK) {
+				t.Errorf("user suspension written = %v", updated)
+			}
+		})
+	}
+}
+
+func TestAdminSuspendUser(t *testing.T) {
+	admin := accounts{admins: map[uuid.UUID]bool{testAdminID: true}}
+	token := func(t *testing.T) string {
+		return testToken(t, middleware.JWTClaims{UserID: testAdminID, IsAdmin: true})
+	}
+
+	t.Run("suspend", func(t *testing.T) {
+		fake := useFakeDB(t, admin.respond)
+		w := serve(adminRouter(), http.MethodPost, "/api/admin/users/"+testUserID.String()+"/suspend", token(t), `{"reason":"spam"}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+		}
+		updates := fake.executed("UPDATE users SET suspended_at")
+		if len(updates) != 1 || updates[0].args[0] == nil {
+			t.Errorf("suspension not written: %v", updates)
+		}
+		if audits := fake.executed("INSERT INTO audit_log"); len(audits) != 1 || audits[0].args[0] != "admin.user.suspend" {
+			t.Errorf("suspension not audited: %v", audits)
+		}
+	})
+
+	t.Run("unsuspend", func(t *testing.T) {
+		fake := useFakeDB(t, admin.respond)
+		w := serve(adminRouter(), http.MethodPost, "/api/admin/users/"+testUserID.String()+"/unsuspend", token(t), "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+		}
+		if updates := fake.executed("UPDATE users SET suspended_at"); len(updates) != 1 || updates[0].args[0] != nil {
+			t.Errorf("suspension not lifted: %v", updates)
+		}
+	})
+
+	t.Run("self", func(t *testing.T) {
+		fake := useFakeDB(t, admin.respond)
+		w := serve(adminRouter(), http.MethodPost, "/api/admin/users/"+testAdminID.String()+"/suspend", token(t), "")
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want 400: %s", w.Code, w.Body)
+		}
+		if updates := fake.executed("UPDATE users SET suspended_at"); len(updates) != 0 {
+			t.Errorf("admin suspended themselves: %v", updates)
+		}
+	})
+
+	t.Run("suspended user's token", func(t *testing.T) {
+		useFakeDB(t, accounts{suspended: map[uuid.UUID]bool{testUserID: true}}.respond)
+		w := serve(adminRouter(), http.MethodPost, "/api/workspaces", testToken(t, middleware.JWTClaims{UserID: testUserID}), "")
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("status = %d, want 403: %s", w.Code, w.Body)
+		}
+	})
+}
+
+func TestAdminUpdateLinkBlocks(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantBlocked bool
+		wantReason  driver.Value
+	}{
+		{"block", `{"disabled":true,"reason":"phishing"}`, true, "phishing"},
+		{"block without reason", `{"disabled":true}`, true, nil},
+		{"unblock", `{"disabled":false}`, false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFakeDB(t, accounts{admins: map[uuid.UUID]bool{testAdminID: true}}.respond)
+			token := testToken(t, middleware.JWTClaims{UserID: testAdminID, IsAdmin: true})
+			w := serve(adminRouter(), http.MethodPatch, "/api/admin/links/"+testLinkID.String(), token, tt.body)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+			}
+
+			updates := fake.executed("UPDATE links SET disabled")
+			if len(updates) != 1 {
+				t.Fatalf("link updated %d times, want once", len(updates))
+			}
+			args := updates[0].args
+			if args[0] != tt.wantBlocked || (args[1] != nil) != tt.wantBlocked || args[2] != tt.wantReason {
+				t.Errorf("update args = %v, want disabled and blocked %v with reason %v", args[:3], tt.wantBlocked, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestUpdateLinkKeepsAdminBlock(t *testing.T) {
+	blockedAt := time.Now().Add(-time.Hour)
+	respond := func(query string, args []driver.Value) fakeResult {
+		switch {
+		case strings.Contains(query, "suspended_at IS NOT NULL FROM users"):
+			return row([]string{"suspended"}, false)
+		case strings.Contains(query, "SELECT m.role"):
+			return row([]string{"role"}, models.RoleOwner)
+		case strings.Contains(query, "SELECT * FROM links WHERE id"):
+			return row([]string{"id", "slug", "original", "disabled", "blocked_at", "blocked_reason"},
+				testLinkID.String(), "abc", "https://example.com", true, blockedAt, "phishing")
+		}
+		return fakeResult{affected: 1}
+	}
+
+	for _, body := range []string{`{"disabled":false}`, `{"name":"renamed","disabled":false}`} {
+		fake := useFakeDB(t, respond)
+		token := testToken(t, middleware.JWTClaims{UserID: testUserID})
+		w := serve(adminRouter(), http.MethodPatch, "/api/links/"+testLinkID.String(), token, body)
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("%s: status = %d, want 403: %s", body, w.Code, w.Body)
+		}
+		if updates := fake.executed("UPDATE links"); len(updates) != 0 {
+			t.Errorf("%s: blocked link updated by its owner: %v", body, updates)
+		}
+	}
+}
+
+func TestAdminImpersonateUser(t *testing.T) {
+	// The target is an admin too, which the token must not inherit
+	fake := useFakeDB(t, accounts{admins: map[uuid.UUID]bool{testAdminID: true, testUserID: true}}.respond)
+	r := adminRouter()
+
+	w := serve(r, http.MethodPost, "/api/admin/users/"+testUserID.String()+"/impersonate",
+		testToken(t, middleware.JWTClaims{UserID: testAdminID, IsAdmin: true}), "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var response models.ImpersonationResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+
+	claims := &middleware.JWTClaims{}
+	if _, err := jwt.ParseWithClaims(response.Token, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(testJWTSecret), nil
+	}); err != nil {
+		t.Fatalf("impersonation token invalid: %v", err)
+	}
+	if claims.UserID != testUserID || claims.ImpersonatorID == nil || *claims.ImpersonatorID != testAdminID {
+		t.Errorf("claims = user %v impersonated by %v, want %v by %v", claims.UserID, claims.ImpersonatorID, testUserID, testAdminID)
+	}
+	if claims.IsAdmin {
+		t.Error("impersonation token carries the admin claim")
+	}
+	if lifetime := time.Until(claims.ExpiresAt.Time); lifetime > impersonationTTL {
+		t.Errorf("impersonation token valid for %v, want at most %v", lifetime, impersonationTTL)
+	}
+
+	// The token can't reach admin routes, and changes made with it are attributed to the admin
+	if w := serve(r, http.MethodPost, "/api/admin/users/"+testAdminID.String()+"/unsuspend", response.Token, ""); w.Code != http.StatusForbidden {
+		t.Errorf("admin route with impersonation token: status = %d, want 403", w.Code)
+	}
+	if w := serve(r, http.MethodPost, "/api/workspaces", response.Token, ""); w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", w.Code, w.Body)
+	}
+	var audited bool
+	for _, entry := range fake.executed("INSERT INTO audit_log") {
+		if entry.args[0] == "impersonation.request" {
+			audited = true
+			if details, _ := entry.args[6].(string); !strings.Contains(details, testAdminID.String()) {
+				t.Errorf("impersonated request not attributed to the admin: %q", details)
+			}
+		}
+	}
+	if !audited {
+		t.Error("impersonated request not audited")
+	}
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"database/sql"
 	"net/http"
+	"strings"
 	"time"
 	"url-shortener-api/db"
 	"url-shortener-api/middleware"
@@ -72,14 +73,32 @@ func Register(c *gin.Context) {
 		VALUES ($1, $2, $3, $4)
 	`
 
-	_, err = db.DB.Exec(query, user.ID, user.Email, user.Password, user.CreatedAt)
+	tx, err := db.DB.Beginx()
 	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(query, user.ID, user.Email, user.Password, user.CreatedAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+
+	// Every user gets a personal workspace that owns their links by default
+	if _, err := createPersonalWorkspace(tx, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
 
 	// Generate JWT token
-	token, err := generateJWTToken(user.ID, user.Email)
+	token, err := generateJWTToken(user.ID, user.Email, user.IsAdmin)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
@@ -104,13 +123,33 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	// Count the attempt up front and reject it if the account or client is locked out. The
+	// account key is the submitted email so that lockouts behave the same whether or not it exists.
+	accountKey := attemptKey{Scope: scopeAccount, Key: strings.ToLower(strings.TrimSpace(req.Email))}
+	ipKey := attemptKey{Scope: scopeIP, Key: c.ClientIP()}
+
+	remaining, err := beginAttempt(accountKey, ipKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if remaining > 0 {
+		writeAuditLog(c, "login", accountKey.Key, false, "locked out")
+		respondLocked(c, remaining)
+		return
+	}
+
 	// Find user by email
 	var user models.User
-	err := db.DB.Get(&user, "SELECT id, email, password, created_at FROM users WHERE email = $1", req.Email)
+	err = db.DB.Get(&user, "SELECT id, email, password, created_at, is_admin, suspended_at FROM users WHERE email = $1", req.Email)
 	if err == sql.ErrNoRows {
+		// Spend the same time as a real password check so unknown emails can't be detected
+		compareDummyPassword(req.Password)
+		writeAuditLog(c, "login", accountKey.Key, false, "invalid credentials")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	} else if err != nil {
+		releaseAttempt(accountKey, ipKey)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
 	}
@@ -118,12 +157,26 @@ func Login(c *gin.Context) {
 	// Verify password
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password))
 	if err != nil {
+		writeAuditLog(c, "login", accountKey.Key, false, "invalid credentials")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
+	// The right password forgives the account's failures. The client IP only gets this attempt
+	// back, so logging in to one account doesn't wipe out failures against others.
+	clearFailedAttempts(accountKey)
+	releaseAttempt(ipKey)
+
+	if user.SuspendedAt != nil {
+		writeAuditLog(c, "login", accountKey.Key, false, "account suspended")
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account suspended"})
+		return
+	}
+
+	writeAuditLog(c, "login", accountKey.Key, true, "")
+
 	// Generate JWT token
-	token, err := generateJWTToken(user.ID, user.Email)
+	token, err := generateJWTToken(user.ID, user.Email, user.IsAdmin)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
@@ -149,7 +202,7 @@ func GetProfile(c *gin.Context) {
 	}
 
 	var user models.User
-	err := db.DB.Get(&user, "SELECT id, email, created_at FROM users WHERE id = $1", *userID)
+	err := db.DB.Get(&user, "SELECT id, email, created_at, is_admin, suspended_at FROM users WHERE id = $1", *userID)
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
@@ -174,10 +227,11 @@ func Logout(c *gin.Context) {
 }
 
 // generateJWTToken creates a new JWT token for the user
-func generateJWTToken(userID uuid.UUID, email string) (string, error) {
+func generateJWTToken(userID uuid.UUID, email string, isAdmin bool) (string, error) {
 	claims := middleware.JWTClaims{
-		UserID: userID,
-		Email:  email,
+		UserID:  userID,
+		Email:   email,
+		IsAdmin: isAdmin,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // 24 hours
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
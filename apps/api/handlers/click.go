@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"url-shortener-api/db"
+	"url-shortener-api/models"
+	"url-shortener-api/stream"
+	"url-shortener-api/webhooks"
+)
+
+// Click recording tuning. Clicks are written by background workers so redirects don't wait
+// for the insert, the webhook deliveries and the live stream notification.
+const (
+	clickQueueSize = 1024
+	clickRecorders = 4
+)
+
+// pendingClick is a click waiting to be recorded
+type pendingClick struct {
+	link        models.Link
+	click       models.ClickEvent
+	destination string
+}
+
+var clickQueue = make(chan pendingClick, clickQueueSize)
+
+// StartClickRecorders records queued clicks until ctx is cancelled
+func StartClickRecorders(ctx context.Context) {
+	for i := 0; i < clickRecorders; i++ {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case pending := <-clickQueue:
+					recordClick(ctx, &pending)
+				}
+			}
+		}()
+	}
+}
+
+// queueClick hands a click to the recorders. When they fall behind the click is recorded
+// inline rather than dropped.
+func queueClick(ctx context.Context, link *models.Link, click *models.ClickEvent, destination string) {
+	pending := pendingClick{link: *link, click: *click, destination: destination}
+	select {
+	case clickQueue <- pending:
+	default:
+		recordClick(ctx, &pending)
+	}
+}
+
+// recordClick stores a click. For workspace links the same statement queues click.recorded
+// for sampled webhooks and publishes the click to live dashboards, so a click costs one
+// round trip whatever is listening.
+func recordClick(ctx context.Context, pending *pendingClick) {
+	link, click := &pending.link, &pending.click
+	if link.WorkspaceID == nil {
+		_, err := db.DB.ExecContext(ctx, `
+			INSERT INTO click_events (id, link_id, timestamp, ip, country, device, rule_id, variant_id, utm_source, utm_medium, utm_campaign, source)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		`, click.ID, click.LinkID, click.Timestamp, click.IP, click.Country, click.Device,
+			click.RuleID, click.VariantID, click.UTMSource, click.UTMMedium, click.UTMCampaign, click.Source)
+		if err != nil {
+			fmt.Printf("Error recording click event: %v\n", err)
+		}
+		return
+	}
+
+	eventID, payload, err := webhooks.NewPayload(models.EventClickRecorded, clickWebhookData(link, click, pending.destination))
+	if err != nil {
+		fmt.Printf("Error encoding click webhook event: %v\n", err)
+		return
+	}
+	notification, err := stream.EncodeClick(clickStreamEvent(link, click))
+	if err != nil {
+		fmt.Printf("Error encoding click stream event: %v\n", err)
+		return
+	}
+
+	var deliveries int
+	var notified sql.NullString
+	err = db.DB.QueryRowContext(ctx, `
+		WITH click AS (
+			INSERT INTO click_events (id, link_id, timestamp, ip, country, device, rule_id, variant_id, utm_source, utm_medium, utm_campaign, source)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+			RETURNING id
+		), deliveries AS (
+			INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload)
+			SELECT w.id, $14, $15, $16 FROM webhooks w, click
+			WHERE w.workspace_id = $13 AND w.active AND $15 = ANY(w.events) AND random() < w.click_sample_rate
+			RETURNING id
+		)
+		SELECT (SELECT COUNT(*) FROM deliveries), pg_notify($17, $18) FROM click
+	`, click.ID, click.LinkID, click.Timestamp, click.IP, click.Country, click.Device,
+		click.RuleID, click.VariantID, click.UTMSource, click.UTMMedium, click.UTMCampaign, click.Source,
+		*link.WorkspaceID, eventID, models.EventClickRecorded, payload, stream.Channel, notification,
+	).Scan(&deliveries, &notified)
+	if err != nil {
+		fmt.Printf("Error recording click event: %v\n", err)
+		return
+	}
+
+	if deliveries > 0 {
+		webhooks.Notify()
+	}
+}
@@ -1,14 +1,12 @@
 package handlers
 
 import (
-	"database/sql"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
 	"url-shortener-api/db"
 	"url-shortener-api/models"
-	"url-shortener-api/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -23,6 +21,7 @@ type DashboardStats struct {
 	PeakClickTime    *PeakTimeData         `json:"peakClickTime"`
 	TopCountries     []CountryData         `json:"topCountries"`
 	RecentActivity   []ActivityData        `json:"recentActivity"`
+	TopCampaigns     []models.CampaignStats `json:"topCampaigns"`
 }
 
 type ClicksOverTimeData struct {
@@ -79,18 +78,34 @@ func GetDashboardStats(c *gin.Context) {
 		return
 	}
 
+	// Scope stats to a single workspace if requested, otherwise to every workspace the user belongs to
+	var workspaceFilter *uuid.UUID
+	if workspaceParam := c.Query("workspaceId"); workspaceParam != "" {
+		workspaceID, err := uuid.Parse(workspaceParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID format"})
+			return
+		}
+		role, err := workspaceRole(workspaceID, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if role == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Workspace not found or access denied"})
+			return
+		}
+		workspaceFilter = &workspaceID
+	}
+	scope, scopeArg := statsScope(userID, workspaceFilter)
+	allTime := statsPeriod{}
+
 	database := db.DB
 	stats := DashboardStats{}
 
 	// Get unique visitors count
-	var uniqueVisitors int
-	err := database.QueryRow(`
-		SELECT COUNT(DISTINCT ip) 
-		FROM click_events ce
-		JOIN links l ON ce.link_id = l.id
-		WHERE l.user_id = $1
-	`, userID).Scan(&uniqueVisitors)
-	if err != nil && err != sql.ErrNoRows {
+	uniqueVisitors, err := countUniqueVisitors(scope, scopeArg, allTime)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get unique visitors"})
 		return
 	}
@@ -102,23 +117,22 @@ func GetDashboardStats(c *gin.Context) {
 		SELECT 
 			l.id, l.name, l.slug, l.original, l.clicks, 
 			l.created_at, l.last_updated, l.expires_at, 
-			l.active_from, l.user_id, l.disabled,
+			l.active_from, l.user_id, l.disabled, l.domain_id,
 			COUNT(DISTINCT ce.ip) as unique_clicks
 		FROM links l
 		LEFT JOIN click_events ce ON l.id = ce.link_id
-		WHERE l.user_id = $1
+		WHERE ` + scope + `
 		GROUP BY l.id
 		ORDER BY l.clicks DESC
 		LIMIT 1
-	`, userID).Scan(
+	`, scopeArg).Scan(
 		&mostPopularLink.ID, &mostPopularLink.Name, &mostPopularLink.Slug,
 		&mostPopularLink.Original, &mostPopularLink.Clicks, &mostPopularLink.CreatedAt,
 		&mostPopularLink.LastUpdated, &mostPopularLink.ExpiresAt, &mostPopularLink.ActiveFrom,
-		&mostPopularLink.UserID, &mostPopularLink.Disabled, &uniqueVisitors,
+		&mostPopularLink.UserID, &mostPopularLink.Disabled, &mostPopularLink.DomainID, &uniqueVisitors,
 	)
 	if err == nil {
-		baseURL := utils.AppConfig.BaseURL
-		mostPopularLink.ShortURL = baseURL + "/" + mostPopularLink.Slug
+		mostPopularLink.ShortURL = domainShortURL(mostPopularLink.DomainID, mostPopularLink.Slug)
 		
 		// Calculate IsActive and IsExpired
 		now := time.Now()
@@ -137,65 +151,32 @@ func GetDashboardStats(c *gin.Context) {
 	}
 
 	// Get clicks over time (last 7 days)
-	clicksOverTime := make([]ClicksOverTimeData, 0)
-	rows, err := database.Query(`
-		SELECT 
-			DATE(ce.timestamp) as date,
-			COUNT(*) as clicks
-		FROM click_events ce
-		JOIN links l ON ce.link_id = l.id
-		WHERE l.user_id = $1 AND ce.timestamp >= NOW() - INTERVAL '7 days'
-		GROUP BY DATE(ce.timestamp)
-		ORDER BY date
-	`, userID)
-	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var data ClicksOverTimeData
-			var date time.Time
-			if err := rows.Scan(&date, &data.Clicks); err == nil {
-				data.Date = date.Format("2006-01-02")
-				clicksOverTime = append(clicksOverTime, data)
-			}
-		}
+	weekAgo := time.Now().AddDate(0, 0, -7)
+	clicksOverTime, err := queryClicksPerDay(scope, scopeArg, statsPeriod{From: &weekAgo})
+	if err != nil {
+		clicksOverTime = make([]ClicksOverTimeData, 0)
 	}
 	stats.ClicksOverTime = clicksOverTime
 
 	// Get top 5 links
-	topLinks := make([]TopLinkData, 0)
-	rows, err = database.Query(`
-		SELECT 
-			l.id, COALESCE(l.name, l.slug) as name, l.slug, l.clicks
-		FROM links l
-		WHERE l.user_id = $1
-		ORDER BY l.clicks DESC
-		LIMIT 5
-	`, userID)
-	if err == nil {
-		defer rows.Close()
-		baseURL := utils.AppConfig.BaseURL
-		for rows.Next() {
-			var data TopLinkData
-			if err := rows.Scan(&data.ID, &data.Name, &data.Slug, &data.Clicks); err == nil {
-				data.ShortURL = baseURL + "/" + data.Slug
-				topLinks = append(topLinks, data)
-			}
-		}
+	topLinks, err := queryTopLinks(scope, scopeArg, allTime, 5)
+	if err != nil {
+		topLinks = make([]TopLinkData, 0)
 	}
 	stats.TopLinks = topLinks
 
 	// Get device breakdown
 	deviceBreakdown := make([]DeviceData, 0)
-	rows, err = database.Query(`
+	rows, err := database.Query(`
 		SELECT 
 			COALESCE(ce.device, 'Unknown') as device,
 			COUNT(*) as clicks
 		FROM click_events ce
 		JOIN links l ON ce.link_id = l.id
-		WHERE l.user_id = $1
+		WHERE ` + scope + `
 		GROUP BY device
 		ORDER BY clicks DESC
-	`, userID)
+	`, scopeArg)
 	if err == nil {
 		defer rows.Close()
 		var totalClicks int
@@ -226,11 +207,11 @@ func GetDashboardStats(c *gin.Context) {
 			COUNT(*) as clicks
 		FROM click_events ce
 		JOIN links l ON ce.link_id = l.id
-		WHERE l.user_id = $1
+		WHERE ` + scope + `
 		GROUP BY hour
 		ORDER BY clicks DESC
 		LIMIT 1
-	`, userID).Scan(&peakTime.Hour, &peakTime.Clicks)
+	`, scopeArg).Scan(&peakTime.Hour, &peakTime.Clicks)
 	if err == nil {
 		// Format hour to readable time
 		peakTime.Label = formatHour(peakTime.Hour)
@@ -238,30 +219,19 @@ func GetDashboardStats(c *gin.Context) {
 	}
 
 	// Get top countries
-	topCountries := make([]CountryData, 0)
-	rows, err = database.Query(`
-		SELECT 
-			COALESCE(ce.country, 'Unknown') as country,
-			COUNT(*) as clicks
-		FROM click_events ce
-		JOIN links l ON ce.link_id = l.id
-		WHERE l.user_id = $1 AND ce.country IS NOT NULL
-		GROUP BY country
-		ORDER BY clicks DESC
-		LIMIT 5
-	`, userID)
-	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var data CountryData
-			if err := rows.Scan(&data.Country, &data.Clicks); err == nil {
-				data.Code = getCountryCode(data.Country)
-				topCountries = append(topCountries, data)
-			}
-		}
+	topCountries, err := queryTopCountries(scope, scopeArg, allTime, 5)
+	if err != nil {
+		topCountries = make([]CountryData, 0)
 	}
 	stats.TopCountries = topCountries
 
+	// Get top UTM campaigns
+	topCampaigns, err := queryTopCampaigns(scope, scopeArg, allTime, 5)
+	if err != nil {
+		topCampaigns = make([]models.CampaignStats, 0)
+	}
+	stats.TopCampaigns = topCampaigns
+
 	// Get recent activity
 	recentActivity := make([]ActivityData, 0)
 	rows, err = database.Query(`
@@ -274,10 +244,10 @@ func GetDashboardStats(c *gin.Context) {
 			COALESCE(ce.device, 'Unknown') as device
 		FROM click_events ce
 		JOIN links l ON ce.link_id = l.id
-		WHERE l.user_id = $1
+		WHERE ` + scope + `
 		ORDER BY ce.timestamp DESC
 		LIMIT 10
-	`, userID)
+	`, scopeArg)
 	if err == nil {
 		defer rows.Close()
 		for rows.Next() {
@@ -295,6 +265,180 @@ func GetDashboardStats(c *gin.Context) {
 	c.JSON(http.StatusOK, stats)
 }
 
+// statsPeriod bounds the clicks an aggregation counts. A nil end is open.
+type statsPeriod struct {
+	From *time.Time
+	To   *time.Time
+}
+
+// periodFilter restricts click_events ce to a statsPeriod passed as $2 and $3
+const periodFilter = ` AND ($2::timestamp IS NULL OR ce.timestamp >= $2) AND ($3::timestamp IS NULL OR ce.timestamp < $3)`
+
+// statsScope returns the condition on links l selecting a user's stats, with its $1 argument:
+// one workspace, or every workspace the user belongs to
+func statsScope(userID uuid.UUID, workspaceID *uuid.UUID) (string, interface{}) {
+	if workspaceID != nil {
+		return "l.workspace_id = $1", *workspaceID
+	}
+	return "l.workspace_id IN (SELECT workspace_id FROM workspace_members WHERE user_id = $1)", userID
+}
+
+// countClicks counts the clicks on links in scope during a period
+func countClicks(scope string, scopeArg interface{}, period statsPeriod) (int, error) {
+	var clicks int
+	err := db.DB.QueryRow(`
+		SELECT COUNT(*)
+		FROM click_events ce
+		JOIN links l ON ce.link_id = l.id
+		WHERE `+scope+periodFilter,
+		scopeArg, period.From, period.To).Scan(&clicks)
+	return clicks, err
+}
+
+// countUniqueVisitors counts the distinct IPs that clicked links in scope during a period
+func countUniqueVisitors(scope string, scopeArg interface{}, period statsPeriod) (int, error) {
+	var visitors int
+	err := db.DB.QueryRow(`
+		SELECT COUNT(DISTINCT ip)
+		FROM click_events ce
+		JOIN links l ON ce.link_id = l.id
+		WHERE `+scope+periodFilter,
+		scopeArg, period.From, period.To).Scan(&visitors)
+	return visitors, err
+}
+
+// queryClicksPerDay returns daily click counts during a period, leaving out days without clicks
+func queryClicksPerDay(scope string, scopeArg interface{}, period statsPeriod) ([]ClicksOverTimeData, error) {
+	rows, err := db.DB.Query(`
+		SELECT 
+			DATE(ce.timestamp) as date,
+			COUNT(*) as clicks
+		FROM click_events ce
+		JOIN links l ON ce.link_id = l.id
+		WHERE `+scope+periodFilter+`
+		GROUP BY DATE(ce.timestamp)
+		ORDER BY date
+	`, scopeArg, period.From, period.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clicksOverTime := make([]ClicksOverTimeData, 0)
+	for rows.Next() {
+		var data ClicksOverTimeData
+		var date time.Time
+		if err := rows.Scan(&date, &data.Clicks); err == nil {
+			data.Date = date.Format("2006-01-02")
+			clicksOverTime = append(clicksOverTime, data)
+		}
+	}
+	return clicksOverTime, rows.Err()
+}
+
+// queryTopLinks returns the most clicked links in scope. Over all time this uses the links'
+// click counters; for a bounded period it counts that period's click events.
+func queryTopLinks(scope string, scopeArg interface{}, period statsPeriod, limit int) ([]TopLinkData, error) {
+	query := `
+		SELECT 
+			l.id, COALESCE(l.name, l.slug) as name, l.slug, l.clicks, l.domain_id
+		FROM links l
+		WHERE ` + scope + `
+		ORDER BY l.clicks DESC
+		LIMIT $2
+	`
+	args := []interface{}{scopeArg, limit}
+	if period.From != nil || period.To != nil {
+		query = `
+			SELECT
+				l.id, COALESCE(l.name, l.slug) as name, l.slug, COUNT(*) as clicks, l.domain_id
+			FROM click_events ce
+			JOIN links l ON ce.link_id = l.id
+			WHERE ` + scope + periodFilter + `
+			GROUP BY l.id
+			ORDER BY clicks DESC
+			LIMIT $4
+		`
+		args = []interface{}{scopeArg, period.From, period.To, limit}
+	}
+
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	topLinks := make([]TopLinkData, 0)
+	for rows.Next() {
+		var data TopLinkData
+		var domainID *uuid.UUID
+		if err := rows.Scan(&data.ID, &data.Name, &data.Slug, &data.Clicks, &domainID); err == nil {
+			data.ShortURL = domainShortURL(domainID, data.Slug)
+			topLinks = append(topLinks, data)
+		}
+	}
+	return topLinks, rows.Err()
+}
+
+// queryTopCountries returns the countries with the most clicks during a period
+func queryTopCountries(scope string, scopeArg interface{}, period statsPeriod, limit int) ([]CountryData, error) {
+	rows, err := db.DB.Query(`
+		SELECT 
+			COALESCE(ce.country, 'Unknown') as country,
+			COUNT(*) as clicks
+		FROM click_events ce
+		JOIN links l ON ce.link_id = l.id
+		WHERE `+scope+periodFilter+` AND ce.country IS NOT NULL
+		GROUP BY country
+		ORDER BY clicks DESC
+		LIMIT $4
+	`, scopeArg, period.From, period.To, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	topCountries := make([]CountryData, 0)
+	for rows.Next() {
+		var data CountryData
+		if err := rows.Scan(&data.Country, &data.Clicks); err == nil {
+			data.Code = getCountryCode(data.Country)
+			topCountries = append(topCountries, data)
+		}
+	}
+	return topCountries, rows.Err()
+}
+
+// queryTopCampaigns returns the UTM campaigns with the most clicks during a period
+func queryTopCampaigns(scope string, scopeArg interface{}, period statsPeriod, limit int) ([]models.CampaignStats, error) {
+	rows, err := db.DB.Query(`
+		SELECT
+			COALESCE(ce.utm_source, '') as utm_source,
+			COALESCE(ce.utm_medium, '') as utm_medium,
+			COALESCE(ce.utm_campaign, '') as utm_campaign,
+			COUNT(*) as clicks
+		FROM click_events ce
+		JOIN links l ON ce.link_id = l.id
+		WHERE `+scope+periodFilter+` AND (ce.utm_source IS NOT NULL OR ce.utm_medium IS NOT NULL OR ce.utm_campaign IS NOT NULL)
+		GROUP BY 1, 2, 3
+		ORDER BY clicks DESC
+		LIMIT $4
+	`, scopeArg, period.From, period.To, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	topCampaigns := make([]models.CampaignStats, 0)
+	for rows.Next() {
+		var data models.CampaignStats
+		if err := rows.Scan(&data.Source, &data.Medium, &data.Campaign, &data.Clicks); err == nil {
+			topCampaigns = append(topCampaigns, data)
+		}
+	}
+	return topCampaigns, rows.Err()
+}
+
 func formatHour(hour int) string {
 	period := "AM"
 	displayHour := hour
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"url-shortener-api/models"
+	"url-shortener-api/screening"
+	"url-shortener-api/targeting"
+	"url-shortener-api/views"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unsafeAppSchemes can run code in the browser and are never accepted as app URLs
+var unsafeAppSchemes = map[string]bool{
+	"javascript": true,
+	"data":       true,
+	"vbscript":   true,
+	"file":       true,
+	"blob":       true,
+}
+
+// validateAppURL checks a deep link target, which may be a custom app scheme or a universal link
+func validateAppURL(raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Scheme == "" {
+		return errors.New("app URLs need a scheme, such as myapp:// or https://")
+	}
+	if unsafeAppSchemes[strings.ToLower(parsed.Scheme)] {
+		return errors.New("app URL scheme is not allowed")
+	}
+	return nil
+}
+
+// validateStoreURL checks an app store fallback. The bridge page sends visitors there from
+// script, so only plain http(s) URLs are accepted.
+func validateStoreURL(raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return errors.New("store URLs must be http(s) URLs")
+	}
+	return nil
+}
+
+// isWebURL reports whether a deep link target is an http(s) URL, which opens like any other destination
+func isWebURL(raw string) bool {
+	parsed, err := url.Parse(raw)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https")
+}
+
+// screenDeepLinkURLs screens the http(s) app and store URLs of a link, since mobile visitors
+// are sent to them instead of the destination. It returns the most severe verdict.
+func screenDeepLinkURLs(ctx context.Context, urls ...*string) screening.Verdict {
+	result := screening.Allow
+	for _, raw := range urls {
+		if raw == nil || *raw == "" || !isWebURL(*raw) {
+			continue
+		}
+		verdict := screening.Default.Screen(ctx, *raw)
+		if verdict.Blocked() {
+			return verdict
+		}
+		if verdict.Flagged() && !result.Flagged() {
+			result = verdict
+		}
+	}
+	return result
+}
+
+// deepLinkTarget returns the app and store URLs for the visitor's platform, if the link has any
+func deepLinkTarget(link *models.Link, userAgent string) (appURL string, storeURL string) {
+	os, _ := targeting.ParseUserAgent(userAgent)
+
+	var app, store *string
+	switch os {
+	case targeting.OSiOS:
+		app, store = link.IOSAppURL, link.IOSStoreURL
+	case targeting.OSAndroid:
+		app, store = link.AndroidAppURL, link.AndroidStoreURL
+	}
+
+	// Checked again here so rows saved before validation was tightened can't reach the bridge page
+	if app != nil && validateAppURL(*app) == nil {
+		appURL = *app
+	}
+	if store != nil && validateStoreURL(*store) == nil {
+		storeURL = *store
+	}
+	return appURL, storeURL
+}
+
+// serveDeepLink sends mobile visitors into the app when the link has a deep link for their
+// platform, and reports whether it handled the response. Universal and app links are plain
+// redirects, since the OS falls back to the website itself. Custom schemes get a bridge page
+// that tries the app and falls back to the store, or to the web destination.
+func serveDeepLink(c *gin.Context, link *models.Link, destination string) bool {
+	appURL, storeURL := deepLinkTarget(link, c.Request.UserAgent())
+	if appURL == "" {
+		return false
+	}
+
+	if isWebURL(appURL) {
+		c.Redirect(http.StatusFound, appURL)
+		return true
+	}
+
+	fallback := destination
+	if storeURL != "" {
+		fallback = storeURL
+	}
+
+	title := "Opening app"
+	if link.Name != nil && *link.Name != "" {
+		title = *link.Name
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.HTML(http.StatusOK, "deeplink.html", views.DeepLinkPage{
+		PageTitle: title,
+		Title:     title,
+		// Validated by validateAppURL when the link was saved
+		AppURL:      template.URL(appURL),
+		FallbackURL: fallback,
+	})
+	return true
+}
@@ -0,0 +1,477 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"url-shortener-api/db"
+	"url-shortener-api/middleware"
+	"url-shortener-api/models"
+	"url-shortener-api/screening"
+	"url-shortener-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// DomainResolver looks up ownership TXT records. Tests can replace it with a stub.
+var DomainResolver utils.TXTResolver = net.DefaultResolver
+
+// domainCacheTTL is how long host lookups are cached, including misses
+const domainCacheTTL = time.Minute
+
+// maxCachedMisses bounds the cache of hosts that are not custom domains, since any client can
+// send requests with made-up Host headers. The cache is emptied when it fills up.
+const maxCachedMisses = 1024
+
+// unverifiedDomainTTL is how long an unverified domain claim lasts. Until a domain is verified
+// other workspaces can claim it too, and expired claims can no longer be verified.
+const unverifiedDomainTTL = 7 * 24 * time.Hour
+
+var hostnamePattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+type cachedDomain struct {
+	domain    *models.Domain
+	expiresAt time.Time
+}
+
+var (
+	domainCacheMu sync.RWMutex
+	domainsByHost = map[string]cachedDomain{}
+	domainsByID   = map[uuid.UUID]cachedDomain{}
+	domainMisses  = map[string]time.Time{}
+)
+
+// requestHost returns the lowercase request host without a port
+func requestHost(c *gin.Context) string {
+	host := c.Request.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
+
+// lookupVerifiedDomain returns the verified custom domain for a host, or nil if there is none
+func lookupVerifiedDomain(host string) (*models.Domain, error) {
+	now := time.Now()
+	domainCacheMu.RLock()
+	cached, ok := domainsByHost[host]
+	missExpiresAt, missed := domainMisses[host]
+	domainCacheMu.RUnlock()
+	if ok && now.Before(cached.expiresAt) {
+		return cached.domain, nil
+	}
+	if missed && now.Before(missExpiresAt) {
+		return nil, nil
+	}
+
+	var domain models.Domain
+	err := db.DB.Get(&domain, "SELECT * FROM domains WHERE hostname = $1 AND verified_at IS NOT NULL", host)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	domainCacheMu.Lock()
+	defer domainCacheMu.Unlock()
+	if err == sql.ErrNoRows {
+		if len(domainMisses) >= maxCachedMisses {
+			domainMisses = map[string]time.Time{}
+		}
+		domainMisses[host] = now.Add(domainCacheTTL)
+		return nil, nil
+	}
+
+	entry := cachedDomain{domain: &domain, expiresAt: now.Add(domainCacheTTL)}
+	domainsByHost[host] = entry
+	domainsByID[domain.ID] = entry
+	return entry.domain, nil
+}
+
+// lookupDomainByID returns a domain by ID using the cache
+func lookupDomainByID(id uuid.UUID) (*models.Domain, error) {
+	domainCacheMu.RLock()
+	cached, ok := domainsByID[id]
+	domainCacheMu.RUnlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.domain, nil
+	}
+
+	var domain models.Domain
+	if err := db.DB.Get(&domain, "SELECT * FROM domains WHERE id = $1", id); err != nil {
+		return nil, err
+	}
+
+	domainCacheMu.Lock()
+	domainsByID[id] = cachedDomain{domain: &domain, expiresAt: time.Now().Add(domainCacheTTL)}
+	domainCacheMu.Unlock()
+
+	return &domain, nil
+}
+
+// invalidateDomainCache drops a domain from the host and ID caches
+func invalidateDomainCache(domain *models.Domain) {
+	domainCacheMu.Lock()
+	delete(domainsByHost, domain.Hostname)
+	delete(domainMisses, domain.Hostname)
+	delete(domainsByID, domain.ID)
+	domainCacheMu.Unlock()
+}
+
+// IsCustomDomainHost reports whether host is a verified custom short domain
+func IsCustomDomainHost(host string) bool {
+	domain, err := lookupVerifiedDomain(strings.ToLower(host))
+	return err == nil && domain != nil
+}
+
+// requestDomain returns the custom domain the request was made on, or nil for the default domain
+func requestDomain(c *gin.Context) (*models.Domain, error) {
+	return lookupVerifiedDomain(requestHost(c))
+}
+
+// findLinkBySlug loads a link by slug on a custom domain, or on the default domain when domain is nil
+func findLinkBySlug(domain *models.Domain, slug string) (models.Link, error) {
+	var link models.Link
+	if domain != nil {
+		err := db.DB.Get(&link, "SELECT * FROM links WHERE domain_id = $1 AND slug = $2", domain.ID, slug)
+		return link, err
+	}
+	err := db.DB.Get(&link, "SELECT * FROM links WHERE domain_id IS NULL AND slug = $1", slug)
+	return link, err
+}
+
+// linkShortURL builds the public short URL for a link, using its custom domain when it has one
+func linkShortURL(link *models.Link) string {
+	return domainShortURL(link.DomainID, link.Slug)
+}
+
+// domainShortURL builds the short URL for a slug on a custom domain, or on the default domain when domainID is nil
+func domainShortURL(domainID *uuid.UUID, slug string) string {
+	if domainID == nil {
+		return utils.ShortURL(slug)
+	}
+
+	domain, err := lookupDomainByID(*domainID)
+	if err != nil || domain == nil {
+		return utils.ShortURL(slug)
+	}
+	return fmt.Sprintf("https://%s/%s", domain.Hostname, slug)
+}
+
+// withVerificationRecord fills in the DNS record the owner needs to publish
+func withVerificationRecord(domain models.Domain) models.Domain {
+	domain.VerificationRecord = &models.DNSRecord{
+		Type:  "TXT",
+		Name:  utils.DomainVerificationName(domain.Hostname),
+		Value: utils.DomainVerificationValue(domain.VerificationToken),
+	}
+	return domain
+}
+
+// loadDomainForRole loads the :id domain and checks the caller's role in its workspace.
+// It writes an error response and returns nil if access is denied.
+func loadDomainForRole(c *gin.Context, min string) *models.Domain {
+	userID := middleware.GetUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return nil
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid domain ID format"})
+		return nil
+	}
+
+	var domain models.Domain
+	err = db.DB.Get(&domain, "SELECT * FROM domains WHERE id = $1", id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Domain not found or access denied"})
+		return nil
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return nil
+	}
+
+	role, err := workspaceRole(domain.WorkspaceID, *userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return nil
+	}
+	if role == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Domain not found or access denied"})
+		return nil
+	}
+	if !roleAtLeast(role, min) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient workspace role"})
+		return nil
+	}
+
+	return &domain
+}
+
+// CreateDomain registers a custom short domain pending DNS verification
+func CreateDomain(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	var req models.CreateDomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	hostname := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(req.Hostname)), ".")
+	if !hostnamePattern.MatchString(hostname) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hostname"})
+		return
+	}
+	if hostname == utils.ShortDomain() || hostname == utils.GetDomainFromURL(utils.AppConfig.BaseURL) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This hostname is reserved"})
+		return
+	}
+
+	// Domains belong to the requested workspace or the user's personal one
+	var workspaceID uuid.UUID
+	if req.WorkspaceID != nil {
+		role, err := workspaceRole(*req.WorkspaceID, *userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if !roleAtLeast(role, models.RoleAdmin) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient workspace role"})
+			return
+		}
+		workspaceID = *req.WorkspaceID
+	} else {
+		personalID, err := personalWorkspaceID(*userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		workspaceID = personalID
+	}
+
+	token, err := utils.GenerateToken(16)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create domain"})
+		return
+	}
+
+	// Expired claims no longer hold a hostname
+	_, err = db.DB.Exec("DELETE FROM domains WHERE hostname = $1 AND verified_at IS NULL AND created_at < $2",
+		hostname, time.Now().Add(-unverifiedDomainTTL))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create domain"})
+		return
+	}
+
+	var verified bool
+	err = db.DB.Get(&verified, "SELECT EXISTS (SELECT 1 FROM domains WHERE hostname = $1 AND verified_at IS NOT NULL)", hostname)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create domain"})
+		return
+	}
+	if verified {
+		c.JSON(http.StatusConflict, gin.H{"error": "Domain is already registered"})
+		return
+	}
+
+	domain := models.Domain{
+		ID:                uuid.New(),
+		Hostname:          hostname,
+		WorkspaceID:       workspaceID,
+		CreatedBy:         userID,
+		VerificationToken: token,
+		CreatedAt:         time.Now(),
+	}
+
+	_, err = db.DB.Exec(`
+		INSERT INTO domains (id, hostname, workspace_id, created_by, verification_token, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, domain.ID, domain.Hostname, domain.WorkspaceID, domain.CreatedBy, domain.VerificationToken, domain.CreatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Domain is already registered"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create domain"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, withVerificationRecord(domain))
+}
+
+// GetDomains lists the custom domains of every workspace the user belongs to
+func GetDomains(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	var domains []models.Domain
+	err := db.DB.Select(&domains, `
+		SELECT d.* FROM domains d
+		JOIN workspace_members m ON m.workspace_id = d.workspace_id
+		WHERE m.user_id = $1
+		ORDER BY d.created_at
+	`, *userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve domains"})
+		return
+	}
+
+	response := make([]models.Domain, len(domains))
+	for i, domain := range domains {
+		response[i] = withVerificationRecord(domain)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// VerifyDomain checks the domain's ownership TXT record and marks it verified
+func VerifyDomain(c *gin.Context) {
+	domain := loadDomainForRole(c, models.RoleAdmin)
+	if domain == nil {
+		return
+	}
+
+	if domain.VerifiedAt != nil {
+		c.JSON(http.StatusOK, withVerificationRecord(*domain))
+		return
+	}
+
+	if time.Since(domain.CreatedAt) > unverifiedDomainTTL {
+		c.JSON(http.StatusGone, gin.H{"error": "Verification period has expired. Remove the domain and add it again."})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	verified, err := utils.VerifyDomainTXT(ctx, DomainResolver, domain.Hostname, domain.VerificationToken)
+	if err != nil || !verified {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":              "Verification record not found",
+			"verificationRecord": withVerificationRecord(*domain).VerificationRecord,
+		})
+		return
+	}
+
+	// Only one workspace can hold a verified hostname; the unique index settles races
+	now := time.Now()
+	if _, err := db.DB.Exec("UPDATE domains SET verified_at = $1 WHERE id = $2", now, domain.ID); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Domain has already been verified by another workspace"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify domain"})
+		return
+	}
+	if _, err := db.DB.Exec("DELETE FROM domains WHERE hostname = $1 AND verified_at IS NULL", domain.Hostname); err != nil {
+		fmt.Printf("Error removing other claims of %s: %v\n", domain.Hostname, err)
+	}
+	domain.VerifiedAt = &now
+	invalidateDomainCache(domain)
+
+	c.JSON(http.StatusOK, withVerificationRecord(*domain))
+}
+
+// validRedirectURL reports whether raw is an absolute http(s) URL, the only kind a domain redirects to
+func validRedirectURL(raw string) bool {
+	parsed, err := url.Parse(raw)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// UpdateDomain changes a domain's default redirect and not-found settings
+func UpdateDomain(c *gin.Context) {
+	domain := loadDomainForRole(c, models.RoleAdmin)
+	if domain == nil {
+		return
+	}
+
+	var req models.UpdateDomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	// Empty strings clear a setting. Visitors are redirected to these URLs, so they are held
+	// to the same screening as link destinations.
+	for _, setting := range []struct {
+		value  *string
+		target **string
+	}{
+		{req.DefaultRedirectURL, &domain.DefaultRedirectURL},
+		{req.NotFoundURL, &domain.NotFoundURL},
+	} {
+		if setting.value == nil {
+			continue
+		}
+		value := strings.TrimSpace(*setting.value)
+		if value == "" {
+			*setting.target = nil
+			continue
+		}
+		if !validRedirectURL(value) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Redirect URLs must be http or https URLs"})
+			return
+		}
+		verdict := screening.Default.Screen(c.Request.Context(), value)
+		if verdict.Blocked() {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Destination URL is not allowed", "reason": verdict.Reason})
+			return
+		}
+		*setting.target = &value
+	}
+
+	_, err := db.DB.Exec(
+		"UPDATE domains SET default_redirect_url = $1, not_found_url = $2 WHERE id = $3",
+		domain.DefaultRedirectURL, domain.NotFoundURL, domain.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update domain"})
+		return
+	}
+	invalidateDomainCache(domain)
+
+	c.JSON(http.StatusOK, withVerificationRecord(*domain))
+}
+
+// DeleteDomain removes a custom domain along with the links on it
+func DeleteDomain(c *gin.Context) {
+	domain := loadDomainForRole(c, models.RoleAdmin)
+	if domain == nil {
+		return
+	}
+
+	if _, err := db.DB.Exec("DELETE FROM domains WHERE id = $1", domain.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete domain"})
+		return
+	}
+	invalidateDomainCache(domain)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Domain deleted successfully"})
+}
+
+// DomainRoot handles requests to the bare short domain, using the custom domain's default redirect if set
+func DomainRoot(c *gin.Context) {
+	domain, err := requestDomain(c)
+	// Checked again here so settings saved before validation was tightened are never served
+	if err == nil && domain != nil && domain.DefaultRedirectURL != nil && validRedirectURL(*domain.DefaultRedirectURL) {
+		c.Redirect(http.StatusFound, *domain.DefaultRedirectURL)
+		return
+	}
+	respondLinkNotFound(c)
+}
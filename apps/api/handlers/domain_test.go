@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+	"url-shortener-api/middleware"
+	"url-shortener-api/models"
+	"url-shortener-api/screening"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// hostBlocker blocks URLs on a single host
+type hostBlocker string
+
+func (h hostBlocker) Name() string { return "test" }
+
+func (h hostBlocker) Check(_ context.Context, u *url.URL) (screening.Verdict, error) {
+	if u.Hostname() == string(h) {
+		return screening.Verdict{Action: screening.ActionBlock, Reason: "blocked host"}, nil
+	}
+	return screening.Allow, nil
+}
+
+func TestUpdateDomainRedirects(t *testing.T) {
+	previous := screening.Default
+	screening.Default = &screening.Pipeline{Checkers: []screening.Checker{hostBlocker("evil.example")}}
+	t.Cleanup(func() { screening.Default = previous })
+
+	domainID := uuid.MustParse("dddddddd-dddd-dddd-dddd-dddddddddddd")
+	respond := func(query string, args []driver.Value) fakeResult {
+		switch {
+		case strings.Contains(query, "suspended_at IS NOT NULL FROM users"):
+			return row([]string{"suspended"}, false)
+		case strings.Contains(query, "SELECT * FROM domains"):
+			return row([]string{"id", "hostname", "workspace_id", "verification_token", "default_redirect_url", "not_found_url", "created_at"},
+				domainID.String(), "go.example.com", uuid.NewString(), "token", "https://example.com/home", "https://example.com/404", time.Now())
+		case strings.Contains(query, "SELECT role FROM workspace_members"):
+			return row([]string{"role"}, models.RoleAdmin)
+		}
+		return fakeResult{affected: 1}
+	}
+
+	tests := []struct {
+		name         string
+		body         string
+		want         int
+		wantRedirect driver.Value
+		wantNotFound driver.Value
+	}{
+		{"clear both", `{"defaultRedirectUrl":"","notFoundUrl":""}`, http.StatusOK, nil, nil},
+		{"clear one", `{"notFoundUrl":"  "}`, http.StatusOK, "https://example.com/home", nil},
+		{"set", `{"defaultRedirectUrl":"http://example.org/landing?ref=go"}`, http.StatusOK, "http://example.org/landing?ref=go", "https://example.com/404"},
+		{"nothing", `{}`, http.StatusOK, "https://example.com/home", "https://example.com/404"},
+		{"script", `{"defaultRedirectUrl":"javascript:alert(1)"}`, http.StatusBadRequest, nil, nil},
+		{"data", `{"notFoundUrl":"data:text/html,<h1>hi</h1>"}`, http.StatusBadRequest, nil, nil},
+		{"other scheme", `{"notFoundUrl":"ftp://example.com/file"}`, http.StatusBadRequest, nil, nil},
+		{"relative", `{"defaultRedirectUrl":"/home"}`, http.StatusBadRequest, nil, nil},
+		{"no host", `{"defaultRedirectUrl":"https://"}`, http.StatusBadRequest, nil, nil},
+		{"screened", `{"notFoundUrl":"https://evil.example/login"}`, http.StatusUnprocessableEntity, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFakeDB(t, respond)
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.PATCH("/api/domains/:id", middleware.JWTAuth(), UpdateDomain)
+
+			w := serve(r, http.MethodPatch, "/api/domains/"+domainID.String(), testToken(t, middleware.JWTClaims{UserID: testUserID}), tt.body)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+
+			updates := fake.executed("UPDATE domains")
+			if tt.want != http.StatusOK {
+				if len(updates) != 0 {
+					t.Errorf("rejected settings were saved: %v", updates)
+				}
+				return
+			}
+			if len(updates) != 1 {
+				t.Fatalf("domain updated %d times, want once", len(updates))
+			}
+			if args := updates[0].args; args[0] != tt.wantRedirect || args[1] != tt.wantNotFound {
+				t.Errorf("saved %v, %v, want %v, %v", args[0], args[1], tt.wantRedirect, tt.wantNotFound)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"url-shortener-api/db"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// fakeResult is what the fake database answers to one statement
+type fakeResult struct {
+	columns  []string
+	rows     [][]driver.Value
+	affected int64
+	err      error
+}
+
+// fakeStatement is a statement the fake database received
+type fakeStatement struct {
+	query string
+	args  []driver.Value
+}
+
+// fakeDB stands in for Postgres in handler tests. respond answers each statement;
+// statements it doesn't know get an empty result.
+type fakeDB struct {
+	respond func(query string, args []driver.Value) fakeResult
+
+	mu         sync.Mutex
+	statements []fakeStatement
+}
+
+// useFakeDB points db.DB at a fake database for the duration of the test
+func useFakeDB(t *testing.T, respond func(query string, args []driver.Value) fakeResult) *fakeDB {
+	t.Helper()
+	fake := &fakeDB{respond: respond}
+	previous := db.DB
+	db.DB = sqlx.NewDb(sql.OpenDB(fake), "postgres")
+	t.Cleanup(func() {
+		db.DB.Close()
+		db.DB = previous
+	})
+	return fake
+}
+
+// executed returns the statements received so far that contain fragment
+func (f *fakeDB) executed(fragment string) []fakeStatement {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var matching []fakeStatement
+	for _, statement := range f.statements {
+		if strings.Contains(statement.query, fragment) {
+			matching = append(matching, statement)
+		}
+	}
+	return matching
+}
+
+func (f *fakeDB) run(query string, named []driver.NamedValue) fakeResult {
+	args := make([]driver.Value, len(named))
+	for i, arg := range named {
+		args[i] = arg.Value
+	}
+	f.mu.Lock()
+	f.statements = append(f.statements, fakeStatement{query: query, args: args})
+	f.mu.Unlock()
+	if f.respond == nil {
+		return fakeResult{}
+	}
+	return f.respond(query, args)
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return fakeDriver{f} }
+
+type fakeDriver struct{ db *fakeDB }
+
+func (d fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{d.db}, nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.db, query}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { return fakeTx{}, nil }
+
+func (c fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	result := c.db.run(query, args)
+	if result.err != nil {
+		return nil, result.err
+	}
+	return &fakeRows{columns: result.columns, rows: result.rows}, nil
+}
+
+func (c fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	result := c.db.run(query, args)
+	if result.err != nil {
+		return nil, result.err
+	}
+	return driver.RowsAffected(result.affected), nil
+}
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return fakeConn{s.db}.ExecContext(context.Background(), s.query, named(args))
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return fakeConn{s.db}.QueryContext(context.Background(), s.query, named(args))
+}
+
+func named(args []driver.Value) []driver.NamedValue {
+	values := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		values[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return values
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// row answers a query with a single row
+func row(columns []string, values ...driver.Value) fakeResult {
+	return fakeResult{columns: columns, rows: [][]driver.Value{values}}
+}
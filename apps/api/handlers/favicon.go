@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+	"url-shortener-api/favicons"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetFavicon serves a destination host's stored favicon. Icons are content-addressed by
+// hash, so clients can cache them for a long time and revalidate with If-None-Match.
+func GetFavicon(c *gin.Context) {
+	favicon, err := favicons.Lookup(c.Request.Context(), c.Param("domain"))
+	if err == sql.ErrNoRows {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusNotFound, gin.H{"error": "Favicon not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	etag := `"` + favicon.Hash + `"`
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "public, max-age=86400, stale-while-revalidate=604800")
+	c.Header("Last-Modified", favicon.FetchedAt.UTC().Format(http.TimeFormat))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Security-Policy", "default-src 'none'")
+
+	for _, candidate := range strings.Split(c.GetHeader("If-None-Match"), ",") {
+		if strings.TrimSpace(candidate) == etag {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+
+	c.Data(http.StatusOK, favicon.ContentType, favicon.Data)
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+	"url-shortener-api/db"
+	"url-shortener-api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// linkHealthChecks is how many recent checks GetLinkHealth returns
+const linkHealthChecks = 20
+
+// GetLinkHealth returns a link's destination health and its most recent checks
+func GetLinkHealth(c *gin.Context) {
+	link := loadLinkForRole(c, models.RoleViewer)
+	if link == nil {
+		return
+	}
+
+	health := models.LinkHealth{
+		Status:     link.HealthStatus,
+		StatusCode: link.HealthStatusCode,
+		Failures:   link.HealthFailures,
+		CheckedAt:  link.HealthCheckedAt,
+		Checks:     []models.LinkCheck{},
+	}
+
+	err := db.DB.Select(&health.Checks, `
+		SELECT * FROM link_checks
+		WHERE link_id = $1
+		ORDER BY checked_at DESC
+		LIMIT $2
+	`, link.ID, linkHealthChecks)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, health)
+}
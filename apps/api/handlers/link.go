@@ -1,16 +1,23 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"math/rand"
 	"net/http"
 	"strings"
 	"time"
 	"url-shortener-api/db"
+	"url-shortener-api/favicons"
 	"url-shortener-api/middleware"
 	"url-shortener-api/models"
+	"url-shortener-api/policy"
+	"url-shortener-api/screening"
+	"url-shortener-api/targeting"
 	"url-shortener-api/utils"
+	"url-shortener-api/webhooks"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -20,10 +27,50 @@ import (
 
 const slugCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 
+// linkListColumns are the link columns returned when listing links. Secrets such as the
+// password hash and management token hash are deliberately left out.
+const linkListColumns = "id, name, slug, original, clicks, created_at, last_updated, expires_at, active_from, user_id, favicon_url, disabled, workspace_id, flagged_reason, screened_at, blocked_at, blocked_reason, preview_mode, domain_id, max_clicks, one_time, forward_query, query_precedence, forward_path, utm_template_id, ios_app_url, ios_store_url, android_app_url, android_store_url, meta_title, meta_description, meta_image, meta_site_name, metadata_fetched_at, og_title, og_description, og_image, health_status, health_status_code, health_failures, health_checked_at"
+
+// managementTokenHeader carries the secret(s) that let anonymous creators manage their links
+const managementTokenHeader = "X-Management-Token"
+
 func init() {
 	rand.Seed(time.Now().UnixNano())
 }
 
+// managementTokenHashes returns the hashes of the management tokens sent with the request.
+// Several tokens may be sent as a comma-separated list.
+func managementTokenHashes(c *gin.Context) []string {
+	hashes := []string{}
+	for _, token := range strings.Split(c.GetHeader(managementTokenHeader), ",") {
+		token = strings.TrimSpace(token)
+		if token != "" {
+			hashes = append(hashes, utils.HashToken(token))
+		}
+	}
+	return hashes
+}
+
+// reservedSlugs are root-level paths that can never be used as slugs
+var reservedSlugs = map[string]bool{
+	"api":         true,
+	"ping":        true,
+	"health":      true,
+	"ready":       true,
+	"admin":       true,
+	"static":      true,
+	"assets":      true,
+	"favicon.ico": true,
+	"robots.txt":  true,
+	"sitemap.xml": true,
+	".well-known": true,
+}
+
+// isReservedSlug reports whether a slug collides with a reserved root-level path
+func isReservedSlug(slug string) bool {
+	return reservedSlugs[strings.ToLower(slug)]
+}
+
 func generateSlug(length int) string {
 	b := make([]byte, length)
 	for i := range b {
@@ -32,6 +79,90 @@ func generateSlug(length int) string {
 	return string(b)
 }
 
+// verifyLinkPassword checks a password against a protected link, tracking failures per link
+// and per client IP. A non-zero duration means the caller is locked out and the password
+// was not checked.
+func verifyLinkPassword(c *gin.Context, link *models.Link, password string) (bool, time.Duration, error) {
+	// Slugs are only unique per domain, so failures are keyed by link ID
+	slugKey := attemptKey{Scope: scopeSlug, Key: link.ID.String()}
+	ipKey := attemptKey{Scope: scopeIP, Key: c.ClientIP()}
+
+	remaining, err := beginAttempt(slugKey, ipKey)
+	if err != nil {
+		return false, 0, err
+	}
+	if remaining > 0 {
+		writeAuditLog(c, "link_password", link.Slug, false, "locked out")
+		return false, remaining, nil
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(*link.Password), []byte(password)); err != nil {
+		writeAuditLog(c, "link_password", link.Slug, false, "invalid password")
+		return false, 0, nil
+	}
+
+	// The right password forgives the link's failures; the client IP only gets this attempt back
+	clearFailedAttempts(slugKey)
+	releaseAttempt(ipKey)
+	return true, 0, nil
+}
+
+// loadLinkForRole loads the :id link if the caller has at least the given workspace role on it,
+// or sent the link's management token. It writes an error response and returns nil otherwise.
+func loadLinkForRole(c *gin.Context, min string) *models.Link {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid link ID format"})
+		return nil
+	}
+
+	var link models.Link
+	if userID := middleware.GetUserID(c); userID != nil {
+		role, err := linkRole(id, *userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return nil
+		}
+		if role == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Link not found or access denied"})
+			return nil
+		}
+		if !roleAtLeast(role, min) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient workspace role"})
+			return nil
+		}
+		err = db.DB.Get(&link, "SELECT * FROM links WHERE id = $1", id)
+	} else {
+		err = db.DB.Get(&link, "SELECT * FROM links WHERE id = $1 AND user_id IS NULL AND management_token_hash = ANY($2)",
+			id, pq.Array(managementTokenHashes(c)))
+	}
+
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Link not found or access denied"})
+		return nil
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return nil
+	}
+
+	return &link
+}
+
+// isClickLimited reports whether a link can only be followed a limited number of times
+func isClickLimited(link *models.Link) bool {
+	return link.MaxClicks != nil || link.OneTime
+}
+
+// isLinkDisabled reports whether a link was disabled by its owner or blocked by an admin or screening
+func isLinkDisabled(link *models.Link) bool {
+	return link.Disabled || link.BlockedAt != nil
+}
+
+// clickLimitReached reports whether a link has been followed as often as its click limit allows
+func clickLimitReached(link *models.Link) bool {
+	return link.MaxClicks != nil && link.Clicks >= *link.MaxClicks
+}
+
 // CreateLink handles the creation of a new short link
 func CreateLink(c *gin.Context) {
 	var req models.CreateLinkRequest
@@ -40,7 +171,70 @@ func CreateLink(c *gin.Context) {
 		return
 	}
 
-	// Generate a unique slug
+	// App URLs may use custom schemes, but never ones that run script
+	for _, appURL := range []*string{req.IOSAppURL, req.AndroidAppURL} {
+		if appURL == nil || *appURL == "" {
+			continue
+		}
+		if err := validateAppURL(*appURL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	for _, storeURL := range []*string{req.IOSStoreURL, req.AndroidStoreURL} {
+		if storeURL == nil || *storeURL == "" {
+			continue
+		}
+		if err := validateStoreURL(*storeURL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if req.OGImage != nil && *req.OGImage != "" {
+		if err := validateCardImage(*req.OGImage); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// Screen the destination for malicious or internal URLs
+	verdict := screening.Default.Screen(c.Request.Context(), req.URL)
+	if verdict.Blocked() {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Destination URL is not allowed", "reason": verdict.Reason})
+		return
+	}
+	var flaggedReason *string
+	if verdict.Flagged() {
+		flaggedReason = &verdict.Reason
+	}
+
+	// Mobile visitors may be sent to http(s) app or store URLs instead, so they are screened too
+	deepLinkVerdict := screenDeepLinkURLs(c.Request.Context(), req.IOSAppURL, req.IOSStoreURL, req.AndroidAppURL, req.AndroidStoreURL)
+	if deepLinkVerdict.Blocked() {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Deep link URL is not allowed", "reason": deepLinkVerdict.Reason})
+		return
+	}
+	if deepLinkVerdict.Flagged() && flaggedReason == nil {
+		flaggedReason = &deepLinkVerdict.Reason
+	}
+	screenedAt := time.Now()
+
+	// Links on a custom domain need a verified domain
+	var domain *models.Domain
+	if req.DomainID != nil {
+		d, err := lookupDomainByID(*req.DomainID)
+		if err == sql.ErrNoRows || (err == nil && d.VerifiedAt == nil) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Domain not found or not verified"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		domain = d
+	}
+
+	// Generate a slug that is unique on the link's domain
 	var slug string
 	var link models.Link
 	maxAttempts := 10
@@ -49,11 +243,11 @@ func CreateLink(c *gin.Context) {
 		slug = generateSlug(6)
 
 		// Check if slug already exists
-		err := db.DB.Get(&link, "SELECT id FROM links WHERE slug = $1", slug)
-		if err == sql.ErrNoRows {
+		_, err := findLinkBySlug(domain, slug)
+		if err == sql.ErrNoRows && !isReservedSlug(slug) {
 			// Slug is unique, we can use it
 			break
-		} else if err != nil {
+		} else if err != nil && err != sql.ErrNoRows {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 			return
 		}
@@ -67,6 +261,69 @@ func CreateLink(c *gin.Context) {
 	// Get user ID from context (set by JWT middleware)
 	userID := middleware.GetUserID(c)
 
+	// Authenticated links belong to a workspace the user can edit
+	var workspaceID *uuid.UUID
+	if userID != nil {
+		if req.WorkspaceID != nil {
+			role, err := workspaceRole(*req.WorkspaceID, *userID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+				return
+			}
+			if !roleAtLeast(role, models.RoleEditor) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient workspace role"})
+				return
+			}
+			workspaceID = req.WorkspaceID
+		} else {
+			personalID, err := personalWorkspaceID(*userID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+				return
+			}
+			workspaceID = &personalID
+		}
+	} else if req.WorkspaceID != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required to create workspace links"})
+		return
+	} else if err := policy.Anonymous.Apply(c.Request.Context(), &req, c.ClientIP()); err != nil {
+		switch {
+		case errors.Is(err, policy.ErrAnonymousDisabled):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required to create links"})
+		case errors.Is(err, policy.ErrVerificationFailed):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Verification failed", "verificationRequired": true})
+		case errors.Is(err, policy.ErrDomainNotAllowed), errors.Is(err, policy.ErrPasswordNotAllowed):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			fmt.Printf("Error applying anonymous link policy: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify request"})
+		}
+		return
+	}
+
+	// UTM templates are only available to signed-in users who can see the template
+	if req.UTMTemplateID != nil {
+		if userID == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required to use UTM templates"})
+			return
+		}
+		allowed, err := canUseUTMTemplate(*req.UTMTemplateID, *userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if !allowed {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "UTM template not found"})
+			return
+		}
+	}
+
+	// Custom domains can only be used by links in the domain's workspace
+	if domain != nil && (workspaceID == nil || *workspaceID != domain.WorkspaceID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Domain does not belong to this workspace"})
+		return
+	}
+
 	// Hash password if provided
 	var hashedPassword *string
 	if req.Password != nil && *req.Password != "" {
@@ -79,13 +336,31 @@ func CreateLink(c *gin.Context) {
 		hashedPassword = &hashStr
 	}
 
-	// Fetch favicon URL for the original URL
-	faviconURL := utils.FetchFaviconURL(req.URL)
-	var faviconPtr *string
-	if faviconURL != "" {
-		faviconPtr = &faviconURL
+	// Anonymous links get a management secret in place of an owner
+	var managementToken string
+	var managementTokenHash *string
+	if userID == nil {
+		token, err := utils.GenerateToken(24)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create link"})
+			return
+		}
+		hash := utils.HashToken(token)
+		managementToken = token
+		managementTokenHash = &hash
 	}
 
+	// One-time links can only be followed once
+	maxClicks := req.MaxClicks
+	if req.OneTime {
+		one := 1
+		maxClicks = &one
+	}
+
+	// Use the stored favicon of the destination host if we have one. Otherwise it is
+	// downloaded in the background and set on the link once fetched.
+	faviconPtr := favicons.Resolve(c.Request.Context(), utils.GetDomainFromURL(req.URL))
+
 	// Create the link
 	link = models.Link{
 		ID:          uuid.New(),
@@ -100,14 +375,48 @@ func CreateLink(c *gin.Context) {
 		Password:    hashedPassword,
 		UserID:      userID,
 		FaviconURL:  faviconPtr,
+		WorkspaceID: workspaceID,
+
+		ManagementTokenHash: managementTokenHash,
+		FlaggedReason:       flaggedReason,
+		ScreenedAt:          &screenedAt,
+		PreviewMode:         req.PreviewMode,
+		MaxClicks:           maxClicks,
+		OneTime:             req.OneTime,
+		ForwardQuery:        req.ForwardQuery,
+		QueryPrecedence:     PrecedenceDestination,
+		ForwardPath:         req.ForwardPath,
+		UTMTemplateID:       req.UTMTemplateID,
+		IOSAppURL:           emptyToNil(req.IOSAppURL),
+		IOSStoreURL:         emptyToNil(req.IOSStoreURL),
+		AndroidAppURL:       emptyToNil(req.AndroidAppURL),
+		AndroidStoreURL:     emptyToNil(req.AndroidStoreURL),
+		OGTitle:             emptyToNil(req.OGTitle),
+		OGDescription:       emptyToNil(req.OGDescription),
+		OGImage:             emptyToNil(req.OGImage),
+	}
+	if req.QueryPrecedence != "" {
+		link.QueryPrecedence = req.QueryPrecedence
+	}
+	if domain != nil {
+		link.DomainID = &domain.ID
 	}
 
 	query := `
-		INSERT INTO links (id, name, slug, original, clicks, created_at, last_updated, expires_at, active_from, password, user_id, favicon_url)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		INSERT INTO links (id, name, slug, original, clicks, created_at, last_updated, expires_at, active_from, password, user_id, favicon_url, workspace_id, management_token_hash, flagged_reason, screened_at, preview_mode, domain_id, max_clicks, one_time, forward_query, query_precedence, forward_path, utm_template_id, ios_app_url, ios_store_url, android_app_url, android_store_url, og_title, og_description, og_image)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31)
 	`
 
-	_, err := db.DB.Exec(query, link.ID, link.Name, link.Slug, link.Original, link.Clicks, link.CreatedAt, link.LastUpdated, link.ExpiresAt, link.ActiveFrom, link.Password, link.UserID, link.FaviconURL)
+	// The link.created event is queued in the same transaction, so it is sent if and only if
+	// the link is saved
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create link"})
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(query, link.ID, link.Name, link.Slug, link.Original, link.Clicks, link.CreatedAt, link.LastUpdated, link.ExpiresAt, link.ActiveFrom, link.Password, link.UserID, link.FaviconURL, link.WorkspaceID, link.ManagementTokenHash, link.FlaggedReason, link.ScreenedAt, link.PreviewMode, link.DomainID, link.MaxClicks, link.OneTime, link.ForwardQuery, link.QueryPrecedence, link.ForwardPath, link.UTMTemplateID, link.IOSAppURL, link.IOSStoreURL, link.AndroidAppURL, link.AndroidStoreURL, link.OGTitle, link.OGDescription, link.OGImage)
 	if err != nil {
 		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
 			c.JSON(http.StatusConflict, gin.H{"error": "Slug already exists"})
@@ -117,15 +426,27 @@ func CreateLink(c *gin.Context) {
 		return
 	}
 
-	// Get the base URL from environment or use a default
-	baseURL := utils.AppConfig.BaseURL
-	if baseURL == "" {
-		baseURL = fmt.Sprintf("http://localhost:%s", utils.AppConfig.Port)
+	// Title, description and icons are filled in once the destination has been scraped
+	if err := queueLinkMetadata(tx, link.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create link"})
+		return
 	}
+	if err := emitLinkEvent(c.Request.Context(), tx, models.EventLinkCreated, &link); err != nil {
+		fmt.Printf("Error queueing link.created event: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create link"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create link"})
+		return
+	}
+	webhooks.Notify()
+	wakeMetadataWorker()
 
 	response := models.CreateLinkResponse{
-		ShortURL: fmt.Sprintf("%s/%s", baseURL, slug),
-		Slug:     slug,
+		ShortURL:        linkShortURL(&link),
+		Slug:            slug,
+		ManagementToken: managementToken,
 	}
 
 	c.JSON(http.StatusCreated, response)
@@ -135,68 +456,147 @@ func CreateLink(c *gin.Context) {
 func RedirectLink(c *gin.Context) {
 	slug := c.Param("slug")
 
-	var link models.Link
-	err := db.DB.Get(&link, "SELECT * FROM links WHERE slug = $1", slug)
+	// Anything after the slug is only used by prefix links
+	extraPath := c.Param("path")
+	if extraPath == "/" {
+		extraPath = ""
+	}
+
+	// A trailing "+" asks for the preview page instead of a redirect
+	preview := strings.HasSuffix(slug, previewSuffix)
+	slug = strings.TrimSuffix(slug, previewSuffix)
+
+	if isReservedSlug(slug) {
+		respondLinkNotFound(c)
+		return
+	}
+
+	// The Host header selects a verified custom domain, otherwise the default short domain
+	domain, err := requestDomain(c)
+	if err != nil {
+		respondLinkError(c, http.StatusInternalServerError, "Database error",
+			"Something went wrong", "We couldn't look up this link. Please try again shortly.")
+		return
+	}
+
+	link, err := findLinkBySlug(domain, slug)
 	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Link not found"})
+		if domain != nil && domain.NotFoundURL != nil && validRedirectURL(*domain.NotFoundURL) {
+			c.Redirect(http.StatusFound, *domain.NotFoundURL)
+			return
+		}
+		respondLinkNotFound(c)
 		return
 	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		respondLinkError(c, http.StatusInternalServerError, "Database error",
+			"Something went wrong", "We couldn't look up this link. Please try again shortly.")
+		return
+	}
+
+	// Only prefix links accept a path after the slug
+	if extraPath != "" && !link.ForwardPath {
+		respondLinkNotFound(c)
 		return
 	}
 
-	// Check if link is active (activeFrom)
 	now := time.Now()
+	if preview {
+		renderPreview(c, &link, interstitialWarning(&link), linkStatus(&link, now))
+		return
+	}
+
+	// Check if link is active (activeFrom)
 	if link.ActiveFrom != nil && now.Before(*link.ActiveFrom) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Link is not yet active"})
+		respondLinkScheduled(c, *link.ActiveFrom)
 		return
 	}
 
-	// Check if link has expired
+	// Check if link has expired, by date or by reaching its click limit
 	if link.ExpiresAt != nil && now.After(*link.ExpiresAt) {
-		c.JSON(http.StatusGone, gin.H{"error": "Link has expired"})
+		respondLinkExpired(c)
+		return
+	}
+	if clickLimitReached(&link) {
+		respondLinkExpired(c)
 		return
 	}
 
 	// Check if link is disabled
-	if link.Disabled {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Link has been disabled"})
+	if isLinkDisabled(&link) {
+		respondLinkDisabled(c)
 		return
 	}
 
-	// Check password protection
+	// Link preview crawlers get the link's own social card rather than the destination
+	if serveSocialCard(c, &link) {
+		return
+	}
+
+	// Show the preview page or interstitial warning before leaving
+	if needsPreviewPage(c, &link) {
+		renderPreview(c, &link, interstitialWarning(&link), "")
+		return
+	}
+
+	// Check password protection. Browsers submit the password form back to this URL.
 	if link.Password != nil {
-		var req models.AccessLinkRequest
-		if err := c.ShouldBindJSON(&req); err != nil || req.Password == nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Password required", "passwordRequired": true})
+		password := submittedLinkPassword(c)
+		if password == nil {
+			respondPasswordRequired(c, &link, false)
 			return
 		}
 
-		err = bcrypt.CompareHashAndPassword([]byte(*link.Password), []byte(*req.Password))
+		valid, remaining, err := verifyLinkPassword(c, &link, *password)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password", "passwordRequired": true})
+			respondLinkError(c, http.StatusInternalServerError, "Database error",
+				"Something went wrong", "We couldn't check this password. Please try again shortly.")
+			return
+		}
+		if remaining > 0 {
+			respondLinkLocked(c, remaining)
+			return
+		}
+		if !valid {
+			respondPasswordRequired(c, &link, true)
 			return
 		}
 	}
 
 	clientIP := c.ClientIP()
 
-	// Check if this is a unique click (within the configured time window)
-	var existingClick int
-	timeWindow := time.Duration(utils.AppConfig.UniqueClickExpiry) * time.Hour
-	cutoffTime := now.Add(-timeWindow)
+	// Targeting rules may send this visitor somewhere other than the original URL
+	visitor := targeting.NewVisitor(c.Request, clientIP, now)
+	destination, ruleID, targeted := selectDestination(&link, visitor)
 
-	err = db.DB.Get(&existingClick,
-		"SELECT COUNT(*) FROM click_events WHERE link_id = $1 AND ip = $2 AND timestamp > $3",
-		link.ID, clientIP, cutoffTime)
+	// Without a matching rule, links in an A/B test split visitors across their variants
+	var variantID *uuid.UUID
+	if ruleID == nil {
+		if variant := assignVariant(c, &link); variant != nil {
+			destination, variantID, targeted = variant.Destination, &variant.ID, true
+		}
+	}
 
-	// isUniqueClick := (err != nil || existingClick == 0)
+	// Add the link's UTM template, then forward the visitor's query string and extra path
+	// segments if the link asks for it
+	destination = applyUTMTemplate(destination, linkUTMTemplate(&link))
+	destination = applyPassthrough(destination, &link, extraPath, c.Request.URL.RawQuery)
 
-	// Increment click count (always increment total)
-	_, err = db.DB.Exec("UPDATE links SET clicks = clicks + 1, last_updated = NOW() WHERE id = $1", link.ID)
+	// Increment click count (always increment total). The limit is checked in the same statement
+	// so concurrent requests can't follow a limited link more often than allowed.
+	result, err := db.DB.Exec(
+		"UPDATE links SET clicks = clicks + 1, last_updated = NOW() WHERE id = $1 AND (max_clicks IS NULL OR clicks < max_clicks)",
+		link.ID)
 	if err != nil {
+		if link.MaxClicks != nil {
+			respondLinkError(c, http.StatusInternalServerError, "Database error",
+				"Something went wrong", "We couldn't open this link. Please try again shortly.")
+			return
+		}
 		// Log error but don't stop the redirect
 		fmt.Printf("Error updating click count: %v\n", err)
+	} else if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		respondLinkExpired(c)
+		return
 	}
 
 	// Record click event
@@ -207,18 +607,42 @@ func RedirectLink(c *gin.Context) {
 		Timestamp: now,
 		IP:        &clientIP,
 		Device:    &userAgent,
+		RuleID:    ruleID,
+		VariantID: variantID,
+		Source:    clickSource(c.Request.URL.Query()),
+	}
+	clickEvent.UTMSource, clickEvent.UTMMedium, clickEvent.UTMCampaign = campaignValues(destination)
+	if visitor.Country != "" {
+		clickEvent.Country = &visitor.Country
 	}
 
-	_, err = db.DB.Exec(
-		"INSERT INTO click_events (id, link_id, timestamp, ip, device) VALUES ($1, $2, $3, $4, $5)",
-		clickEvent.ID, clickEvent.LinkID, clickEvent.Timestamp, clickEvent.IP, clickEvent.Device,
-	)
-	if err != nil {
-		// Log error but don't stop the redirect
-		fmt.Printf("Error recording click event: %v\n", err)
+	// The click is written in the background, along with its webhook and live stream events
+	queueClick(context.Background(), &link, &clickEvent, destination)
+
+	// Mobile visitors go into the app when the link has a deep link for their platform
+	if serveDeepLink(c, &link, destination) {
+		return
 	}
 
-	c.Redirect(http.StatusMovedPermanently, link.Original)
+	// A POSTed password form must be followed with a GET to the destination
+	if c.Request.Method == http.MethodPost {
+		c.Redirect(http.StatusSeeOther, destination)
+		return
+	}
+
+	// Browsers cache permanent redirects, which would pin visitors to one rule's or variant's
+	// destination, or let them keep following a limited link after its last click
+	if isClickLimited(&link) {
+		c.Header("Cache-Control", "no-store")
+		c.Redirect(http.StatusFound, destination)
+		return
+	}
+	if targeted {
+		c.Redirect(http.StatusFound, destination)
+		return
+	}
+
+	c.Redirect(http.StatusMovedPermanently, destination)
 }
 
 // GetLinks retrieves user-specific links (for dashboard)
@@ -231,24 +655,47 @@ func GetLinks(c *gin.Context) {
 	var query string
 	var err error
 
-	if userID != nil {
-		// If authenticated, show only user's links
+	if userID != nil && c.Query("workspaceId") != "" {
+		// Restrict to a single workspace the user is a member of
+		workspaceID, parseErr := uuid.Parse(c.Query("workspaceId"))
+		if parseErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID format"})
+			return
+		}
+		role, roleErr := workspaceRole(workspaceID, *userID)
+		if roleErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if role == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Workspace not found or access denied"})
+			return
+		}
 		query = `
-			SELECT id, name, slug, original, clicks, created_at, last_updated, expires_at, active_from, user_id, favicon_url, disabled
+			SELECT ` + linkListColumns + `
 			FROM links
-			WHERE user_id = $1
+			WHERE workspace_id = $1
+			ORDER BY created_at DESC
+		`
+		err = db.DB.Select(&links, query, workspaceID)
+	} else if userID != nil {
+		// If authenticated, show links from every workspace the user belongs to
+		query = `
+			SELECT ` + linkListColumns + `
+			FROM links
+			WHERE workspace_id IN (SELECT workspace_id FROM workspace_members WHERE user_id = $1)
 			ORDER BY created_at DESC
 		`
 		err = db.DB.Select(&links, query, *userID)
 	} else {
-		// If not authenticated, show only anonymous links (for backward compatibility)
+		// If not authenticated, show only the anonymous links whose management tokens were sent
 		query = `
-			SELECT id, name, slug, original, clicks, created_at, last_updated, expires_at, active_from, user_id, favicon_url, disabled
+			SELECT ` + linkListColumns + `
 			FROM links
-			WHERE user_id IS NULL
+			WHERE user_id IS NULL AND management_token_hash = ANY($1)
 			ORDER BY created_at DESC
 		`
-		err = db.DB.Select(&links, query)
+		err = db.DB.Select(&links, query, pq.Array(managementTokenHashes(c)))
 	}
 
 	if err != nil {
@@ -257,10 +704,6 @@ func GetLinks(c *gin.Context) {
 	}
 
 	// Generate short URLs and calculate unique clicks
-	baseURL := utils.AppConfig.BaseURL
-	if baseURL == "" {
-		baseURL = fmt.Sprintf("http://localhost:%s", utils.AppConfig.Port)
-	}
 
 	type LinkResponse struct {
 		models.Link
@@ -288,11 +731,11 @@ func GetLinks(c *gin.Context) {
 
 		// Check if link is active and not expired
 		isActive := link.ActiveFrom == nil || now.After(*link.ActiveFrom)
-		isExpired := link.ExpiresAt != nil && now.After(*link.ExpiresAt)
+		isExpired := (link.ExpiresAt != nil && now.After(*link.ExpiresAt)) || clickLimitReached(&link)
 
 		response[i] = LinkResponse{
 			Link:         link,
-			ShortURL:     fmt.Sprintf("%s/%s", baseURL, link.Slug),
+			ShortURL:     linkShortURL(&link),
 			UniqueClicks: uniqueClicks,
 			IsActive:     isActive,
 			IsExpired:    isExpired,
@@ -306,8 +749,22 @@ func GetLinks(c *gin.Context) {
 func CheckLinkAccess(c *gin.Context) {
 	slug := c.Param("slug")
 
-	var link models.Link
-	err := db.DB.Get(&link, "SELECT * FROM links WHERE slug = $1", slug)
+	// Links on a custom domain are looked up with ?domain=<hostname>
+	var domain *models.Domain
+	if hostname := c.Query("domain"); hostname != "" {
+		d, err := lookupVerifiedDomain(strings.ToLower(hostname))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if d == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Link not found"})
+			return
+		}
+		domain = d
+	}
+
+	link, err := findLinkBySlug(domain, slug)
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Link not found"})
 		return
@@ -323,31 +780,49 @@ func CheckLinkAccess(c *gin.Context) {
 		return
 	}
 
+	if isLinkDisabled(&link) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Link has been disabled"})
+		return
+	}
+
 	// Check if link has expired
-	if link.ExpiresAt != nil && now.After(*link.ExpiresAt) {
+	if (link.ExpiresAt != nil && now.After(*link.ExpiresAt)) || clickLimitReached(&link) {
 		c.JSON(http.StatusGone, gin.H{"error": "Link has expired"})
 		return
 	}
 
-	// Return link info and password requirement status
+	// Return link info and password requirement status. The destination of a limited link is
+	// only revealed by following it, which uses up a click.
+	limited := isClickLimited(&link)
 	response := gin.H{
 		"slug":             link.Slug,
 		"passwordRequired": link.Password != nil,
+		"clickLimited":     limited,
 	}
 
 	// If password is provided, validate it
 	if link.Password != nil {
 		var req models.AccessLinkRequest
 		if err := c.ShouldBindJSON(&req); err == nil && req.Password != nil {
-			err = bcrypt.CompareHashAndPassword([]byte(*link.Password), []byte(*req.Password))
-			if err == nil {
+			valid, remaining, err := verifyLinkPassword(c, &link, *req.Password)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+				return
+			}
+			if remaining > 0 {
+				respondLocked(c, remaining)
+				return
+			}
+			if valid {
 				response["passwordValid"] = true
-				response["originalUrl"] = link.Original
+				if !limited {
+					response["originalUrl"] = link.Original
+				}
 			} else {
 				response["passwordValid"] = false
 			}
 		}
-	} else {
+	} else if !limited {
 		response["originalUrl"] = link.Original
 	}
 
@@ -374,17 +849,30 @@ func UpdateLink(c *gin.Context) {
 	// Get user ID from context (set by JWT middleware)
 	userID := middleware.GetUserID(c)
 
-	// Check if link exists and belongs to user
+	// Check if link exists and the user may edit it
 	var existingLink models.Link
 	var query string
 	var err2 error
 
 	if userID != nil {
-		query = "SELECT * FROM links WHERE id = $1 AND user_id = $2"
-		err2 = db.DB.Get(&existingLink, query, id, *userID)
-	} else {
-		query = "SELECT * FROM links WHERE id = $1 AND user_id IS NULL"
+		role, roleErr := linkRole(id, *userID)
+		if roleErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if role == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Link not found or access denied"})
+			return
+		}
+		if !roleAtLeast(role, models.RoleEditor) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient workspace role"})
+			return
+		}
+		query = "SELECT * FROM links WHERE id = $1"
 		err2 = db.DB.Get(&existingLink, query, id)
+	} else {
+		query = "SELECT * FROM links WHERE id = $1 AND user_id IS NULL AND management_token_hash = ANY($2)"
+		err2 = db.DB.Get(&existingLink, query, id, pq.Array(managementTokenHashes(c)))
 	}
 
 	if err2 == sql.ErrNoRows {
@@ -407,11 +895,154 @@ func UpdateLink(c *gin.Context) {
 	}
 
 	if req.Disabled != nil {
+		// Blocked links stay disabled until an admin lifts the block
+		if !*req.Disabled && existingLink.BlockedAt != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Link has been disabled by an administrator"})
+			return
+		}
 		updateFields = append(updateFields, fmt.Sprintf("disabled = $%d", argCount))
 		args = append(args, *req.Disabled)
 		argCount++
 	}
 
+	if req.PreviewMode != nil {
+		updateFields = append(updateFields, fmt.Sprintf("preview_mode = $%d", argCount))
+		args = append(args, *req.PreviewMode)
+		argCount++
+	}
+
+	// A limit of 0 removes the click limit. One-time links keep their single click.
+	if req.MaxClicks != nil {
+		if existingLink.OneTime {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The click limit of a one-time link can't be changed"})
+			return
+		}
+		var maxClicks *int
+		if *req.MaxClicks > 0 {
+			maxClicks = req.MaxClicks
+		}
+		// A new limit may bring the link back, so its next expiry is announced again
+		updateFields = append(updateFields, fmt.Sprintf("max_clicks = $%d", argCount), "expired_event_at = NULL")
+		args = append(args, maxClicks)
+		argCount++
+	}
+
+	if req.ForwardQuery != nil {
+		updateFields = append(updateFields, fmt.Sprintf("forward_query = $%d", argCount))
+		args = append(args, *req.ForwardQuery)
+		argCount++
+	}
+
+	if req.QueryPrecedence != nil {
+		updateFields = append(updateFields, fmt.Sprintf("query_precedence = $%d", argCount))
+		args = append(args, *req.QueryPrecedence)
+		argCount++
+	}
+
+	if req.ForwardPath != nil {
+		updateFields = append(updateFields, fmt.Sprintf("forward_path = $%d", argCount))
+		args = append(args, *req.ForwardPath)
+		argCount++
+	}
+
+	if req.UTMTemplateID != nil {
+		var templateID *uuid.UUID
+		if *req.UTMTemplateID != "" {
+			parsed, err := uuid.Parse(*req.UTMTemplateID)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UTM template ID format"})
+				return
+			}
+			if userID == nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required to use UTM templates"})
+				return
+			}
+			allowed, err := canUseUTMTemplate(parsed, *userID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+				return
+			}
+			if !allowed {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "UTM template not found"})
+				return
+			}
+			templateID = &parsed
+		}
+		updateFields = append(updateFields, fmt.Sprintf("utm_template_id = $%d", argCount))
+		args = append(args, templateID)
+		argCount++
+	}
+
+	// Deep link targets; an empty string removes one
+	deepLinkURLs := []*string{}
+	for _, field := range []struct {
+		column string
+		value  *string
+		app    bool
+	}{
+		{"ios_app_url", req.IOSAppURL, true},
+		{"ios_store_url", req.IOSStoreURL, false},
+		{"android_app_url", req.AndroidAppURL, true},
+		{"android_store_url", req.AndroidStoreURL, false},
+	} {
+		if field.value == nil {
+			continue
+		}
+		value := emptyToNil(field.value)
+		if value != nil {
+			var err error
+			if field.app {
+				err = validateAppURL(*value)
+			} else {
+				err = validateStoreURL(*value)
+			}
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			deepLinkURLs = append(deepLinkURLs, value)
+		}
+		updateFields = append(updateFields, fmt.Sprintf("%s = $%d", field.column, argCount))
+		args = append(args, value)
+		argCount++
+	}
+	if len(deepLinkURLs) > 0 {
+		verdict := screenDeepLinkURLs(c.Request.Context(), deepLinkURLs...)
+		if verdict.Blocked() {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Deep link URL is not allowed", "reason": verdict.Reason})
+			return
+		}
+		if verdict.Flagged() {
+			updateFields = append(updateFields, fmt.Sprintf("flagged_reason = COALESCE(flagged_reason, $%d)", argCount))
+			args = append(args, verdict.Reason)
+			argCount++
+		}
+	}
+
+	// Social card overrides; an empty string removes one
+	for _, field := range []struct {
+		column string
+		value  *string
+	}{
+		{"og_title", req.OGTitle},
+		{"og_description", req.OGDescription},
+		{"og_image", req.OGImage},
+	} {
+		if field.value == nil {
+			continue
+		}
+		value := emptyToNil(field.value)
+		if value != nil && field.column == "og_image" {
+			if err := validateCardImage(*value); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		updateFields = append(updateFields, fmt.Sprintf("%s = $%d", field.column, argCount))
+		args = append(args, value)
+		argCount++
+	}
+
 	if len(updateFields) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No valid fields to update"})
 		return
@@ -425,30 +1056,44 @@ func UpdateLink(c *gin.Context) {
 	args = append(args, id)
 	whereClause := fmt.Sprintf("id = $%d", argCount)
 
-	if userID != nil {
+	if userID == nil {
 		argCount++
-		args = append(args, *userID)
-		whereClause += fmt.Sprintf(" AND user_id = $%d", argCount)
-	} else {
-		whereClause += " AND user_id IS NULL"
+		args = append(args, pq.Array(managementTokenHashes(c)))
+		whereClause += fmt.Sprintf(" AND user_id IS NULL AND management_token_hash = ANY($%d)", argCount)
 	}
 
 	updateQuery := fmt.Sprintf("UPDATE links SET %s WHERE %s",
 		strings.Join(updateFields, ", "), whereClause)
 
-	// Execute update
-	result, err := db.DB.Exec(updateQuery, args...)
+	// Execute the update and queue link.updated in one transaction
+	tx, err := db.DB.Beginx()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update link"})
 		return
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil || rowsAffected == 0 {
+	var updatedLink models.Link
+	err = tx.Get(&updatedLink, updateQuery+" RETURNING *", args...)
+	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Link not found or no changes made"})
 		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update link"})
+		return
 	}
 
+	if err := emitLinkEvent(c.Request.Context(), tx, models.EventLinkUpdated, &updatedLink); err != nil {
+		fmt.Printf("Error queueing link.updated event: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update link"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update link"})
+		return
+	}
+	webhooks.Notify()
+
 	c.JSON(http.StatusOK, gin.H{"message": "Link updated successfully"})
 }
 
@@ -466,17 +1111,30 @@ func DeleteLink(c *gin.Context) {
 	// Get user ID from context (set by JWT middleware)
 	userID := middleware.GetUserID(c)
 
-	// Check if link exists and belongs to user before deleting
+	// Check if link exists and the user may delete it
 	var existingLink models.Link
 	var query string
 	var err2 error
 
 	if userID != nil {
-		query = "SELECT id FROM links WHERE id = $1 AND user_id = $2"
-		err2 = db.DB.Get(&existingLink, query, id, *userID)
-	} else {
-		query = "SELECT id FROM links WHERE id = $1 AND user_id IS NULL"
+		role, roleErr := linkRole(id, *userID)
+		if roleErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if role == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Link not found or access denied"})
+			return
+		}
+		if !roleAtLeast(role, models.RoleEditor) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient workspace role"})
+			return
+		}
+		query = "SELECT * FROM links WHERE id = $1"
 		err2 = db.DB.Get(&existingLink, query, id)
+	} else {
+		query = "SELECT * FROM links WHERE id = $1 AND user_id IS NULL AND management_token_hash = ANY($2)"
+		err2 = db.DB.Get(&existingLink, query, id, pq.Array(managementTokenHashes(c)))
 	}
 
 	if err2 == sql.ErrNoRows {
@@ -488,15 +1146,23 @@ func DeleteLink(c *gin.Context) {
 	}
 
 	// Delete the link (this will cascade delete click_events due to foreign key constraint)
+	// and queue link.deleted in one transaction
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete link"})
+		return
+	}
+	defer tx.Rollback()
+
 	var deleteQuery string
 	var result sql.Result
 
 	if userID != nil {
-		deleteQuery = "DELETE FROM links WHERE id = $1 AND user_id = $2"
-		result, err = db.DB.Exec(deleteQuery, id, *userID)
+		deleteQuery = "DELETE FROM links WHERE id = $1"
+		result, err = tx.Exec(deleteQuery, id)
 	} else {
-		deleteQuery = "DELETE FROM links WHERE id = $1 AND user_id IS NULL"
-		result, err = db.DB.Exec(deleteQuery, id)
+		deleteQuery = "DELETE FROM links WHERE id = $1 AND user_id IS NULL AND management_token_hash = ANY($2)"
+		result, err = tx.Exec(deleteQuery, id, pq.Array(managementTokenHashes(c)))
 	}
 
 	if err != nil {
@@ -510,5 +1176,104 @@ func DeleteLink(c *gin.Context) {
 		return
 	}
 
+	if err := emitLinkEvent(c.Request.Context(), tx, models.EventLinkDeleted, &existingLink); err != nil {
+		fmt.Printf("Error queueing link.deleted event: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete link"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete link"})
+		return
+	}
+	webhooks.Notify()
+
 	c.JSON(http.StatusOK, gin.H{"message": "Link deleted successfully"})
 }
+
+// ClaimLinks moves anonymous links into the current user's account using their management tokens
+func ClaimLinks(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	var req models.ClaimLinksRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	// Claimed links go to the requested workspace or the user's personal one
+	var workspaceID uuid.UUID
+	if req.WorkspaceID != nil {
+		role, err := workspaceRole(*req.WorkspaceID, *userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if !roleAtLeast(role, models.RoleEditor) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient workspace role"})
+			return
+		}
+		workspaceID = *req.WorkspaceID
+	} else {
+		personalID, err := personalWorkspaceID(*userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		workspaceID = personalID
+	}
+
+	hashes := make([]string, 0, len(req.Tokens))
+	for _, token := range req.Tokens {
+		if token = strings.TrimSpace(token); token != "" {
+			hashes = append(hashes, utils.HashToken(token))
+		}
+	}
+
+	// The management token is cleared so it can no longer be used once the link has an owner
+	var claimed []string
+	err := db.DB.Select(&claimed, `
+		UPDATE links
+		SET user_id = $1, workspace_id = $2, management_token_hash = NULL, last_updated = $3
+		WHERE user_id IS NULL AND management_token_hash = ANY($4)
+		RETURNING slug
+	`, *userID, workspaceID, time.Now(), pq.Array(hashes))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to claim links"})
+		return
+	}
+
+	if claimed == nil {
+		claimed = []string{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"claimed": len(claimed),
+		"slugs":   claimed,
+	})
+}
+
+// GetLinkChallenge issues a proof-of-work challenge for anonymous link creation
+func GetLinkChallenge(c *gin.Context) {
+	issuer, ok := policy.Anonymous.Verifier.(policy.ChallengeIssuer)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Challenges are not enabled"})
+		return
+	}
+
+	challenge, err := issuer.NewChallenge()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create challenge"})
+		return
+	}
+
+	c.JSON(http.StatusOK, challenge)
+}
+
+// NotFound handles unmatched routes on the short link host
+func NotFound(c *gin.Context) {
+	respondLinkNotFound(c)
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+	"url-shortener-api/db"
+	"url-shortener-api/metadata"
+	"url-shortener-api/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// storeLinkMetadata scrapes a link's destination and saves the page metadata on the link.
+// Icons are left to the favicon queue, which stores them on our side instead of hotlinking.
+func storeLinkMetadata(ctx context.Context, linkID uuid.UUID, destination string) (*metadata.Metadata, error) {
+	meta, err := metadata.Fetch(ctx, destination)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.DB.ExecContext(ctx, `
+		UPDATE links
+		SET meta_title = $1, meta_description = $2, meta_image = $3, meta_site_name = $4,
+			metadata_fetched_at = NOW()
+		WHERE id = $5
+	`, emptyToNil(&meta.Title), emptyToNil(&meta.Description), emptyToNil(&meta.ImageURL),
+		emptyToNil(&meta.SiteName), linkID)
+	if err != nil {
+		return nil, err
+	}
+
+	return meta, nil
+}
+
+// Metadata queue tuning. Failed scrapes are retried with a growing delay and dropped after
+// metadataMaxAttempts, leaving the link without a title or description.
+const (
+	metadataMaxAttempts  = 3
+	metadataPollInterval = 30 * time.Second
+	metadataClaimTimeout = 5 * time.Minute
+)
+
+// metadataWake nudges the worker when a link is queued so it doesn't wait for the next poll
+var metadataWake = make(chan struct{}, 1)
+
+// queueLinkMetadata queues a new link's destination to be scraped in the transaction creating
+// the link. Call wakeMetadataWorker once it has committed.
+func queueLinkMetadata(tx sqlx.Execer, linkID uuid.UUID) error {
+	_, err := tx.Exec("INSERT INTO metadata_jobs (link_id) VALUES ($1) ON CONFLICT (link_id) DO NOTHING", linkID)
+	return err
+}
+
+func wakeMetadataWorker() {
+	select {
+	case metadataWake <- struct{}{}:
+	default:
+	}
+}
+
+// StartMetadataWorker scrapes the destinations of queued links until ctx is cancelled
+func StartMetadataWorker(ctx context.Context) {
+	ticker := time.NewTicker(metadataPollInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			// Drain everything that is due before waiting again
+			for processNextMetadataJob(ctx) {
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-metadataWake:
+			}
+		}
+	}()
+}
+
+// processNextMetadataJob scrapes the destination of the next due link. It reports whether a job was found.
+func processNextMetadataJob(ctx context.Context) bool {
+	// Claiming pushes run_at forward, so a worker that dies mid-job lets it be retried later
+	var job struct {
+		LinkID      uuid.UUID `db:"link_id"`
+		Attempts    int       `db:"attempts"`
+		Destination string    `db:"original"`
+	}
+	err := db.DB.GetContext(ctx, &job, `
+		UPDATE metadata_jobs j
+		SET attempts = j.attempts + 1, run_at = $1
+		FROM links l
+		WHERE l.id = j.link_id AND j.link_id = (
+			SELECT link_id FROM metadata_jobs
+			WHERE run_at <= NOW()
+			ORDER BY run_at
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING j.link_id, j.attempts, l.original
+	`, time.Now().Add(metadataClaimTimeout))
+	if err == sql.ErrNoRows {
+		return false
+	} else if err != nil {
+		log.Printf("Error claiming metadata job: %v", err)
+		return false
+	}
+
+	// metadata.Fetch bounds each scrape with its own timeout
+	_, err = storeLinkMetadata(ctx, job.LinkID, job.Destination)
+	if err == nil || job.Attempts >= metadataMaxAttempts {
+		if err != nil {
+			log.Printf("Giving up on metadata for link %s after %d attempts: %v", job.LinkID, job.Attempts, err)
+		}
+		if _, dbErr := db.DB.ExecContext(ctx, "DELETE FROM metadata_jobs WHERE link_id = $1", job.LinkID); dbErr != nil {
+			log.Printf("Error removing metadata job for link %s: %v", job.LinkID, dbErr)
+		}
+		return true
+	}
+
+	retryAt := time.Now().Add(time.Duration(job.Attempts*job.Attempts) * time.Minute)
+	_, dbErr := db.DB.ExecContext(ctx,
+		"UPDATE metadata_jobs SET run_at = $1, last_error = $2 WHERE link_id = $3",
+		retryAt, err.Error(), job.LinkID)
+	if dbErr != nil {
+		log.Printf("Error rescheduling metadata job for link %s: %v", job.LinkID, dbErr)
+	}
+	return true
+}
+
+// RefreshLinkMetadata re-scrapes a link's destination on demand and returns the updated link
+func RefreshLinkMetadata(c *gin.Context) {
+	link := loadLinkForRole(c, models.RoleEditor)
+	if link == nil {
+		return
+	}
+
+	if isLinkDisabled(link) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Disabled links are not refreshed"})
+		return
+	}
+
+	meta, err := storeLinkMetadata(c.Request.Context(), link.ID, link.Original)
+	if err != nil {
+		fmt.Printf("Error refreshing metadata for link %s: %v\n", link.ID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Could not fetch destination"})
+		return
+	}
+
+	if err := db.DB.Get(link, "SELECT * FROM links WHERE id = $1", link.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	link.ShortURL = linkShortURL(link)
+
+	c.JSON(http.StatusOK, gin.H{
+		"link":  link,
+		"icons": meta.Icons,
+	})
+}
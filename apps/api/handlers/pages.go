@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+	"url-shortener-api/models"
+	"url-shortener-api/views"
+
+	"github.com/gin-gonic/gin"
+)
+
+// wantsHTML reports whether the client prefers an HTML page over JSON, based on the Accept header.
+// JSON is offered first so API clients that send no Accept header keep getting JSON.
+func wantsHTML(c *gin.Context) bool {
+	return c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML
+}
+
+// respondLinkError returns a JSON error to API clients and a rendered error page to browsers
+func respondLinkError(c *gin.Context, status int, message string, title string, description string) {
+	if !wantsHTML(c) {
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.HTML(status, "error.html", views.ErrorPage{
+		PageTitle: title,
+		Title:     title,
+		Message:   description,
+	})
+}
+
+// respondLinkNotFound responds for slugs that don't exist
+func respondLinkNotFound(c *gin.Context) {
+	respondLinkError(c, http.StatusNotFound, "Link not found",
+		"Link not found", "This short link doesn't exist. Check that it was typed correctly.")
+}
+
+// respondLinkExpired responds for links past their expiry
+func respondLinkExpired(c *gin.Context) {
+	respondLinkError(c, http.StatusGone, "Link has expired",
+		"Link expired", "This short link has expired and no longer redirects anywhere.")
+}
+
+// respondLinkDisabled responds for links disabled by their owner or an admin
+func respondLinkDisabled(c *gin.Context) {
+	respondLinkError(c, http.StatusForbidden, "Link has been disabled",
+		"Link disabled", "This short link has been disabled.")
+}
+
+// respondLinkScheduled responds for links that are not active yet, with a countdown for browsers
+func respondLinkScheduled(c *gin.Context, activeFrom time.Time) {
+	if !wantsHTML(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Link is not yet active", "activeFrom": activeFrom})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.HTML(http.StatusForbidden, "scheduled.html", views.ScheduledPage{
+		PageTitle:         "Link not active yet",
+		Title:             "Link not active yet",
+		ActiveFrom:        activeFrom.UTC().Format(time.RFC3339),
+		ActiveFromDisplay: activeFrom.UTC().Format("January 2, 2006 at 15:04 UTC"),
+	})
+}
+
+// respondPasswordRequired asks for a link password; browsers get a form that posts back to the same URL
+func respondPasswordRequired(c *gin.Context, link *models.Link, invalid bool) {
+	message := "Password required"
+	if invalid {
+		message = "Invalid password"
+	}
+
+	if !wantsHTML(c) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": message, "passwordRequired": true})
+		return
+	}
+
+	page := views.PasswordPage{
+		PageTitle: "Protected link",
+		Title:     "Protected link",
+		Action:    c.Request.URL.RequestURI(),
+	}
+	if link.Name != nil && *link.Name != "" {
+		page.Title = *link.Name
+	}
+	if invalid {
+		page.Error = "That password is incorrect."
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.HTML(http.StatusUnauthorized, "password.html", page)
+}
+
+// respondLinkLocked responds when too many wrong passwords were tried
+func respondLinkLocked(c *gin.Context, remaining time.Duration) {
+	if !wantsHTML(c) {
+		respondLocked(c, remaining)
+		return
+	}
+
+	seconds := int(math.Ceil(remaining.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	respondLinkError(c, http.StatusTooManyRequests, "Too many failed attempts, please try again later",
+		"Too many attempts", "Too many incorrect passwords were entered. Please wait a while and try again.")
+}
+
+// submittedLinkPassword reads a link password from an HTML form post or a JSON body
+func submittedLinkPassword(c *gin.Context) *string {
+	switch c.ContentType() {
+	case gin.MIMEPOSTForm, gin.MIMEMultipartPOSTForm:
+		if password, ok := c.GetPostForm("password"); ok {
+			return &password
+		}
+		return nil
+	}
+
+	var req models.AccessLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return nil
+	}
+	return req.Password
+}
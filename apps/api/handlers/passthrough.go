@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"net/url"
+	"path"
+	"strings"
+	"url-shortener-api/models"
+)
+
+// Query precedence options for links that forward the query string
+const (
+	PrecedenceDestination = "destination"
+	PrecedenceIncoming    = "incoming"
+)
+
+// internalParams are query parameters used by the redirect handler itself and never forwarded
+var internalParams = map[string]bool{
+	continueParam: true,
+}
+
+// applyPassthrough appends the extra request path and the request query to the destination,
+// as configured on the link. The destination's own path and query keep their encoding, and
+// forwarded parameters are passed on as the visitor sent them. The destination is returned
+// unchanged if it can't be parsed.
+func applyPassthrough(destination string, link *models.Link, extraPath string, rawQuery string) string {
+	if !link.ForwardQuery && (!link.ForwardPath || extraPath == "") {
+		return destination
+	}
+
+	target, err := url.Parse(destination)
+	if err != nil {
+		return destination
+	}
+
+	if link.ForwardPath && extraPath != "" {
+		// Clean the path so ".." segments can't climb above the destination's own path
+		extra := path.Clean("/" + extraPath)
+		if strings.HasSuffix(extraPath, "/") && extra != "/" {
+			extra += "/"
+		}
+		escaped := strings.TrimSuffix(target.EscapedPath(), "/") + (&url.URL{Path: extra}).EscapedPath()
+		if unescaped, err := url.PathUnescape(escaped); err == nil {
+			target.Path, target.RawPath = unescaped, escaped
+		}
+	}
+
+	if link.ForwardQuery && rawQuery != "" {
+		incoming, _ := url.ParseQuery(rawQuery)
+		existing := target.Query()
+		overridden := map[string]bool{}
+		added := []string{}
+		for _, pair := range strings.Split(rawQuery, "&") {
+			key := queryKey(pair)
+			if pair == "" || internalParams[key] {
+				continue
+			}
+			// Source markers such as ?src=qr are recorded, not forwarded
+			if key == sourceParam && clickSource(incoming) != nil {
+				continue
+			}
+			if existing.Has(key) {
+				if link.QueryPrecedence != PrecedenceIncoming {
+					continue
+				}
+				overridden[key] = true
+			}
+			added = append(added, pair)
+		}
+
+		if len(added) > 0 {
+			query := target.RawQuery
+			if len(overridden) > 0 {
+				kept := []string{}
+				for _, pair := range strings.Split(target.RawQuery, "&") {
+					if pair != "" && !overridden[queryKey(pair)] {
+						kept = append(kept, pair)
+					}
+				}
+				query = strings.Join(kept, "&")
+			}
+			if query != "" && !strings.HasSuffix(query, "&") {
+				query += "&"
+			}
+			target.RawQuery = query + strings.Join(added, "&")
+		}
+	}
+
+	return target.String()
+}
+
+// queryKey returns the unescaped name of a raw key=value query pair
+func queryKey(pair string) string {
+	key, _, _ := strings.Cut(pair, "=")
+	if unescaped, err := url.QueryUnescape(key); err == nil {
+		return unescaped
+	}
+	return key
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"testing"
+	"url-shortener-api/models"
+)
+
+func TestApplyPassthrough(t *testing.T) {
+	query := &models.Link{ForwardQuery: true, QueryPrecedence: PrecedenceDestination}
+	incoming := &models.Link{ForwardQuery: true, QueryPrecedence: PrecedenceIncoming}
+	prefix := &models.Link{ForwardPath: true}
+	both := &models.Link{ForwardQuery: true, ForwardPath: true, QueryPrecedence: PrecedenceDestination}
+
+	tests := []struct {
+		name        string
+		link        *models.Link
+		destination string
+		extraPath   string
+		rawQuery    string
+		want        string
+	}{
+		{"nothing forwarded", &models.Link{}, "https://example.com/?a=1", "more", "b=2", "https://example.com/?a=1"},
+		{"no query", query, "https://example.com/?a=%7e", "", "", "https://example.com/?a=%7e"},
+
+		// Query precedence
+		{"query appended", query, "https://example.com/page", "", "b=2&a=1", "https://example.com/page?b=2&a=1"},
+		{"destination wins", query, "https://example.com/?a=1", "", "a=2&b=3", "https://example.com/?a=1&b=3"},
+		{"incoming wins", incoming, "https://example.com/?a=1&c=3", "", "a=2&b=3", "https://example.com/?c=3&a=2&b=3"},
+		{"incoming wins every value", incoming, "https://example.com/?tag=x&tag=y&c=3", "", "tag=z", "https://example.com/?c=3&tag=z"},
+		{"repeated parameters", query, "https://example.com/", "", "tag=x&tag=y", "https://example.com/?tag=x&tag=y"},
+		{"continue token dropped", query, "https://example.com/", "", "continue=abc.def&a=1", "https://example.com/?a=1"},
+		{"source marker dropped", query, "https://example.com/", "", "src=qr&a=1", "https://example.com/?a=1"},
+		{"unknown source forwarded", query, "https://example.com/", "", "src=newsletter", "https://example.com/?src=newsletter"},
+		{"only dropped parameters", query, "https://example.com/?a=%7e", "", "continue=abc", "https://example.com/?a=%7e"},
+		{"fragment kept last", query, "https://example.com/page#top", "", "a=1", "https://example.com/page?a=1#top"},
+
+		// Encoding preservation
+		{"destination encoding kept", query, "https://example.com/?sig=a%2Bb&z=%7e&a", "", "q=1", "https://example.com/?sig=a%2Bb&z=%7e&a&q=1"},
+		{"incoming encoding kept", query, "https://example.com/?z=1", "", "q=hello%20world&r=a+b&s=%7E", "https://example.com/?z=1&q=hello%20world&r=a+b&s=%7E"},
+		{"escaped keys compared unescaped", query, "https://example.com/?a%5B%5D=1", "", "a[]=2&b=3", "https://example.com/?a%5B%5D=1&b=3"},
+		{"overridden keys removed only", incoming, "https://example.com/?sig=a%2Bb&a=1", "", "a=2", "https://example.com/?sig=a%2Bb&a=2"},
+		{"trailing ampersand", query, "https://example.com/?a=1&", "", "b=2", "https://example.com/?a=1&b=2"},
+
+		// Path forwarding
+		{"path appended", prefix, "https://example.com/docs", "guide/intro", "", "https://example.com/docs/guide/intro"},
+		{"destination slash", prefix, "https://example.com/docs/", "guide", "", "https://example.com/docs/guide"},
+		{"trailing slash kept", prefix, "https://example.com/docs", "guide/", "", "https://example.com/docs/guide/"},
+		{"root destination", prefix, "https://example.com", "guide", "", "https://example.com/guide"},
+		{"no climbing", prefix, "https://example.com/docs", "../../etc/passwd", "", "https://example.com/docs/etc/passwd"},
+		{"escaped destination path kept", prefix, "https://example.com/files/a%2Fb", "c", "", "https://example.com/files/a%2Fb/c"},
+		{"extra path escaped", prefix, "https://example.com/files", "my file?.txt", "", "https://example.com/files/my%20file%3F.txt"},
+		{"query not forwarded", prefix, "https://example.com/docs?v=1", "guide", "a=1", "https://example.com/docs/guide?v=1"},
+		{"path and query", both, "https://example.com/base?x=%7e", "more", "y=2", "https://example.com/base/more?x=%7e&y=2"},
+
+		{"unparsable destination", both, "https://exa mple.com/%zz", "more", "y=2", "https://exa mple.com/%zz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := applyPassthrough(tt.destination, tt.link, tt.extraPath, tt.rawQuery); got != tt.want {
+				t.Errorf("applyPassthrough(%q, %q, %q) = %q, want %q", tt.destination, tt.extraPath, tt.rawQuery, got, tt.want)
+			}
+		})
+	}
+}
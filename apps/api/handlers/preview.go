@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"url-shortener-api/models"
+	"url-shortener-api/utils"
+	"url-shortener-api/views"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// previewSuffix appended to a slug shows the preview page instead of redirecting
+const previewSuffix = "+"
+
+// continueParam carries a signed token that bypasses the preview page and interstitial
+// once the visitor chooses to continue
+const continueParam = "continue"
+
+// continueTokenTTL is how long a continue link stays valid, so a shared one stops skipping the warning
+const continueTokenTTL = 10 * time.Minute
+
+// continueToken signs a link ID with an expiry time for the continue link of its preview page
+func continueToken(linkID uuid.UUID, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 36)
+	return exp + "." + continueSignature(linkID, exp)
+}
+
+func continueSignature(linkID uuid.UUID, exp string) string {
+	mac := hmac.New(sha256.New, []byte(utils.AppConfig.JWTSecret))
+	mac.Write([]byte("continue:" + linkID.String() + ":" + exp))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// validContinueToken reports whether token was issued for the link and has not expired
+func validContinueToken(linkID uuid.UUID, token string, now time.Time) bool {
+	exp, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	expires, err := strconv.ParseInt(exp, 36, 64)
+	if err != nil || now.Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(continueSignature(linkID, exp)))
+}
+
+// linkStatus returns why a link cannot currently be followed, or an empty string if it can
+func linkStatus(link *models.Link, now time.Time) string {
+	switch {
+	case isLinkDisabled(link):
+		return "This link has been disabled."
+	case link.ActiveFrom != nil && now.Before(*link.ActiveFrom):
+		return "This link is not active yet."
+	case link.ExpiresAt != nil && now.After(*link.ExpiresAt):
+		return "This link has expired."
+	case clickLimitReached(link):
+		return "This link has expired."
+	}
+	return ""
+}
+
+// interstitialWarning returns the warning to show before redirecting, or an empty string
+// when the visitor can be redirected straight away
+func interstitialWarning(link *models.Link) string {
+	if link.OneTime {
+		return "This link can only be opened once. After you continue it will stop working."
+	}
+
+	if utils.AppConfig.InterstitialFlagged && link.FlaggedReason != nil {
+		return "This link has been flagged as potentially unsafe: " + *link.FlaggedReason + ". Only continue if you trust the destination."
+	}
+
+	if utils.AppConfig.InterstitialExternal && !isTrustedDestination(link.Original) {
+		return "You are leaving this site. Check the destination below before continuing."
+	}
+
+	return ""
+}
+
+// needsPreviewPage reports whether a link should render the preview or interstitial page
+func needsPreviewPage(c *gin.Context, link *models.Link) bool {
+	if validContinueToken(link.ID, c.Query(continueParam), time.Now()) {
+		return false
+	}
+	// One-time links always ask first, so link unfurlers and scanners don't use up the only click
+	return link.PreviewMode || link.OneTime || interstitialWarning(link) != ""
+}
+
+// isTrustedDestination reports whether the URL's host is in INTERSTITIAL_TRUSTED_DOMAINS
+func isTrustedDestination(rawURL string) bool {
+	host := strings.ToLower(utils.GetDomainFromURL(rawURL))
+	for _, domain := range strings.Split(utils.AppConfig.InterstitialTrustedDomains, ",") {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if domain != "" && (host == domain || strings.HasSuffix(host, "."+domain)) {
+			return true
+		}
+	}
+	return false
+}
+
+// continueURL returns the current URL without the preview suffix and with a continue token
+// set, keeping any extra path and query so they can still be forwarded
+func continueURL(c *gin.Context, link *models.Link) string {
+	target := *c.Request.URL
+	slug := c.Param("slug")
+	target.Path = strings.Replace(target.Path, "/"+slug, "/"+strings.TrimSuffix(slug, previewSuffix), 1)
+	target.RawPath = ""
+
+	query := target.Query()
+	query.Set(continueParam, continueToken(link.ID, time.Now().Add(continueTokenTTL)))
+	target.RawQuery = query.Encode()
+
+	return target.RequestURI()
+}
+
+// renderPreview shows the destination of a link without recording a click
+func renderPreview(c *gin.Context, link *models.Link, warning string, status string) {
+	title := utils.GetDomainFromURL(link.Original)
+	if link.Name != nil && *link.Name != "" {
+		title = *link.Name
+	}
+
+	page := views.PreviewPage{
+		PageTitle:         "Link preview",
+		Title:             title,
+		Destination:       link.Original,
+		ContinueURL:       continueURL(c, link),
+		Warning:           warning,
+		Status:            status,
+		PasswordProtected: link.Password != nil,
+		OneTime:           link.OneTime,
+		ClickLimited:      isClickLimited(link),
+	}
+	if link.FaviconURL != nil {
+		page.FaviconURL = *link.FaviconURL
+	}
+
+	// Don't leak the destination of protected or click-limited links through the page title
+	if (page.PasswordProtected || page.ClickLimited) && (link.Name == nil || *link.Name == "") {
+		page.Title = "Protected link"
+	}
+
+	c.Header("Cache-Control", "no-store")
+	if !wantsHTML(c) {
+		response := gin.H{
+			"slug":             link.Slug,
+			"title":            page.Title,
+			"passwordRequired": page.PasswordProtected,
+			"previewRequired":  true,
+			"warning":          page.Warning,
+			"status":           page.Status,
+			"continueUrl":      page.ContinueURL,
+		}
+		if !page.PasswordProtected && !page.ClickLimited {
+			response["destination"] = page.Destination
+			response["faviconUrl"] = page.FaviconURL
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	c.HTML(http.StatusOK, "preview.html", page)
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"url-shortener-api/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestRenderPreviewHidesLimitedDestinations(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	maxClicks := 3
+	password := "hash"
+
+	tests := []struct {
+		name   string
+		link   models.Link
+		hidden bool
+	}{
+		{"plain", models.Link{}, false},
+		{"password", models.Link{Password: &password}, true},
+		{"one-time", models.Link{OneTime: true}, true},
+		{"click limit", models.Link{MaxClicks: &maxClicks}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link := tt.link
+			link.ID = uuid.New()
+			link.Slug = "abc"
+			link.Original = "https://secret.example.com/page"
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/abc+", nil)
+			c.Request.Header.Set("Accept", "application/json")
+			c.Params = gin.Params{{Key: "slug", Value: "abc+"}}
+
+			renderPreview(c, &link, "", "")
+
+			var body map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			_, shown := body["destination"]
+			if shown == tt.hidden {
+				t.Errorf("destination shown = %v, want %v", shown, !tt.hidden)
+			}
+			if tt.hidden && body["title"] != "Protected link" {
+				t.Errorf("title = %v, want the destination hidden", body["title"])
+			}
+		})
+	}
+}
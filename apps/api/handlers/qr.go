@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"url-shortener-api/models"
+	"url-shortener-api/qrcode"
+	"url-shortener-api/screening"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sourceParam marks where a click came from. Only known sources are recorded.
+const sourceParam = "src"
+
+// SourceQR marks clicks from scanned QR codes
+const SourceQR = "qr"
+
+// knownSources are the sourceParam values recorded on click events
+var knownSources = map[string]bool{
+	SourceQR: true,
+}
+
+// QR code rendering limits
+const (
+	qrDefaultSize = 512
+	qrMinSize     = 64
+	qrMaxSize     = 2048
+	qrMaxMargin   = 16
+	qrMaxLogo     = 1 << 20
+	// qrMaxLogoSide bounds logo dimensions, since a small file can declare a huge image
+	qrMaxLogoSide = 2048
+)
+
+// clickSource returns the recorded source marker of a request, or nil
+func clickSource(query url.Values) *string {
+	source := query.Get(sourceParam)
+	if !knownSources[source] {
+		return nil
+	}
+	return &source
+}
+
+// logoClient fetches QR logos and refuses to connect to private or internal addresses
+var logoClient = screening.PublicClient(5 * time.Second)
+
+// fetchLogo downloads and decodes a logo image for the centre of a QR code
+func fetchLogo(ctx context.Context, rawURL string) (*qrcode.Logo, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return nil, errors.New("logo must be an http(s) URL")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; URL-Shortener-Bot/1.0)")
+
+	resp, err := logoClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch logo: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not fetch logo: status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, qrMaxLogo+1))
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch logo: %w", err)
+	}
+	if len(data) > qrMaxLogo {
+		return nil, errors.New("logo is larger than 1 MB")
+	}
+
+	// Check the declared size before decoding allocates memory for every pixel
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("logo must be a PNG, JPEG or GIF image")
+	}
+	if config.Width > qrMaxLogoSide || config.Height > qrMaxLogoSide {
+		return nil, fmt.Errorf("logo must be at most %dx%d pixels", qrMaxLogoSide, qrMaxLogoSide)
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("logo must be a PNG, JPEG or GIF image")
+	}
+
+	return &qrcode.Logo{Image: img, Data: data, ContentType: "image/" + format}, nil
+}
+
+// intQuery parses an integer query parameter within [min, max], using def when it is absent
+func intQuery(c *gin.Context, name string, def int, min int, max int) (int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("%s must be between %d and %d", name, min, max)
+	}
+	return n, nil
+}
+
+// GetLinkQRCode renders a QR code for a link's short URL as PNG or SVG. The encoded URL carries
+// ?src=qr so scans can be told apart from other clicks.
+func GetLinkQRCode(c *gin.Context) {
+	link := loadLinkForRole(c, models.RoleViewer)
+	if link == nil {
+		return
+	}
+
+	format := strings.ToLower(c.DefaultQuery("format", "png"))
+	if format != "png" && format != "svg" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be png or svg"})
+		return
+	}
+
+	size, err := intQuery(c, "size", qrDefaultSize, qrMinSize, qrMaxSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	margin, err := intQuery(c, "margin", 4, 0, qrMaxMargin)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	opts := qrcode.Options{Size: size, Margin: margin}
+	if opts.Foreground, err = qrcode.ParseColor(c.DefaultQuery("fg", "000000")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "fg: " + err.Error()})
+		return
+	}
+	if opts.Background, err = qrcode.ParseColor(c.DefaultQuery("bg", "ffffff")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bg: " + err.Error()})
+		return
+	}
+
+	// A logo hides part of the symbol, so default to the highest error correction with one
+	level := qrcode.LevelM
+	if logoURL := c.Query("logo"); logoURL != "" {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		defer cancel()
+		if opts.Logo, err = fetchLogo(ctx, logoURL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		level = qrcode.LevelH
+	}
+	if ecc := c.Query("ecc"); ecc != "" {
+		if level, err = qrcode.ParseLevel(ecc); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	target := linkShortURL(link) + "?" + sourceParam + "=" + SourceQR
+	code, err := qrcode.Encode([]byte(target), level)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate QR code"})
+		return
+	}
+
+	if c.Query("download") != "" {
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-qr.%s"`, link.Slug, format))
+	}
+	c.Header("Cache-Control", "private, max-age=3600")
+
+	if format == "svg" {
+		c.Data(http.StatusOK, "image/svg+xml", qrcode.SVG(code, opts))
+		return
+	}
+
+	data, err := qrcode.PNG(code, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate QR code"})
+		return
+	}
+	c.Data(http.StatusOK, "image/png", data)
+}
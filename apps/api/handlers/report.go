@@ -0,0 +1,563 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"url-shortener-api/db"
+	"url-shortener-api/mailer"
+	"url-shortener-api/middleware"
+	"url-shortener-api/models"
+	"url-shortener-api/views"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// PerformanceReport summarises link performance over a complete week or month
+type PerformanceReport struct {
+	Frequency   string    `json:"frequency"`
+	PeriodStart time.Time `json:"periodStart"`
+	PeriodEnd   time.Time `json:"periodEnd"`
+	TotalClicks int       `json:"totalClicks"`
+	// PreviousClicks is the total of the period before, for the click trend
+	PreviousClicks int `json:"previousClicks"`
+	// ClickChange is the percentage change against the previous period, nil when it had no clicks
+	ClickChange    *float64             `json:"clickChange"`
+	UniqueVisitors int                  `json:"uniqueVisitors"`
+	ClicksPerDay   []ClicksOverTimeData `json:"clicksPerDay"`
+	TopLinks       []TopLinkData        `json:"topLinks"`
+	TopCountries   []CountryData        `json:"topCountries"`
+	// NewCountries sent their first clicks during the period
+	NewCountries []CountryData    `json:"newCountries"`
+	BrokenLinks  []BrokenLinkData `json:"brokenLinks"`
+}
+
+type BrokenLinkData struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Slug       string     `json:"slug"`
+	Original   string     `json:"original"`
+	ShortURL   string     `json:"shortUrl"`
+	StatusCode *int       `json:"statusCode,omitempty"`
+	CheckedAt  *time.Time `json:"checkedAt,omitempty"`
+}
+
+// reportTemplates renders report emails outside of a request
+var reportTemplates = views.Templates()
+
+// reportPeriodEnd returns the end of the last complete reporting period before now:
+// the start of the current ISO week or calendar month, in UTC
+func reportPeriodEnd(frequency string, now time.Time) time.Time {
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if frequency == models.ReportMonthly {
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	daysSinceMonday := (int(today.Weekday()) + 6) % 7
+	return today.AddDate(0, 0, -daysSinceMonday)
+}
+
+// reportPeriodStart returns the start of the period ending at end
+func reportPeriodStart(frequency string, end time.Time) time.Time {
+	if frequency == models.ReportMonthly {
+		return end.AddDate(0, -1, 0)
+	}
+	return end.AddDate(0, 0, -7)
+}
+
+// buildReport aggregates the period ending at end with the same queries as the dashboard
+func buildReport(userID uuid.UUID, workspaceID *uuid.UUID, frequency string, end time.Time) (*PerformanceReport, error) {
+	scope, scopeArg := statsScope(userID, workspaceID)
+	start := reportPeriodStart(frequency, end)
+	previousStart := reportPeriodStart(frequency, start)
+	period := statsPeriod{From: &start, To: &end}
+
+	report := &PerformanceReport{
+		Frequency:   frequency,
+		PeriodStart: start,
+		PeriodEnd:   end,
+	}
+
+	var err error
+	if report.TotalClicks, err = countClicks(scope, scopeArg, period); err != nil {
+		return nil, err
+	}
+	if report.PreviousClicks, err = countClicks(scope, scopeArg, statsPeriod{From: &previousStart, To: &start}); err != nil {
+		return nil, err
+	}
+	if report.PreviousClicks > 0 {
+		change := float64(report.TotalClicks-report.PreviousClicks) / float64(report.PreviousClicks) * 100
+		report.ClickChange = &change
+	}
+	if report.UniqueVisitors, err = countUniqueVisitors(scope, scopeArg, period); err != nil {
+		return nil, err
+	}
+	if report.ClicksPerDay, err = queryClicksPerDay(scope, scopeArg, period); err != nil {
+		return nil, err
+	}
+	if report.TopLinks, err = queryTopLinks(scope, scopeArg, period, 5); err != nil {
+		return nil, err
+	}
+	if report.TopCountries, err = queryTopCountries(scope, scopeArg, period, 5); err != nil {
+		return nil, err
+	}
+	if report.NewCountries, err = queryNewCountries(scope, scopeArg, period); err != nil {
+		return nil, err
+	}
+	if report.BrokenLinks, err = queryBrokenLinks(scope, scopeArg, 10); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// queryNewCountries returns countries whose first click on links in scope falls within the period
+func queryNewCountries(scope string, scopeArg interface{}, period statsPeriod) ([]CountryData, error) {
+	rows, err := db.DB.Query(`
+		SELECT
+			ce.country,
+			COUNT(*) FILTER (WHERE ce.timestamp >= $2) as clicks
+		FROM click_events ce
+		JOIN links l ON ce.link_id = l.id
+		WHERE `+scope+` AND ce.country IS NOT NULL AND ce.timestamp < $3
+		GROUP BY ce.country
+		HAVING MIN(ce.timestamp) >= $2
+		ORDER BY clicks DESC
+	`, scopeArg, period.From, period.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	countries := make([]CountryData, 0)
+	for rows.Next() {
+		var data CountryData
+		if err := rows.Scan(&data.Country, &data.Clicks); err == nil {
+			data.Code = getCountryCode(data.Country)
+			countries = append(countries, data)
+		}
+	}
+	return countries, rows.Err()
+}
+
+// queryBrokenLinks returns links in scope whose latest health check failed
+func queryBrokenLinks(scope string, scopeArg interface{}, limit int) ([]BrokenLinkData, error) {
+	rows, err := db.DB.Query(`
+		SELECT
+			l.id, COALESCE(l.name, l.slug) as name, l.slug, l.original, l.domain_id,
+			l.health_status_code, l.health_checked_at
+		FROM links l
+		WHERE `+scope+` AND l.health_status = $2
+		ORDER BY l.health_checked_at DESC
+		LIMIT $3
+	`, scopeArg, models.HealthBroken, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	brokenLinks := make([]BrokenLinkData, 0)
+	for rows.Next() {
+		var data BrokenLinkData
+		var domainID *uuid.UUID
+		if err := rows.Scan(&data.ID, &data.Name, &data.Slug, &data.Original, &domainID,
+			&data.StatusCode, &data.CheckedAt); err == nil {
+			data.ShortURL = domainShortURL(domainID, data.Slug)
+			brokenLinks = append(brokenLinks, data)
+		}
+	}
+	return brokenLinks, rows.Err()
+}
+
+// isEmpty reports whether there is nothing worth emailing about
+func (r *PerformanceReport) isEmpty() bool {
+	return r.TotalClicks == 0 && r.PreviousClicks == 0 && len(r.BrokenLinks) == 0
+}
+
+func (r *PerformanceReport) title() string {
+	if r.Frequency == models.ReportMonthly {
+		return "Your monthly link report"
+	}
+	return "Your weekly link report"
+}
+
+// periodLabel formats the period for display; PeriodEnd is exclusive
+func (r *PerformanceReport) periodLabel() string {
+	if r.Frequency == models.ReportMonthly {
+		return r.PeriodStart.Format("January 2006")
+	}
+	return r.PeriodStart.Format("Jan 2") + " – " + r.PeriodEnd.AddDate(0, 0, -1).Format("Jan 2, 2006")
+}
+
+// changeLabel formats the click trend, e.g. "+12%"
+func (r *PerformanceReport) changeLabel() string {
+	if r.ClickChange == nil {
+		return ""
+	}
+	return fmt.Sprintf("%+.0f%%", *r.ClickChange)
+}
+
+// emailData formats the report for report.html
+func (r *PerformanceReport) emailData() views.ReportEmail {
+	data := views.ReportEmail{
+		Title:          r.title(),
+		Period:         r.periodLabel(),
+		TotalClicks:    r.TotalClicks,
+		PreviousClicks: r.PreviousClicks,
+		Change:         r.changeLabel(),
+		ChangeUp:       r.ClickChange != nil && *r.ClickChange >= 0,
+		UniqueVisitors: r.UniqueVisitors,
+	}
+	for _, link := range r.TopLinks {
+		data.TopLinks = append(data.TopLinks, views.ReportRow{
+			Label:  link.Name,
+			Detail: link.ShortURL,
+			URL:    link.ShortURL,
+			Value:  fmt.Sprintf("%d clicks", link.Clicks),
+		})
+	}
+	for _, country := range r.NewCountries {
+		data.NewCountries = append(data.NewCountries, views.ReportRow{
+			Label: country.Country,
+			Value: fmt.Sprintf("%d clicks", country.Clicks),
+		})
+	}
+	for _, link := range r.BrokenLinks {
+		status := "No response"
+		if link.StatusCode != nil {
+			status = fmt.Sprintf("HTTP %d", *link.StatusCode)
+		}
+		data.BrokenLinks = append(data.BrokenLinks, views.ReportRow{
+			Label:  link.Name,
+			Detail: link.Original,
+			URL:    link.ShortURL,
+			Value:  status,
+		})
+	}
+	return data
+}
+
+// text renders the plain text version of the report email
+func (r *PerformanceReport) text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n%s\n\n", r.title(), r.periodLabel())
+	fmt.Fprintf(&b, "Clicks: %d", r.TotalClicks)
+	if change := r.changeLabel(); change != "" {
+		fmt.Fprintf(&b, " (%s vs previous period, %d)", change, r.PreviousClicks)
+	}
+	fmt.Fprintf(&b, "\nUnique visitors: %d\n", r.UniqueVisitors)
+
+	if len(r.TopLinks) > 0 {
+		b.WriteString("\nTop links\n")
+		for _, link := range r.TopLinks {
+			fmt.Fprintf(&b, "- %s (%s): %d clicks\n", link.Name, link.ShortURL, link.Clicks)
+		}
+	}
+	if len(r.NewCountries) > 0 {
+		b.WriteString("\nNew countries\n")
+		for _, country := range r.NewCountries {
+			fmt.Fprintf(&b, "- %s: %d clicks\n", country.Country, country.Clicks)
+		}
+	}
+	if len(r.BrokenLinks) > 0 {
+		b.WriteString("\nBroken links\n")
+		for _, link := range r.BrokenLinks {
+			fmt.Fprintf(&b, "- %s (%s) -> %s\n", link.Name, link.ShortURL, link.Original)
+		}
+	}
+	return b.String()
+}
+
+// message renders the report as an email to the given address
+func (r *PerformanceReport) message(to string) (mailer.Message, error) {
+	var html bytes.Buffer
+	if err := reportTemplates.ExecuteTemplate(&html, "report.html", r.emailData()); err != nil {
+		return mailer.Message{}, err
+	}
+	return mailer.Message{
+		To:      to,
+		Subject: r.title() + ": " + r.periodLabel(),
+		HTML:    html.String(),
+		Text:    r.text(),
+	}, nil
+}
+
+// loadReportSettings returns the user's report settings, defaulting to off
+func loadReportSettings(userID uuid.UUID) (models.ReportSettings, error) {
+	var settings models.ReportSettings
+	err := db.DB.Get(&settings, "SELECT * FROM report_settings WHERE user_id = $1", userID)
+	if err == sql.ErrNoRows {
+		now := time.Now()
+		return models.ReportSettings{UserID: userID, Frequency: models.ReportOff, CreatedAt: now, UpdatedAt: now}, nil
+	}
+	return settings, err
+}
+
+// GetReportSettings returns the user's performance report email settings
+func GetReportSettings(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	settings, err := loadReportSettings(*userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve report settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// UpdateReportSettings opts the user in to or out of weekly or monthly report emails
+func UpdateReportSettings(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	var req models.UpdateReportSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	settings, err := loadReportSettings(*userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if req.WorkspaceID != nil {
+		settings.WorkspaceID = nil
+		if *req.WorkspaceID != "" {
+			workspaceID, err := uuid.Parse(*req.WorkspaceID)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID format"})
+				return
+			}
+			role, err := workspaceRole(workspaceID, *userID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+				return
+			}
+			if role == "" {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Workspace not found or access denied"})
+				return
+			}
+			settings.WorkspaceID = &workspaceID
+		}
+	}
+
+	// Start a newly chosen schedule at the next period boundary rather than
+	// immediately sending a report for the period that just ended
+	if req.Frequency != settings.Frequency {
+		periodEnd := reportPeriodEnd(req.Frequency, time.Now())
+		settings.LastPeriodEnd = &periodEnd
+	}
+	settings.Frequency = req.Frequency
+	settings.UpdatedAt = time.Now()
+
+	_, err = db.DB.Exec(`
+		INSERT INTO report_settings (user_id, frequency, workspace_id, last_period_end, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id) DO UPDATE SET
+			frequency = EXCLUDED.frequency,
+			workspace_id = EXCLUDED.workspace_id,
+			last_period_end = EXCLUDED.last_period_end,
+			updated_at = EXCLUDED.updated_at
+	`, *userID, settings.Frequency, settings.WorkspaceID, settings.LastPeriodEnd, settings.CreatedAt, settings.UpdatedAt)
+	if err != nil {
+		fmt.Printf("Error saving report settings: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save report settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// PreviewReport builds the report for the last complete period as JSON, or as the
+// email's HTML with ?format=html. Frequency and workspace default to the user's settings.
+func PreviewReport(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	settings, err := loadReportSettings(*userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	frequency := c.DefaultQuery("frequency", settings.Frequency)
+	if frequency == models.ReportOff {
+		frequency = models.ReportWeekly
+	}
+	if frequency != models.ReportWeekly && frequency != models.ReportMonthly {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "frequency must be weekly or monthly"})
+		return
+	}
+
+	workspaceID := settings.WorkspaceID
+	if workspaceParam := c.Query("workspaceId"); workspaceParam != "" {
+		id, err := uuid.Parse(workspaceParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID format"})
+			return
+		}
+		workspaceID = &id
+	}
+	if workspaceID != nil {
+		role, err := workspaceRole(*workspaceID, *userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if role == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Workspace not found or access denied"})
+			return
+		}
+	}
+
+	report, err := buildReport(*userID, workspaceID, frequency, reportPeriodEnd(frequency, time.Now()))
+	if err != nil {
+		fmt.Printf("Error building report: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build report"})
+		return
+	}
+
+	switch c.DefaultQuery("format", "json") {
+	case "html":
+		c.HTML(http.StatusOK, "report.html", report.emailData())
+	case "json":
+		c.JSON(http.StatusOK, report)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be html or json"})
+	}
+}
+
+// StartReportScheduler periodically emails reports to users whose weekly or monthly period has ended
+func StartReportScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				sendDueReports(ctx)
+			}
+		}
+	}()
+}
+
+type reportRecipient struct {
+	UserID        uuid.UUID  `db:"user_id"`
+	Email         string     `db:"email"`
+	Frequency     string     `db:"frequency"`
+	WorkspaceID   *uuid.UUID `db:"workspace_id"`
+	LastPeriodEnd *time.Time `db:"last_period_end"`
+}
+
+func sendDueReports(ctx context.Context) {
+	// Without a real mailer reports would be marked sent without anyone receiving them
+	if !mailer.Enabled() {
+		return
+	}
+
+	var recipients []reportRecipient
+	err := db.DB.SelectContext(ctx, &recipients, `
+		SELECT rs.user_id, u.email, rs.frequency, rs.workspace_id, rs.last_period_end
+		FROM report_settings rs
+		JOIN users u ON u.id = rs.user_id
+		WHERE rs.frequency IN ('weekly', 'monthly') AND u.suspended_at IS NULL
+	`)
+	if err != nil {
+		log.Printf("Error finding report recipients: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, recipient := range recipients {
+		periodEnd := reportPeriodEnd(recipient.Frequency, now)
+		if recipient.LastPeriodEnd != nil && !recipient.LastPeriodEnd.Before(periodEnd) {
+			continue
+		}
+		if err := sendReport(ctx, recipient, periodEnd); err != nil {
+			log.Printf("Error sending %s report to user %s: %v", recipient.Frequency, recipient.UserID, err)
+		}
+	}
+}
+
+// sendReport claims the period so other replicas skip it, then builds and sends the report.
+// The claim is released if sending fails so the next run retries.
+func sendReport(ctx context.Context, recipient reportRecipient, periodEnd time.Time) error {
+	result, err := db.DB.ExecContext(ctx, `
+		UPDATE report_settings SET last_period_end = $2
+		WHERE user_id = $1 AND frequency = $3 AND (last_period_end IS NULL OR last_period_end < $2)
+	`, recipient.UserID, periodEnd, recipient.Frequency)
+	if err != nil {
+		return err
+	}
+	if claimed, _ := result.RowsAffected(); claimed == 0 {
+		return nil
+	}
+
+	err = deliverReport(ctx, recipient, periodEnd)
+	if err != nil {
+		if _, releaseErr := db.DB.ExecContext(ctx,
+			"UPDATE report_settings SET last_period_end = $2 WHERE user_id = $1 AND last_period_end = $3",
+			recipient.UserID, recipient.LastPeriodEnd, periodEnd); releaseErr != nil {
+			log.Printf("Error releasing report claim for user %s: %v", recipient.UserID, releaseErr)
+		}
+		return err
+	}
+	return nil
+}
+
+func deliverReport(ctx context.Context, recipient reportRecipient, periodEnd time.Time) error {
+	// Fall back to all workspaces if the user has since left the chosen one
+	workspaceID := recipient.WorkspaceID
+	if workspaceID != nil {
+		role, err := workspaceRole(*workspaceID, recipient.UserID)
+		if err != nil {
+			return err
+		}
+		if role == "" {
+			workspaceID = nil
+		}
+	}
+
+	report, err := buildReport(recipient.UserID, workspaceID, recipient.Frequency, periodEnd)
+	if err != nil {
+		return err
+	}
+	if report.isEmpty() {
+		return nil
+	}
+
+	msg, err := report.message(recipient.Email)
+	if err != nil {
+		return err
+	}
+	if err := mailer.Send(ctx, msg); err != nil {
+		return err
+	}
+
+	// The report is out, so failing to record it must not release the claim and send it twice
+	if _, err := db.DB.ExecContext(ctx, "UPDATE report_settings SET last_sent_at = NOW() WHERE user_id = $1", recipient.UserID); err != nil {
+		log.Printf("Error recording report sent to user %s: %v", recipient.UserID, err)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+	"url-shortener-api/db"
+	"url-shortener-api/models"
+	"url-shortener-api/screening"
+	"url-shortener-api/targeting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// loadLinkRules returns a link's targeting rules in evaluation order
+func loadLinkRules(linkID uuid.UUID) ([]models.LinkRule, error) {
+	rules := []models.LinkRule{}
+	err := db.DB.Select(&rules, "SELECT * FROM link_rules WHERE link_id = $1 ORDER BY position", linkID)
+	return rules, err
+}
+
+// selectDestination picks the destination for a visitor from the link's rules. The returned
+// rule ID is nil when no rule matched and the original URL is used. targeted reports whether
+// the link has any rules, in which case the redirect must not be cached.
+func selectDestination(link *models.Link, visitor targeting.Visitor) (destination string, ruleID *uuid.UUID, targeted bool) {
+	targets, err := loadLinkTargets(link)
+	if err != nil {
+		// Fall back to the original URL rather than failing the redirect
+		fmt.Printf("Error loading link rules: %v\n", err)
+		return link.Original, nil, false
+	}
+
+	if rule := targeting.Select(targets.rules, visitor); rule != nil {
+		return rule.Destination, &rule.ID, true
+	}
+	return link.Original, nil, len(targets.rules) > 0
+}
+
+// GetLinkRules lists a link's targeting rules
+func GetLinkRules(c *gin.Context) {
+	link := loadLinkForRole(c, models.RoleEditor)
+	if link == nil {
+		return
+	}
+
+	rules, err := loadLinkRules(link.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// SetLinkRules replaces a link's targeting rules with the given ordered list
+func SetLinkRules(c *gin.Context) {
+	link := loadLinkForRole(c, models.RoleEditor)
+	if link == nil {
+		return
+	}
+
+	var req models.SetLinkRulesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	for _, input := range req.Rules {
+		if err := targeting.Validate(input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Rule destinations go through the same screening as the original URL
+		verdict := screening.Default.Screen(c.Request.Context(), input.Destination)
+		if verdict.Blocked() {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Destination URL is not allowed", "reason": verdict.Reason})
+			return
+		}
+	}
+
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM link_rules WHERE link_id = $1", link.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update rules"})
+		return
+	}
+
+	rules := make([]models.LinkRule, len(req.Rules))
+	now := time.Now()
+	for i, input := range req.Rules {
+		rules[i] = models.LinkRule{
+			ID:          uuid.New(),
+			LinkID:      link.ID,
+			Position:    i,
+			Kind:        input.Kind,
+			Values:      pq.StringArray(input.Values),
+			StartTime:   input.StartTime,
+			EndTime:     input.EndTime,
+			Timezone:    input.Timezone,
+			Destination: input.Destination,
+			CreatedAt:   now,
+		}
+		if rules[i].Values == nil {
+			rules[i].Values = pq.StringArray{}
+		}
+
+		_, err := tx.Exec(`
+			INSERT INTO link_rules (id, link_id, position, kind, match_values, start_time, end_time, timezone, destination, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		`, rules[i].ID, rules[i].LinkID, rules[i].Position, rules[i].Kind, rules[i].Values,
+			rules[i].StartTime, rules[i].EndTime, rules[i].Timezone, rules[i].Destination, rules[i].CreatedAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update rules"})
+			return
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE links SET last_updated = $1 WHERE id = $2", now, link.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update rules"})
+		return
+	}
+
+	if err := notifyLinkTargets(tx, &link.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update rules"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update rules"})
+		return
+	}
+	invalidateLinkTargets(link.ID)
+
+	c.JSON(http.StatusOK, rules)
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"url-shortener-api/models"
+	"url-shortener-api/targeting"
+	"url-shortener-api/views"
+
+	"github.com/gin-gonic/gin"
+)
+
+// hasSocialCard reports whether a link overrides any of its social preview fields
+func hasSocialCard(link *models.Link) bool {
+	return link.OGTitle != nil || link.OGDescription != nil || link.OGImage != nil
+}
+
+// validateCardImage checks that a social card image is an absolute http(s) URL
+func validateCardImage(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return errors.New("ogImage must be an http(s) URL")
+	}
+	return nil
+}
+
+// serveSocialCard answers link preview crawlers with the link's own Open Graph card instead
+// of redirecting them, so chat apps and social networks show the card the user chose.
+// Crawler fetches are not counted as clicks. Returns false when the request should continue.
+func serveSocialCard(c *gin.Context, link *models.Link) bool {
+	if !hasSocialCard(link) {
+		return false
+	}
+
+	// The response depends on who is asking, so shared caches must not mix them up
+	c.Header("Vary", "User-Agent")
+	if !targeting.IsCrawler(c.Request.UserAgent()) {
+		return false
+	}
+
+	page := views.SocialCardPage{URL: linkShortURL(link)}
+
+	// Fields the user didn't override fall back to the scraped destination metadata, unless
+	// the destination is meant to stay hidden
+	if link.Password == nil && !link.OneTime {
+		page.Title = derefOr(link.MetaTitle, "")
+		page.Description = derefOr(link.MetaDescription, "")
+		page.ImageURL = derefOr(link.MetaImage, "")
+		page.SiteName = derefOr(link.MetaSiteName, "")
+	}
+	page.Title = derefOr(link.OGTitle, page.Title)
+	page.Description = derefOr(link.OGDescription, page.Description)
+	page.ImageURL = derefOr(link.OGImage, page.ImageURL)
+	if page.Title == "" {
+		page.Title = derefOr(link.Name, page.URL)
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.HTML(http.StatusOK, "social.html", page)
+	return true
+}
+
+// derefOr returns *value, or fallback when value is nil or empty
+func derefOr(value *string, fallback string) string {
+	if value == nil || *value == "" {
+		return fallback
+	}
+	return *value
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"url-shortener-api/db"
+	"url-shortener-api/middleware"
+	"url-shortener-api/models"
+	"url-shortener-api/stream"
+	"url-shortener-api/targeting"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Click stream timing: keepalives stop proxies closing idle connections, and workspace
+// memberships and suspension are rechecked so removed members and suspended users stop
+// receiving events
+const (
+	streamKeepalive       = 25 * time.Second
+	streamMembershipCheck = time.Minute
+	streamRetryMs         = 5000
+)
+
+// clickStreamEvent builds the event live dashboards receive for a recorded click
+func clickStreamEvent(link *models.Link, click *models.ClickEvent) models.ClickStreamEvent {
+	event := models.ClickStreamEvent{
+		ID:          click.ID,
+		LinkID:      link.ID,
+		WorkspaceID: link.WorkspaceID,
+		Slug:        link.Slug,
+		Timestamp:   click.Timestamp,
+		Country:     click.Country,
+		Source:      click.Source,
+		RuleID:      click.RuleID,
+		VariantID:   click.VariantID,
+		UTMSource:   click.UTMSource,
+		UTMMedium:   click.UTMMedium,
+		UTMCampaign: click.UTMCampaign,
+	}
+	if click.Device != nil {
+		event.OS, event.DeviceType = targeting.ParseUserAgent(*click.Device)
+	}
+	return event
+}
+
+// memberWorkspaceIDs returns the workspaces a user belongs to
+func memberWorkspaceIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := db.DB.SelectContext(ctx, &ids, "SELECT workspace_id FROM workspace_members WHERE user_id = $1", userID)
+	return ids, err
+}
+
+// CreateStreamToken issues a short-lived token for opening the click stream with EventSource,
+// which can't send an Authorization header: GET /api/stream/clicks?token=...
+func CreateStreamToken(c *gin.Context) {
+	token, expiresAt, err := middleware.IssueStreamToken(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create stream token"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"token": token, "expiresAt": expiresAt})
+}
+
+// StreamClicks pushes the user's click events live as Server-Sent Events. ?linkId= (repeated or
+// comma-separated) limits the stream to some links. Each event is named "click", carries the
+// click ID as its SSE id and a models.ClickStreamEvent as JSON data. The stream ends with an
+// "expired" event when the session it was opened with expires or the account is suspended.
+func StreamClicks(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	var linkIDs []uuid.UUID
+	for _, value := range c.QueryArray("linkId") {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part == "" {
+				continue
+			}
+			id, err := uuid.Parse(part)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid link ID format"})
+				return
+			}
+			role, err := linkRole(id, *userID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+				return
+			}
+			if role == "" {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Link not found or access denied"})
+				return
+			}
+			linkIDs = append(linkIDs, id)
+		}
+	}
+
+	ctx := c.Request.Context()
+	workspaceIDs, err := memberWorkspaceIDs(ctx, *userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	sub := stream.Subscribe(workspaceIDs, linkIDs)
+	defer sub.Close()
+
+	keepalive := time.NewTicker(streamKeepalive)
+	defer keepalive.Stop()
+	membership := time.NewTicker(streamMembershipCheck)
+	defer membership.Stop()
+
+	// Never outlive the session the stream was opened with
+	var sessionEnd <-chan time.Time
+	if expiresAt := middleware.GetSessionExpiry(c); expiresAt != nil {
+		timer := time.NewTimer(time.Until(*expiresAt))
+		defer timer.Stop()
+		sessionEnd = timer.C
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// Stop nginx and similar proxies from buffering the stream
+	c.Header("X-Accel-Buffering", "no")
+	c.Render(http.StatusOK, sse.Event{Event: "ready", Retry: streamRetryMs, Data: gin.H{"linkIds": linkIDs}})
+	c.Writer.Flush()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case event := <-sub.C:
+			c.Render(-1, sse.Event{Id: event.ID.String(), Event: "click", Data: event})
+		case <-keepalive.C:
+			c.Render(-1, sse.Event{Event: "ping", Data: time.Now().Unix()})
+		case <-sessionEnd:
+			c.Render(-1, sse.Event{Event: "expired", Data: "session expired"})
+			return false
+		case <-membership.C:
+			if middleware.IsSuspended(*userID) {
+				c.Render(-1, sse.Event{Event: "expired", Data: "account suspended"})
+				return false
+			}
+			if ids, err := memberWorkspaceIDs(ctx, *userID); err == nil {
+				sub.SetWorkspaces(ids)
+			}
+		}
+		return true
+	})
+}
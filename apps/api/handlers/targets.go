@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"sync"
+	"time"
+	"url-shortener-api/models"
+	"url-shortener-api/stream"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// linkTargetsChannel is the Postgres NOTIFY channel a link's ID is published on when what a
+// redirect needs to pick its destination changes. An empty payload empties the whole cache.
+const linkTargetsChannel = "link_targets"
+
+// linkTargetsTTL bounds how long a cached entry is used. Changes reach every replica through
+// linkTargetsChannel when they commit; the TTL only matters if a notification goes missing.
+const linkTargetsTTL = 5 * time.Minute
+
+// maxCachedLinkTargets bounds the redirect cache. It is emptied when it fills up.
+const maxCachedLinkTargets = 10000
+
+// linkTargets is what a redirect needs besides the link itself to pick the destination
+type linkTargets struct {
+	rules      []models.LinkRule
+	variants   []models.LinkVariant
+	template   *models.UTMTemplate
+	templateID *uuid.UUID
+	expiresAt  time.Time
+}
+
+var (
+	linkTargetsMu    sync.RWMutex
+	linkTargetsCache = map[uuid.UUID]*linkTargets{}
+)
+
+// WatchLinkTargets keeps the redirect cache in step with changes made on other replicas.
+// Call it before stream.Start.
+func WatchLinkTargets() {
+	stream.Listen(linkTargetsChannel, func(payload string) {
+		id, err := uuid.Parse(payload)
+		if err != nil {
+			// Empty after a reconnect, when notifications may have been missed
+			invalidateAllLinkTargets()
+			return
+		}
+		invalidateLinkTargets(id)
+	})
+}
+
+// loadLinkTargets returns what a redirect needs to pick a link's destination, cached so
+// popular links don't cost extra queries on every redirect
+func loadLinkTargets(link *models.Link) (*linkTargets, error) {
+	now := time.Now()
+	linkTargetsMu.RLock()
+	cached, ok := linkTargetsCache[link.ID]
+	linkTargetsMu.RUnlock()
+	if ok && now.Before(cached.expiresAt) && sameUUID(cached.templateID, link.UTMTemplateID) {
+		return cached, nil
+	}
+
+	targets := &linkTargets{templateID: link.UTMTemplateID, expiresAt: now.Add(linkTargetsTTL)}
+	var err error
+	if targets.rules, err = loadLinkRules(link.ID); err != nil {
+		return nil, err
+	}
+	if targets.variants, err = loadLinkVariants(link.ID); err != nil {
+		return nil, err
+	}
+	if link.UTMTemplateID != nil {
+		if targets.template, err = loadUTMTemplate(*link.UTMTemplateID); err != nil {
+			return nil, err
+		}
+	}
+
+	linkTargetsMu.Lock()
+	if len(linkTargetsCache) >= maxCachedLinkTargets {
+		linkTargetsCache = map[uuid.UUID]*linkTargets{}
+	}
+	linkTargetsCache[link.ID] = targets
+	linkTargetsMu.Unlock()
+
+	return targets, nil
+}
+
+// notifyLinkTargets tells every replica, once tx commits, to drop a link from its redirect
+// cache. A nil linkID drops every link, for changes shared by many links.
+func notifyLinkTargets(tx sqlx.Execer, linkID *uuid.UUID) error {
+	payload := ""
+	if linkID != nil {
+		payload = linkID.String()
+	}
+	_, err := tx.Exec("SELECT pg_notify($1, $2)", linkTargetsChannel, payload)
+	return err
+}
+
+// invalidateLinkTargets drops a link from this replica's redirect cache
+func invalidateLinkTargets(linkID uuid.UUID) {
+	linkTargetsMu.Lock()
+	delete(linkTargetsCache, linkID)
+	linkTargetsMu.Unlock()
+}
+
+// invalidateAllLinkTargets empties this replica's redirect cache
+func invalidateAllLinkTargets() {
+	linkTargetsMu.Lock()
+	linkTargetsCache = map[uuid.UUID]*linkTargets{}
+	linkTargetsMu.Unlock()
+}
+
+func sameUUID(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
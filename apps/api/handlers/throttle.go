@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"url-shortener-api/db"
+	"url-shortener-api/middleware"
+	"url-shortener-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Attempt scopes used for failed-attempt tracking
+const (
+	scopeAccount = "account"
+	scopeIP      = "ip"
+	scopeSlug    = "slug"
+)
+
+// maxLockout caps how long progressive lockouts can grow
+const maxLockout = 24 * time.Hour
+
+// attemptKey identifies a single failed-attempt counter
+type attemptKey struct {
+	Scope string
+	Key   string
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+// compareDummyPassword burns the same bcrypt cost as a real comparison so that
+// unknown accounts cannot be distinguished from wrong passwords by timing
+func compareDummyPassword(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not-a-real-password"), bcrypt.DefaultCost)
+	})
+	bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
+
+// attemptBackoff returns how long a key stays locked after the given number of failures.
+// The first two failures are free, then the delay doubles each time until the
+// configured maximum is reached, after which a full lockout kicks in and keeps doubling.
+func attemptBackoff(failures int) time.Duration {
+	maxAttempts := utils.AppConfig.MaxFailedAttempts
+	if failures < 3 {
+		return 0
+	}
+
+	var delay time.Duration
+	if failures < maxAttempts {
+		delay = time.Duration(math.Pow(2, float64(failures-2))) * time.Second
+	} else {
+		lockout := time.Duration(utils.AppConfig.LockoutMinutes) * time.Minute
+		delay = lockout * time.Duration(math.Pow(2, float64(failures-maxAttempts)))
+	}
+
+	if delay <= 0 || delay > maxLockout {
+		return maxLockout
+	}
+	return delay
+}
+
+// maxTrackedFailures is how many failures attemptDelays covers; later failures use its last delay
+const maxTrackedFailures = 64
+
+// attemptDelays lists attemptBackoff for 1 to maxTrackedFailures failures in milliseconds. The
+// database picks the delay for the new count, so a counter and its lock change in one statement.
+func attemptDelays() pq.Int64Array {
+	delays := make(pq.Int64Array, maxTrackedFailures)
+	for i := range delays {
+		delays[i] = attemptBackoff(i + 1).Milliseconds()
+	}
+	return delays
+}
+
+// beginAttempt counts an attempt against every key before it is checked, locking keys whose
+// count calls for it, so concurrent guesses can't all slip in before a lockout is written.
+// Each attempt counts as a failure until clearFailedAttempts or releaseAttempt says otherwise.
+// If any key is still locked, nothing is counted and the longest remaining lock is returned.
+func beginAttempt(keys ...attemptKey) (time.Duration, error) {
+	now := time.Now()
+	windowStart := now.Add(-time.Duration(utils.AppConfig.AttemptWindow) * time.Minute)
+	delays := attemptDelays()
+
+	var claimed []attemptKey
+	var remaining time.Duration
+	for _, k := range keys {
+		var failures int
+		err := db.DB.Get(&failures, `
+			INSERT INTO failed_attempts (scope, key, failures, last_failure, locked_until)
+			VALUES ($1, $2, 1, $3, $3::timestamp + ($5::bigint[])[1] * INTERVAL '1 millisecond')
+			ON CONFLICT (scope, key) DO UPDATE SET
+				failures = CASE
+					WHEN failed_attempts.last_failure < $4 THEN 1
+					ELSE failed_attempts.failures + 1
+				END,
+				last_failure = $3,
+				locked_until = $3::timestamp + ($5::bigint[])[CASE
+					WHEN failed_attempts.last_failure < $4 THEN 1
+					ELSE LEAST(failed_attempts.failures + 1, $6)
+				END] * INTERVAL '1 millisecond'
+			WHERE failed_attempts.locked_until IS NULL OR failed_attempts.locked_until <= $3
+			RETURNING failures
+		`, k.Scope, k.Key, now, windowStart, delays, maxTrackedFailures)
+		if err == nil {
+			claimed = append(claimed, k)
+			continue
+		} else if err != sql.ErrNoRows {
+			releaseAttempt(claimed...)
+			return 0, err
+		}
+
+		// The key is locked, so the row was left alone
+		var lockedUntil *time.Time
+		err = db.DB.Get(&lockedUntil,
+			"SELECT locked_until FROM failed_attempts WHERE scope = $1 AND key = $2", k.Scope, k.Key)
+		if err != nil && err != sql.ErrNoRows {
+			releaseAttempt(claimed...)
+			return 0, err
+		}
+		// A lock lifted in the meantime still refuses this attempt for a moment
+		wait := time.Second
+		if lockedUntil != nil && lockedUntil.Sub(now) > wait {
+			wait = lockedUntil.Sub(now)
+		}
+		if wait > remaining {
+			remaining = wait
+		}
+	}
+
+	if remaining > 0 {
+		releaseAttempt(claimed...)
+	}
+	return remaining, nil
+}
+
+// releaseAttempt takes back an attempt counted by beginAttempt that didn't fail, without
+// forgiving earlier failures, and relaxes the lock to what the remaining count calls for
+func releaseAttempt(keys ...attemptKey) {
+	delays := attemptDelays()
+	for _, k := range keys {
+		_, err := db.DB.Exec(`
+			UPDATE failed_attempts SET
+				failures = failures - 1,
+				locked_until = CASE
+					WHEN failures > 1 THEN last_failure + ($3::bigint[])[LEAST(failures - 1, $4)] * INTERVAL '1 millisecond'
+				END
+			WHERE scope = $1 AND key = $2 AND failures > 0
+		`, k.Scope, k.Key, delays, maxTrackedFailures)
+		if err != nil {
+			fmt.Printf("Error releasing attempt: %v\n", err)
+		}
+	}
+}
+
+// clearFailedAttempts forgives every failure of the given keys after a successful attempt
+func clearFailedAttempts(keys ...attemptKey) {
+	for _, k := range keys {
+		_, err := db.DB.Exec("DELETE FROM failed_attempts WHERE scope = $1 AND key = $2", k.Scope, k.Key)
+		if err != nil {
+			fmt.Printf("Error clearing failed attempts: %v\n", err)
+		}
+	}
+}
+
+// respondLocked writes a 429 response with a Retry-After header
+func respondLocked(c *gin.Context, remaining time.Duration) {
+	seconds := int(math.Ceil(remaining.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":      "Too many failed attempts, please try again later",
+		"retryAfter": seconds,
+	})
+}
+
+// writeAuditLog records a security-relevant event
+func writeAuditLog(c *gin.Context, action string, target string, success bool, details string) {
+	// Attribute actions taken through an impersonation token to the admin as well
+	if impersonatorID := middleware.GetImpersonatorID(c); impersonatorID != nil {
+		details = strings.TrimSpace(fmt.Sprintf("%s (impersonated by %s)", details, impersonatorID))
+	}
+
+	var detailsPtr *string
+	if details != "" {
+		detailsPtr = &details
+	}
+
+	_, err := db.DB.Exec(`
+		INSERT INTO audit_log (action, actor_id, target, ip, user_agent, success, details)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, action, middleware.GetUserID(c), target, c.ClientIP(), c.Request.UserAgent(), success, detailsPtr)
+	if err != nil {
+		fmt.Printf("Error writing audit log: %v\n", err)
+	}
+}
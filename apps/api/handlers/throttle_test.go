@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+	"url-shortener-api/utils"
+)
+
+func TestAttemptDelays(t *testing.T) {
+	utils.AppConfig.MaxFailedAttempts = 5
+	utils.AppConfig.LockoutMinutes = 15
+
+	delays := attemptDelays()
+	if len(delays) != maxTrackedFailures {
+		t.Fatalf("%d delays, want %d", len(delays), maxTrackedFailures)
+	}
+	for i, delay := range delays {
+		if want := attemptBackoff(i + 1).Milliseconds(); delay != want {
+			t.Errorf("delay after %d failures = %dms, want %dms", i+1, delay, want)
+		}
+		if i > 0 && delay < delays[i-1] {
+			t.Errorf("delay after %d failures shrinks to %dms", i+1, delay)
+		}
+	}
+	if last := delays[len(delays)-1]; last != maxLockout.Milliseconds() {
+		t.Errorf("last delay = %dms, want the %v cap", last, maxLockout)
+	}
+}
+
+func TestBeginAttempt(t *testing.T) {
+	account := attemptKey{Scope: scopeAccount, Key: "user@example.com"}
+	ip := attemptKey{Scope: scopeIP, Key: "203.0.113.7"}
+
+	// locked answers the attempt counter upsert for unlocked keys and leaves locked ones alone
+	locked := func(lockedUntil map[string]time.Time) func(string, []driver.Value) fakeResult {
+		return func(query string, args []driver.Value) fakeResult {
+			switch {
+			case strings.Contains(query, "INSERT INTO failed_attempts"):
+				if _, ok := lockedUntil[args[0].(string)]; ok {
+					return fakeResult{}
+				}
+				return row([]string{"failures"}, int64(3))
+			case strings.Contains(query, "SELECT locked_until"):
+				if until, ok := lockedUntil[args[0].(string)]; ok {
+					return row([]string{"locked_until"}, until)
+				}
+			}
+			return fakeResult{affected: 1}
+		}
+	}
+
+	t.Run("unlocked", func(t *testing.T) {
+		fake := useFakeDB(t, locked(nil))
+		remaining, err := beginAttempt(account, ip)
+		if err != nil || remaining != 0 {
+			t.Fatalf("beginAttempt() = %v, %v, want no lock", remaining, err)
+		}
+		if counted := fake.executed("INSERT INTO failed_attempts"); len(counted) != 2 {
+			t.Errorf("attempt counted against %d keys, want 2", len(counted))
+		}
+		if released := fake.executed("UPDATE failed_attempts"); len(released) != 0 {
+			t.Errorf("attempt released: %v", released)
+		}
+	})
+
+	t.Run("locked", func(t *testing.T) {
+		fake := useFakeDB(t, locked(map[string]time.Time{scopeIP: time.Now().Add(10 * time.Minute)}))
+		remaining, err := beginAttempt(account, ip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if remaining < 9*time.Minute || remaining > 10*time.Minute {
+			t.Errorf("remaining = %v, want about 10m", remaining)
+		}
+
+		// The refused attempt is taken back from the account it was already counted against
+		released := fake.executed("UPDATE failed_attempts")
+		if len(released) != 1 || released[0].args[0] != scopeAccount {
+			t.Errorf("released %v, want the account key only", released)
+		}
+	})
+
+	t.Run("lock lifted meanwhile", func(t *testing.T) {
+		// Neither the upsert nor the lookup finds the row
+		useFakeDB(t, nil)
+		remaining, err := beginAttempt(account)
+		if err != nil || remaining <= 0 {
+			t.Errorf("beginAttempt() = %v, %v, want a short lock", remaining, err)
+		}
+	})
+}
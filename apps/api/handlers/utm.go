@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"url-shortener-api/db"
+	"url-shortener-api/middleware"
+	"url-shortener-api/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// utmParams lists the UTM query parameters in the order they are added to destinations
+var utmParams = []string{"utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content"}
+
+// templateParams returns a template's UTM parameters keyed by query parameter name
+func templateParams(template *models.UTMTemplate) map[string]*string {
+	return map[string]*string{
+		"utm_source":   template.Source,
+		"utm_medium":   template.Medium,
+		"utm_campaign": template.Campaign,
+		"utm_term":     template.Term,
+		"utm_content":  template.Content,
+	}
+}
+
+// linkUTMTemplate returns the UTM template attached to a link, or nil if it has none
+func linkUTMTemplate(link *models.Link) *models.UTMTemplate {
+	targets, err := loadLinkTargets(link)
+	if err != nil {
+		fmt.Printf("Error loading UTM template: %v\n", err)
+		return nil
+	}
+	return targets.template
+}
+
+// loadUTMTemplate returns a template by ID, or nil if it was deleted
+func loadUTMTemplate(id uuid.UUID) (*models.UTMTemplate, error) {
+	var template models.UTMTemplate
+	if err := db.DB.Get(&template, "SELECT * FROM utm_templates WHERE id = $1", id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &template, nil
+}
+
+// applyUTMTemplate adds the link's UTM template parameters to the destination. Parameters
+// already present in the destination are kept as they are, and the existing query string is
+// left untouched so its order and encoding still match what the destination expects.
+func applyUTMTemplate(destination string, template *models.UTMTemplate) string {
+	if template == nil {
+		return destination
+	}
+
+	target, err := url.Parse(destination)
+	if err != nil {
+		return destination
+	}
+
+	query := target.Query()
+	params := templateParams(template)
+	added := []string{}
+	for _, key := range utmParams {
+		if value := params[key]; value != nil && *value != "" && !query.Has(key) {
+			added = append(added, url.QueryEscape(key)+"="+url.QueryEscape(*value))
+		}
+	}
+	if len(added) == 0 {
+		return destination
+	}
+
+	if target.RawQuery != "" && !strings.HasSuffix(target.RawQuery, "&") {
+		target.RawQuery += "&"
+	}
+	target.RawQuery += strings.Join(added, "&")
+
+	return target.String()
+}
+
+// campaignValues extracts the UTM source, medium and campaign from a destination URL
+func campaignValues(destination string) (source *string, medium *string, campaign *string) {
+	target, err := url.Parse(destination)
+	if err != nil {
+		return nil, nil, nil
+	}
+
+	query := target.Query()
+	value := func(key string) *string {
+		if v := query.Get(key); v != "" {
+			return &v
+		}
+		return nil
+	}
+	return value("utm_source"), value("utm_medium"), value("utm_campaign")
+}
+
+// utmTemplateRole returns the caller's access to a template: "owner" for their personal
+// templates, their workspace role for shared ones, or "" for none
+func utmTemplateRole(template *models.UTMTemplate, userID uuid.UUID) (string, error) {
+	if template.WorkspaceID == nil {
+		if template.UserID != nil && *template.UserID == userID {
+			return models.RoleOwner, nil
+		}
+		return "", nil
+	}
+	return workspaceRole(*template.WorkspaceID, userID)
+}
+
+// canUseUTMTemplate reports whether the user may attach the template to a link
+func canUseUTMTemplate(templateID uuid.UUID, userID uuid.UUID) (bool, error) {
+	var template models.UTMTemplate
+	err := db.DB.Get(&template, "SELECT * FROM utm_templates WHERE id = $1", templateID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	role, err := utmTemplateRole(&template, userID)
+	return role != "", err
+}
+
+// loadUTMTemplateForEdit loads the :id template if the caller may change it. It writes an
+// error response and returns nil otherwise.
+func loadUTMTemplateForEdit(c *gin.Context) *models.UTMTemplate {
+	userID := middleware.GetUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return nil
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID format"})
+		return nil
+	}
+
+	var template models.UTMTemplate
+	err = db.DB.Get(&template, "SELECT * FROM utm_templates WHERE id = $1", id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found or access denied"})
+		return nil
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return nil
+	}
+
+	role, err := utmTemplateRole(&template, *userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return nil
+	}
+	if role == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found or access denied"})
+		return nil
+	}
+	if !roleAtLeast(role, models.RoleEditor) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient workspace role"})
+		return nil
+	}
+
+	return &template
+}
+
+// emptyToNil turns an empty string into nil so cleared parameters are stored as NULL
+func emptyToNil(value *string) *string {
+	if value == nil || strings.TrimSpace(*value) == "" {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*value)
+	return &trimmed
+}
+
+// GetUTMTemplates lists the user's personal templates and those of their workspaces
+func GetUTMTemplates(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	templates := []models.UTMTemplate{}
+	err := db.DB.Select(&templates, `
+		SELECT * FROM utm_templates
+		WHERE (workspace_id IS NULL AND user_id = $1)
+			OR workspace_id IN (SELECT workspace_id FROM workspace_members WHERE user_id = $1)
+		ORDER BY name
+	`, *userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve templates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, templates)
+}
+
+// CreateUTMTemplate creates a personal or workspace UTM template
+func CreateUTMTemplate(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	var req models.CreateUTMTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if req.WorkspaceID != nil {
+		role, err := workspaceRole(*req.WorkspaceID, *userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if !roleAtLeast(role, models.RoleEditor) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient workspace role"})
+			return
+		}
+	}
+
+	template := models.UTMTemplate{
+		ID:          uuid.New(),
+		Name:        strings.TrimSpace(req.Name),
+		UserID:      userID,
+		WorkspaceID: req.WorkspaceID,
+		Source:      emptyToNil(req.Source),
+		Medium:      emptyToNil(req.Medium),
+		Campaign:    emptyToNil(req.Campaign),
+		Term:        emptyToNil(req.Term),
+		Content:     emptyToNil(req.Content),
+		CreatedAt:   time.Now(),
+	}
+
+	_, err := db.DB.Exec(`
+		INSERT INTO utm_templates (id, name, user_id, workspace_id, utm_source, utm_medium, utm_campaign, utm_term, utm_content, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, template.ID, template.Name, template.UserID, template.WorkspaceID, template.Source,
+		template.Medium, template.Campaign, template.Term, template.Content, template.CreatedAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create template"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
+
+// UpdateUTMTemplate changes a template's name or parameters
+func UpdateUTMTemplate(c *gin.Context) {
+	template := loadUTMTemplateForEdit(c)
+	if template == nil {
+		return
+	}
+
+	var req models.UpdateUTMTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if req.Name != nil {
+		template.Name = strings.TrimSpace(*req.Name)
+	}
+	for _, field := range []struct {
+		value  *string
+		target **string
+	}{
+		{req.Source, &template.Source},
+		{req.Medium, &template.Medium},
+		{req.Campaign, &template.Campaign},
+		{req.Term, &template.Term},
+		{req.Content, &template.Content},
+	} {
+		if field.value != nil {
+			*field.target = emptyToNil(field.value)
+		}
+	}
+
+	_, err := db.DB.Exec(`
+		UPDATE utm_templates
+		SET name = $1, utm_source = $2, utm_medium = $3, utm_campaign = $4, utm_term = $5, utm_content = $6
+		WHERE id = $7
+	`, template.Name, template.Source, template.Medium, template.Campaign, template.Term, template.Content, template.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update template"})
+		return
+	}
+	refreshUTMTemplateTargets()
+
+	c.JSON(http.StatusOK, template)
+}
+
+// refreshUTMTemplateTargets empties the redirect cache of every replica after a template
+// changes, since any number of links may use it
+func refreshUTMTemplateTargets() {
+	invalidateAllLinkTargets()
+	if err := notifyLinkTargets(db.DB, nil); err != nil {
+		fmt.Printf("Error notifying replicas of a UTM template change: %v\n", err)
+	}
+}
+
+// DeleteUTMTemplate deletes a template. Links using it keep working without UTM parameters.
+func DeleteUTMTemplate(c *gin.Context) {
+	template := loadUTMTemplateForEdit(c)
+	if template == nil {
+		return
+	}
+
+	if _, err := db.DB.Exec("DELETE FROM utm_templates WHERE id = $1", template.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete template"})
+		return
+	}
+	refreshUTMTemplateTargets()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Template deleted successfully"})
+}
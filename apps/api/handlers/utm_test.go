@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"testing"
+	"url-shortener-api/models"
+)
+
+func TestApplyUTMTemplate(t *testing.T) {
+	source, medium, campaign := "newsletter", "email", "spring sale"
+	template := &models.UTMTemplate{Source: &source, Medium: &medium, Campaign: &campaign}
+
+	tests := []struct {
+		name        string
+		destination string
+		template    *models.UTMTemplate
+		want        string
+	}{
+		{"no template", "https://example.com/?b=2&a=1", nil, "https://example.com/?b=2&a=1"},
+		{"no query", "https://example.com/page",
+			template, "https://example.com/page?utm_source=newsletter&utm_medium=email&utm_campaign=spring+sale"},
+		{"existing order and encoding kept", "https://example.com/?z=1&a=%7e&sig=a%2Bb",
+			template, "https://example.com/?z=1&a=%7e&sig=a%2Bb&utm_source=newsletter&utm_medium=email&utm_campaign=spring+sale"},
+		{"present parameters kept", "https://example.com/?utm_source=ads&utm_medium=",
+			template, "https://example.com/?utm_source=ads&utm_medium=&utm_campaign=spring+sale"},
+		{"all present", "https://example.com/?utm_campaign=x&utm_medium=y&utm_source=z",
+			template, "https://example.com/?utm_campaign=x&utm_medium=y&utm_source=z"},
+		{"fragment kept last", "https://example.com/?a=1#section",
+			template, "https://example.com/?a=1&utm_source=newsletter&utm_medium=email&utm_campaign=spring+sale#section"},
+		{"trailing ampersand", "https://example.com/?a=1&",
+			template, "https://example.com/?a=1&utm_source=newsletter&utm_medium=email&utm_campaign=spring+sale"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := applyUTMTemplate(tt.destination, tt.template); got != tt.want {
+				t.Errorf("applyUTMTemplate(%q) = %q, want %q", tt.destination, got, tt.want)
+			}
+		})
+	}
+}
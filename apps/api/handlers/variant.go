@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+	"url-shortener-api/db"
+	"url-shortener-api/models"
+	"url-shortener-api/screening"
+	"url-shortener-api/targeting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// variantCookiePrefix names the cookie that keeps a visitor on the same A/B variant
+const variantCookiePrefix = "variant_"
+
+// variantCookieMaxAge is how long a variant assignment is remembered, in seconds
+const variantCookieMaxAge = 30 * 24 * 60 * 60
+
+// loadLinkVariants returns a link's A/B variants in order
+func loadLinkVariants(linkID uuid.UUID) ([]models.LinkVariant, error) {
+	variants := []models.LinkVariant{}
+	err := db.DB.Select(&variants, "SELECT * FROM link_variants WHERE link_id = $1 ORDER BY position", linkID)
+	return variants, err
+}
+
+// assignVariant picks the A/B variant for this visitor, or nil if the link has no variants.
+// A previous assignment is kept through a cookie; new visitors are bucketed by a hash of
+// their IP so they stay on the same variant even without cookies.
+func assignVariant(c *gin.Context, link *models.Link) *models.LinkVariant {
+	targets, err := loadLinkTargets(link)
+	if err != nil {
+		fmt.Printf("Error loading link variants: %v\n", err)
+		return nil
+	}
+
+	cookieName := variantCookiePrefix + link.ID.String()
+	assigned, _ := c.Cookie(cookieName)
+	variant, isNew := chooseVariant(targets.variants, assigned, link.ID.String()+"|"+c.ClientIP())
+	if isNew {
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(cookieName, variant.ID.String(), variantCookieMaxAge, "/", "", c.Request.TLS != nil, true)
+	}
+	return variant
+}
+
+// chooseVariant keeps the variant named by the visitor's cookie while it still exists and
+// otherwise buckets the visitor by key. isNew reports whether the cookie should be set.
+func chooseVariant(variants []models.LinkVariant, assigned string, key string) (variant *models.LinkVariant, isNew bool) {
+	if assigned != "" {
+		for i := range variants {
+			if variants[i].ID.String() == assigned {
+				return &variants[i], false
+			}
+		}
+	}
+
+	variant = targeting.PickVariant(variants, key)
+	return variant, variant != nil
+}
+
+// GetLinkVariants lists a link's A/B variants
+func GetLinkVariants(c *gin.Context) {
+	link := loadLinkForRole(c, models.RoleEditor)
+	if link == nil {
+		return
+	}
+
+	variants, err := loadLinkVariants(link.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve variants"})
+		return
+	}
+
+	c.JSON(http.StatusOK, variants)
+}
+
+// SetLinkVariants replaces a link's A/B variants. Variants sent with their ID are updated in
+// place so their click history is kept.
+func SetLinkVariants(c *gin.Context) {
+	link := loadLinkForRole(c, models.RoleEditor)
+	if link == nil {
+		return
+	}
+
+	var req models.SetLinkVariantsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	existing, err := loadLinkVariants(link.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	existingIDs := map[uuid.UUID]bool{}
+	for _, variant := range existing {
+		existingIDs[variant.ID] = true
+	}
+
+	for _, input := range req.Variants {
+		if input.ID != nil && !existingIDs[*input.ID] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown variant ID " + input.ID.String()})
+			return
+		}
+
+		// Variant destinations go through the same screening as the original URL
+		verdict := screening.Default.Screen(c.Request.Context(), input.Destination)
+		if verdict.Blocked() {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Destination URL is not allowed", "reason": verdict.Reason})
+			return
+		}
+	}
+
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	variants := make([]models.LinkVariant, len(req.Variants))
+	keep := []string{}
+	for i, input := range req.Variants {
+		variants[i] = models.LinkVariant{
+			ID:          uuid.New(),
+			LinkID:      link.ID,
+			Position:    i,
+			Name:        input.Name,
+			Destination: input.Destination,
+			Weight:      input.Weight,
+			CreatedAt:   now,
+		}
+
+		if input.ID != nil {
+			variants[i].ID = *input.ID
+			_, err = tx.Exec(
+				"UPDATE link_variants SET position = $1, name = $2, destination = $3, weight = $4 WHERE id = $5 AND link_id = $6",
+				i, input.Name, input.Destination, input.Weight, *input.ID, link.ID)
+		} else {
+			_, err = tx.Exec(`
+				INSERT INTO link_variants (id, link_id, position, name, destination, weight, created_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7)
+			`, variants[i].ID, variants[i].LinkID, variants[i].Position, variants[i].Name,
+				variants[i].Destination, variants[i].Weight, variants[i].CreatedAt)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update variants"})
+			return
+		}
+		keep = append(keep, variants[i].ID.String())
+	}
+
+	_, err = tx.Exec("DELETE FROM link_variants WHERE link_id = $1 AND NOT (id::text = ANY($2))", link.ID, pq.Array(keep))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update variants"})
+		return
+	}
+
+	if _, err := tx.Exec("UPDATE links SET last_updated = $1 WHERE id = $2", now, link.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update variants"})
+		return
+	}
+
+	if err := notifyLinkTargets(tx, &link.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update variants"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update variants"})
+		return
+	}
+	invalidateLinkTargets(link.ID)
+
+	// Reload so kept variants report their original creation time
+	if saved, err := loadLinkVariants(link.ID); err == nil {
+		variants = saved
+	}
+
+	c.JSON(http.StatusOK, variants)
+}
+
+// GetLinkStats returns click totals for a link, broken down per A/B variant and UTM campaign
+func GetLinkStats(c *gin.Context) {
+	link := loadLinkForRole(c, models.RoleViewer)
+	if link == nil {
+		return
+	}
+
+	var stats models.LinkStats
+	err := db.DB.Get(&stats, `
+		SELECT COUNT(*) AS total_clicks, COUNT(DISTINCT ip) AS unique_clicks,
+			COUNT(*) FILTER (WHERE source = 'qr') AS qr_scans
+		FROM click_events
+		WHERE link_id = $1
+	`, link.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get link stats"})
+		return
+	}
+
+	stats.Variants = []models.VariantStats{}
+	err = db.DB.Select(&stats.Variants, `
+		SELECT v.id, v.name, v.destination, v.weight,
+			COUNT(ce.id) AS clicks, COUNT(DISTINCT ce.ip) AS unique_clicks
+		FROM link_variants v
+		LEFT JOIN click_events ce ON ce.variant_id = v.id
+		WHERE v.link_id = $1
+		GROUP BY v.id
+		ORDER BY v.position
+	`, link.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get link stats"})
+		return
+	}
+
+	stats.Campaigns = []models.CampaignStats{}
+	err = db.DB.Select(&stats.Campaigns, `
+		SELECT COALESCE(utm_source, '') AS utm_source, COALESCE(utm_medium, '') AS utm_medium,
+			COALESCE(utm_campaign, '') AS utm_campaign, COUNT(*) AS clicks
+		FROM click_events
+		WHERE link_id = $1 AND (utm_source IS NOT NULL OR utm_medium IS NOT NULL OR utm_campaign IS NOT NULL)
+		GROUP BY 1, 2, 3
+		ORDER BY clicks DESC
+	`, link.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get link stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"testing"
+	"url-shortener-api/models"
+	"url-shortener-api/targeting"
+
+	"github.com/google/uuid"
+)
+
+func TestChooseVariant(t *testing.T) {
+	variants := []models.LinkVariant{
+		{ID: uuid.MustParse("11111111-1111-1111-1111-111111111111"), Position: 0, Weight: 1},
+		{ID: uuid.MustParse("22222222-2222-2222-2222-222222222222"), Position: 1, Weight: 3},
+	}
+	key := "link|203.0.113.7"
+	bucketed := targeting.PickVariant(variants, key).Position
+
+	tests := []struct {
+		name      string
+		variants  []models.LinkVariant
+		assigned  string
+		want      int
+		wantIsNew bool
+	}{
+		{"new visitor", variants, "", bucketed, true},
+		{"cookie reused", variants, variants[0].ID.String(), 0, false},
+		{"cookie reused against the bucket", variants, variants[1-bucketed].ID.String(), 1 - bucketed, false},
+		{"deleted variant", variants, "33333333-3333-3333-3333-333333333333", bucketed, true},
+		{"malformed cookie", variants, "not-a-variant", bucketed, true},
+		{"no variants", nil, variants[0].ID.String(), -1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, isNew := chooseVariant(tt.variants, tt.assigned, key)
+			if isNew != tt.wantIsNew {
+				t.Errorf("chooseVariant() isNew = %v, want %v", isNew, tt.wantIsNew)
+			}
+			switch {
+			case tt.want < 0 && got != nil:
+				t.Errorf("chooseVariant() = %d, want nil", got.Position)
+			case tt.want >= 0 && (got == nil || got.Position != tt.want):
+				t.Errorf("chooseVariant() = %v, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,428 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+	"url-shortener-api/db"
+	"url-shortener-api/middleware"
+	"url-shortener-api/models"
+	"url-shortener-api/utils"
+	"url-shortener-api/webhooks"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// webhookDeliveryPage is how many deliveries GetWebhookDeliveries returns
+const webhookDeliveryPage = 50
+
+// emitLinkEvent queues a link event for the webhooks of the link's workspace in the
+// transaction that changed the link
+func emitLinkEvent(ctx context.Context, tx sqlx.ExecerContext, event string, link *models.Link) error {
+	payload := *link
+	payload.ShortURL = linkShortURL(link)
+	return webhooks.Emit(ctx, tx, link.WorkspaceID, event, payload)
+}
+
+// clickWebhookData builds the data of a click.recorded event
+func clickWebhookData(link *models.Link, click *models.ClickEvent, destination string) gin.H {
+	return gin.H{
+		"click":       click,
+		"destination": destination,
+		"link": gin.H{
+			"id":       link.ID,
+			"slug":     link.Slug,
+			"shortUrl": linkShortURL(link),
+		},
+	}
+}
+
+// StartLinkExpiryEvents sends link.expired for links that pass their expiry date or click
+// limit, checking every interval until ctx is cancelled
+func StartLinkExpiryEvents(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := emitExpiredLinks(ctx); err != nil {
+					log.Printf("Error sending link.expired events: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// emitExpiredLinks marks newly expired links and queues their link.expired events together
+func emitExpiredLinks(ctx context.Context) error {
+	tx, err := db.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var links []models.Link
+	err = tx.SelectContext(ctx, &links, `
+		UPDATE links SET expired_event_at = NOW()
+		WHERE expired_event_at IS NULL AND workspace_id IS NOT NULL
+			AND (expires_at <= NOW() OR (max_clicks IS NOT NULL AND clicks >= max_clicks))
+		RETURNING *
+	`)
+	if err != nil {
+		return err
+	}
+	for i := range links {
+		if err := emitLinkEvent(ctx, tx, models.EventLinkExpired, &links[i]); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if len(links) > 0 {
+		webhooks.Notify()
+	}
+	return nil
+}
+
+// validateWebhookEvents checks that every event can be subscribed to
+func validateWebhookEvents(events []string) error {
+	if len(events) == 0 {
+		return fmt.Errorf("at least one event is required")
+	}
+	for _, event := range events {
+		if !models.WebhookEvents[event] {
+			return fmt.Errorf("unknown event %q", event)
+		}
+	}
+	return nil
+}
+
+// validateWebhookURL checks that a webhook targets an http(s) URL
+func validateWebhookURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("webhook URL must be an http(s) URL")
+	}
+	return nil
+}
+
+// loadWebhookForRole loads the webhook in the :id parameter if the user has at least the
+// given role in its workspace, writing an error response otherwise
+func loadWebhookForRole(c *gin.Context, min string) *models.Webhook {
+	userID := middleware.GetUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return nil
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID format"})
+		return nil
+	}
+
+	var webhook models.Webhook
+	err = db.DB.Get(&webhook, "SELECT * FROM webhooks WHERE id = $1", id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found or access denied"})
+		return nil
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return nil
+	}
+
+	role, err := workspaceRole(webhook.WorkspaceID, *userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return nil
+	}
+	if role == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found or access denied"})
+		return nil
+	}
+	if !roleAtLeast(role, min) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient workspace role"})
+		return nil
+	}
+
+	return &webhook
+}
+
+// CreateWebhook registers a webhook for a workspace. The signing secret is only returned here.
+func CreateWebhook(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	var req models.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	if err := validateWebhookURL(req.URL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateWebhookEvents(req.Events); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Webhooks belong to the requested workspace or the user's personal one
+	var workspaceID uuid.UUID
+	if req.WorkspaceID != nil {
+		role, err := workspaceRole(*req.WorkspaceID, *userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if !roleAtLeast(role, models.RoleAdmin) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient workspace role"})
+			return
+		}
+		workspaceID = *req.WorkspaceID
+	} else {
+		personalID, err := personalWorkspaceID(*userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		workspaceID = personalID
+	}
+
+	secret, err := utils.GenerateToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	sampleRate := 1.0
+	if req.ClickSampleRate != nil {
+		sampleRate = *req.ClickSampleRate
+	}
+
+	now := time.Now()
+	webhook := models.Webhook{
+		ID:              uuid.New(),
+		WorkspaceID:     workspaceID,
+		CreatedBy:       userID,
+		URL:             req.URL,
+		Secret:          secret,
+		Events:          pq.StringArray(req.Events),
+		ClickSampleRate: sampleRate,
+		Active:          true,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+
+	_, err = db.DB.Exec(`
+		INSERT INTO webhooks (id, workspace_id, created_by, url, secret, events, click_sample_rate, active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, webhook.ID, webhook.WorkspaceID, webhook.CreatedBy, webhook.URL, webhook.Secret, webhook.Events,
+		webhook.ClickSampleRate, webhook.Active, webhook.CreatedAt, webhook.UpdatedAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.WebhookWithSecret{Webhook: webhook, Secret: secret})
+}
+
+// GetWebhooks lists the webhooks of workspaces the user administers
+func GetWebhooks(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	webhooks := []models.Webhook{}
+	err := db.DB.Select(&webhooks, `
+		SELECT w.* FROM webhooks w
+		JOIN workspace_members m ON m.workspace_id = w.workspace_id
+		WHERE m.user_id = $1 AND m.role IN ('owner', 'admin')
+		ORDER BY w.created_at
+	`, *userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve webhooks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, webhooks)
+}
+
+// UpdateWebhook changes a webhook's URL, events, click sampling or active state
+func UpdateWebhook(c *gin.Context) {
+	webhook := loadWebhookForRole(c, models.RoleAdmin)
+	if webhook == nil {
+		return
+	}
+
+	var req models.UpdateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if req.URL != nil {
+		if err := validateWebhookURL(*req.URL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		webhook.URL = *req.URL
+	}
+	if req.Events != nil {
+		if err := validateWebhookEvents(req.Events); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		webhook.Events = pq.StringArray(req.Events)
+	}
+	if req.ClickSampleRate != nil {
+		webhook.ClickSampleRate = *req.ClickSampleRate
+	}
+	if req.Active != nil {
+		webhook.Active = *req.Active
+	}
+	webhook.UpdatedAt = time.Now()
+
+	_, err := db.DB.Exec(`
+		UPDATE webhooks SET url = $1, events = $2, click_sample_rate = $3, active = $4, updated_at = $5
+		WHERE id = $6
+	`, webhook.URL, webhook.Events, webhook.ClickSampleRate, webhook.Active, webhook.UpdatedAt, webhook.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// DeleteWebhook removes a webhook along with its delivery log
+func DeleteWebhook(c *gin.Context) {
+	webhook := loadWebhookForRole(c, models.RoleAdmin)
+	if webhook == nil {
+		return
+	}
+
+	if _, err := db.DB.Exec("DELETE FROM webhooks WHERE id = $1", webhook.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// TestWebhook queues a ping event so receivers can be checked end to end
+func TestWebhook(c *gin.Context) {
+	webhook := loadWebhookForRole(c, models.RoleAdmin)
+	if webhook == nil {
+		return
+	}
+
+	deliveryID, err := webhooks.Ping(c.Request.Context(), webhook.ID, gin.H{"webhookId": webhook.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue test event"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"deliveryId": deliveryID})
+}
+
+// GetWebhookDeliveries returns a page of a webhook's delivery log, newest first.
+// ?status= filters by pending, delivered or failed; ?before= pages back by creation time.
+func GetWebhookDeliveries(c *gin.Context) {
+	webhook := loadWebhookForRole(c, models.RoleAdmin)
+	if webhook == nil {
+		return
+	}
+
+	status := c.Query("status")
+	if status != "" && status != models.DeliveryPending && status != models.DeliveryDelivered && status != models.DeliveryFailed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be pending, delivered or failed"})
+		return
+	}
+
+	before := time.Now().Add(time.Minute)
+	if value := c.Query("before"); value != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "before must be an RFC 3339 timestamp"})
+			return
+		}
+		before = parsed
+	}
+
+	limit := webhookDeliveryPage
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > 200 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 200"})
+			return
+		}
+		limit = n
+	}
+
+	deliveries := []models.WebhookDelivery{}
+	err := db.DB.Select(&deliveries, `
+		SELECT * FROM webhook_deliveries
+		WHERE webhook_id = $1 AND ($2 = '' OR status = $2) AND created_at < $3
+		ORDER BY created_at DESC
+		LIMIT $4
+	`, webhook.ID, status, before, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve deliveries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// ReplayWebhookDelivery sends an earlier delivery again as a new delivery
+func ReplayWebhookDelivery(c *gin.Context) {
+	webhook := loadWebhookForRole(c, models.RoleAdmin)
+	if webhook == nil {
+		return
+	}
+
+	deliveryID, err := uuid.Parse(c.Param("deliveryId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery ID format"})
+		return
+	}
+
+	var exists bool
+	err = db.DB.Get(&exists, "SELECT EXISTS(SELECT 1 FROM webhook_deliveries WHERE id = $1 AND webhook_id = $2)",
+		deliveryID, webhook.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		return
+	}
+
+	replayID, err := webhooks.Replay(c.Request.Context(), deliveryID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to replay delivery"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"deliveryId": replayID})
+}
@@ -0,0 +1,534 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"url-shortener-api/db"
+	"url-shortener-api/mailer"
+	"url-shortener-api/middleware"
+	"url-shortener-api/models"
+	"url-shortener-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// invitationTTL is how long a workspace invitation token stays valid
+const invitationTTL = 7 * 24 * time.Hour
+
+// roleAtLeast reports whether role grants at least the permissions of min
+func roleAtLeast(role string, min string) bool {
+	return models.RoleRank[role] >= models.RoleRank[min]
+}
+
+// workspaceRole returns the user's role in a workspace, or an empty string if they are not a member
+func workspaceRole(workspaceID uuid.UUID, userID uuid.UUID) (string, error) {
+	var role string
+	err := db.DB.Get(&role,
+		"SELECT role FROM workspace_members WHERE workspace_id = $1 AND user_id = $2",
+		workspaceID, userID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return role, err
+}
+
+// linkRole returns the user's role in the workspace that owns a link, or an empty string if they have none
+func linkRole(linkID uuid.UUID, userID uuid.UUID) (string, error) {
+	var role string
+	err := db.DB.Get(&role, `
+		SELECT m.role
+		FROM links l
+		JOIN workspace_members m ON m.workspace_id = l.workspace_id
+		WHERE l.id = $1 AND m.user_id = $2
+	`, linkID, userID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return role, err
+}
+
+// createPersonalWorkspace creates a user's personal workspace and owner membership. If a
+// concurrent request created it first, that workspace is returned instead.
+func createPersonalWorkspace(tx *sqlx.Tx, userID uuid.UUID) (uuid.UUID, error) {
+	var workspaceID uuid.UUID
+	err := tx.Get(&workspaceID, `
+		INSERT INTO workspaces (id, name, personal, created_by, created_at) VALUES ($1, $2, TRUE, $3, $4)
+		ON CONFLICT (created_by) WHERE personal DO NOTHING
+		RETURNING id
+	`, uuid.New(), "Personal", userID, time.Now())
+	if err == sql.ErrNoRows {
+		err = tx.Get(&workspaceID, "SELECT id FROM workspaces WHERE created_by = $1 AND personal", userID)
+		return workspaceID, err
+	} else if err != nil {
+		return uuid.Nil, err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO workspace_members (workspace_id, user_id, role) VALUES ($1, $2, $3)",
+		workspaceID, userID, models.RoleOwner)
+	return workspaceID, err
+}
+
+// personalWorkspaceID returns the user's personal workspace, creating it if missing
+func personalWorkspaceID(userID uuid.UUID) (uuid.UUID, error) {
+	var workspaceID uuid.UUID
+	err := db.DB.Get(&workspaceID,
+		"SELECT id FROM workspaces WHERE created_by = $1 AND personal", userID)
+	if err != sql.ErrNoRows {
+		return workspaceID, err
+	}
+
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer tx.Rollback()
+
+	workspaceID, err = createPersonalWorkspace(tx, userID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return workspaceID, tx.Commit()
+}
+
+// requireWorkspaceRole parses the :id workspace param and checks the caller's role.
+// It writes an error response and returns false if access is denied.
+func requireWorkspaceRole(c *gin.Context, min string) (uuid.UUID, uuid.UUID, string, bool) {
+	userID := middleware.GetUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return uuid.Nil, uuid.Nil, "", false
+	}
+
+	workspaceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID format"})
+		return uuid.Nil, uuid.Nil, "", false
+	}
+
+	role, err := workspaceRole(workspaceID, *userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return uuid.Nil, uuid.Nil, "", false
+	}
+	if role == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Workspace not found or access denied"})
+		return uuid.Nil, uuid.Nil, "", false
+	}
+	if !roleAtLeast(role, min) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient workspace role"})
+		return uuid.Nil, uuid.Nil, "", false
+	}
+
+	return workspaceID, *userID, role, true
+}
+
+// CreateWorkspace creates a shared workspace owned by the current user
+func CreateWorkspace(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	var req models.CreateWorkspaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	workspace := models.Workspace{
+		ID:        uuid.New(),
+		Name:      strings.TrimSpace(req.Name),
+		CreatedBy: userID,
+		CreatedAt: time.Now(),
+		Role:      models.RoleOwner,
+	}
+
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"INSERT INTO workspaces (id, name, personal, created_by, created_at) VALUES ($1, $2, FALSE, $3, $4)",
+		workspace.ID, workspace.Name, workspace.CreatedBy, workspace.CreatedAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create workspace"})
+		return
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO workspace_members (workspace_id, user_id, role) VALUES ($1, $2, $3)",
+		workspace.ID, *userID, models.RoleOwner)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create workspace"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create workspace"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, workspace)
+}
+
+// GetWorkspaces lists the workspaces the current user belongs to
+func GetWorkspaces(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	workspaces := make([]models.Workspace, 0)
+	err := db.DB.Select(&workspaces, `
+		SELECT w.id, w.name, w.personal, w.created_by, w.created_at, m.role
+		FROM workspaces w
+		JOIN workspace_members m ON m.workspace_id = w.id
+		WHERE m.user_id = $1
+		ORDER BY w.personal DESC, w.created_at
+	`, *userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve workspaces"})
+		return
+	}
+
+	c.JSON(http.StatusOK, workspaces)
+}
+
+// DeleteWorkspace deletes a shared workspace and its links (owner only)
+func DeleteWorkspace(c *gin.Context) {
+	workspaceID, _, _, ok := requireWorkspaceRole(c, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	result, err := db.DB.Exec("DELETE FROM workspaces WHERE id = $1 AND NOT personal", workspaceID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete workspace"})
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Personal workspaces cannot be deleted"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Workspace deleted successfully"})
+}
+
+// GetWorkspaceMembers lists the members of a workspace
+func GetWorkspaceMembers(c *gin.Context) {
+	workspaceID, _, _, ok := requireWorkspaceRole(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+
+	members := make([]models.WorkspaceMember, 0)
+	err := db.DB.Select(&members, `
+		SELECT m.workspace_id, m.user_id, u.email, m.role, m.created_at
+		FROM workspace_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.workspace_id = $1
+		ORDER BY m.created_at
+	`, workspaceID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve members"})
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+// UpdateWorkspaceMember changes a member's role. Only owners can grant or revoke ownership.
+func UpdateWorkspaceMember(c *gin.Context) {
+	workspaceID, _, callerRole, ok := requireWorkspaceRole(c, models.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	memberID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	var req models.UpdateMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	currentRole, err := workspaceRole(workspaceID, memberID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if currentRole == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+
+	if (currentRole == models.RoleOwner || req.Role == models.RoleOwner) && callerRole != models.RoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only owners can change ownership"})
+		return
+	}
+
+	if currentRole == models.RoleOwner && req.Role != models.RoleOwner {
+		if last, err := isLastOwner(workspaceID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		} else if last {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A workspace must have at least one owner"})
+			return
+		}
+	}
+
+	_, err = db.DB.Exec(
+		"UPDATE workspace_members SET role = $1 WHERE workspace_id = $2 AND user_id = $3",
+		req.Role, workspaceID, memberID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member updated successfully"})
+}
+
+// RemoveWorkspaceMember removes a member. Admins can remove others; anyone can remove themselves.
+func RemoveWorkspaceMember(c *gin.Context) {
+	workspaceID, callerID, callerRole, ok := requireWorkspaceRole(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+
+	memberID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	if memberID != callerID && !roleAtLeast(callerRole, models.RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient workspace role"})
+		return
+	}
+
+	memberRole, err := workspaceRole(workspaceID, memberID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if memberRole == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+
+	if memberRole == models.RoleOwner {
+		if memberID != callerID && callerRole != models.RoleOwner {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only owners can remove owners"})
+			return
+		}
+		if last, err := isLastOwner(workspaceID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		} else if last {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A workspace must have at least one owner"})
+			return
+		}
+	}
+
+	_, err = db.DB.Exec(
+		"DELETE FROM workspace_members WHERE workspace_id = $1 AND user_id = $2",
+		workspaceID, memberID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
+
+// InviteWorkspaceMember creates an email invitation token for a workspace
+func InviteWorkspaceMember(c *gin.Context) {
+	workspaceID, userID, _, ok := requireWorkspaceRole(c, models.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	var req models.InviteMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	var personal bool
+	if err := db.DB.Get(&personal, "SELECT personal FROM workspaces WHERE id = $1", workspaceID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if personal {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Personal workspaces cannot be shared"})
+		return
+	}
+
+	token, err := utils.GenerateToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate invitation"})
+		return
+	}
+
+	invitation := models.WorkspaceInvitation{
+		ID:          uuid.New(),
+		WorkspaceID: workspaceID,
+		Email:       strings.ToLower(strings.TrimSpace(req.Email)),
+		Role:        req.Role,
+		TokenHash:   utils.HashToken(token),
+		InvitedBy:   &userID,
+		ExpiresAt:   time.Now().Add(invitationTTL),
+		CreatedAt:   time.Now(),
+	}
+
+	_, err = db.DB.Exec(`
+		INSERT INTO workspace_invitations (id, workspace_id, email, role, token_hash, invited_by, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, invitation.ID, invitation.WorkspaceID, invitation.Email, invitation.Role,
+		invitation.TokenHash, invitation.InvitedBy, invitation.ExpiresAt, invitation.CreatedAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		return
+	}
+
+	// Inviters can still share the token themselves if the email can't be sent
+	emailSent := false
+	if err := sendInvitationEmail(c.Request.Context(), &invitation, middleware.GetUserEmail(c), token); err != nil {
+		fmt.Printf("Error sending invitation email: %v\n", err)
+	} else {
+		emailSent = mailer.Enabled()
+	}
+
+	// The token is only returned once; it is stored hashed
+	c.JSON(http.StatusCreated, models.InviteMemberResponse{
+		Invitation: invitation,
+		Token:      token,
+		EmailSent:  emailSent,
+	})
+}
+
+// sendInvitationEmail emails an invitation's token to the invited address
+func sendInvitationEmail(ctx context.Context, invitation *models.WorkspaceInvitation, inviter string, token string) error {
+	var workspaceName string
+	if err := db.DB.GetContext(ctx, &workspaceName, "SELECT name FROM workspaces WHERE id = $1", invitation.WorkspaceID); err != nil {
+		return err
+	}
+
+	summary := fmt.Sprintf("%s has invited you to join the workspace %q as %s.", inviter, workspaceName, invitation.Role)
+	expiry := "The invitation expires on " + invitation.ExpiresAt.UTC().Format("January 2, 2006 at 15:04 UTC") +
+		" and can only be accepted by signing in as " + invitation.Email + "."
+
+	text := summary + "\n\n"
+	body := "<p>" + html.EscapeString(summary) + "</p>"
+	if utils.AppConfig.InvitationURL != "" {
+		acceptURL := strings.ReplaceAll(utils.AppConfig.InvitationURL, "{token}", url.PathEscape(token))
+		text += "Accept the invitation: " + acceptURL + "\n\n"
+		body += `<p><a href="` + html.EscapeString(acceptURL) + `">Accept the invitation</a></p>`
+	} else {
+		text += "Accept it in the app with this invitation code: " + token + "\n\n"
+		body += "<p>Accept it in the app with this invitation code:</p><p><code>" + html.EscapeString(token) + "</code></p>"
+	}
+	text += expiry + "\n"
+	body += "<p>" + html.EscapeString(expiry) + "</p>"
+
+	return mailer.Send(ctx, mailer.Message{
+		To:      invitation.Email,
+		Subject: fmt.Sprintf("You're invited to join %s", workspaceName),
+		Text:    text,
+		HTML:    body,
+	})
+}
+
+// AcceptWorkspaceInvitation adds the current user to a workspace using an invitation token
+func AcceptWorkspaceInvitation(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	var invitation models.WorkspaceInvitation
+	err := db.DB.Get(&invitation,
+		"SELECT * FROM workspace_invitations WHERE token_hash = $1",
+		utils.HashToken(c.Param("token")))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if invitation.AcceptedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Invitation has already been used"})
+		return
+	}
+	if time.Now().After(invitation.ExpiresAt) {
+		c.JSON(http.StatusGone, gin.H{"error": "Invitation has expired"})
+		return
+	}
+	if !strings.EqualFold(invitation.Email, middleware.GetUserEmail(c)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invitation was sent to a different email"})
+		return
+	}
+
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Accepting never downgrades an existing membership
+	_, err = tx.Exec(`
+		INSERT INTO workspace_members (workspace_id, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (workspace_id, user_id) DO NOTHING
+	`, invitation.WorkspaceID, *userID, invitation.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invitation"})
+		return
+	}
+
+	_, err = tx.Exec("UPDATE workspace_invitations SET accepted_at = $1 WHERE id = $2", time.Now(), invitation.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invitation"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invitation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation accepted", "workspaceId": invitation.WorkspaceID})
+}
+
+// isLastOwner reports whether a workspace has exactly one owner left
+func isLastOwner(workspaceID uuid.UUID) (bool, error) {
+	var owners int
+	err := db.DB.Get(&owners,
+		"SELECT COUNT(*) FROM workspace_members WHERE workspace_id = $1 AND role = $2",
+		workspaceID, models.RoleOwner)
+	return owners <= 1, err
+}
@@ -0,0 +1,67 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+	"url-shortener-api/screening"
+)
+
+const (
+	requestTimeout = 15 * time.Second
+	maxRedirects   = 10
+)
+
+// transport is shared by all checks and only connects to public addresses
+var transport = screening.PublicClient(requestTimeout).Transport
+
+// result is the outcome of requesting one destination
+type result struct {
+	healthy    bool
+	statusCode *int
+	latency    time.Duration
+	chain      []string
+	err        string
+}
+
+// check requests a destination, following redirects, and reports whether it still works.
+// Login walls, bot protection and rate limits (401, 403, 405, 429) are not treated as rot.
+func check(ctx context.Context, rawURL string) result {
+	var chain []string
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   requestTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return errors.New("too many redirects")
+			}
+			chain = append(chain, req.URL.String())
+			return nil
+		},
+	}
+
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return result{err: err.Error(), chain: chain}
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; URL-Shortener-Bot/1.0; link health check)")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+
+	resp, err := client.Do(req)
+	latency := time.Since(start)
+	if err != nil {
+		return result{latency: latency, chain: chain, err: err.Error()}
+	}
+	resp.Body.Close()
+
+	status := resp.StatusCode
+	healthy := status < 400
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusMethodNotAllowed, http.StatusTooManyRequests:
+		healthy = true
+	}
+
+	return result{healthy: healthy, statusCode: &status, latency: latency, chain: chain}
+}
@@ -0,0 +1,320 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"url-shortener-api/db"
+	"url-shortener-api/models"
+	"url-shortener-api/utils"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	// batchSize is how many links are loaded and checked at a time
+	batchSize = 500
+	// checkRetention is how long individual check results are kept
+	checkRetention = 30 * 24 * time.Hour
+)
+
+type linkRow struct {
+	ID          uuid.UUID  `db:"id"`
+	Slug        string     `db:"slug"`
+	Original    string     `db:"original"`
+	UserID      *uuid.UUID `db:"user_id"`
+	WorkspaceID *uuid.UUID `db:"workspace_id"`
+}
+
+// runStats counts outcomes across the concurrent checks of a run
+type runStats struct {
+	mu     sync.Mutex
+	result models.HealthCheckResult
+}
+
+// CheckLinks requests the destination of every active link. Requests are spread across hosts:
+// at most HEALTH_CHECK_CONCURRENCY run at once, at most HEALTH_CHECK_DOMAIN_CONCURRENCY per
+// host, with HEALTH_CHECK_DOMAIN_DELAY_MS between requests to the same host.
+func CheckLinks(ctx context.Context) (models.HealthCheckResult, error) {
+	stats := &runStats{}
+	lastID := uuid.Nil
+
+	for {
+		var rows []linkRow
+		err := db.DB.SelectContext(ctx, &rows, `
+			SELECT id, slug, original, user_id, workspace_id FROM links
+			WHERE NOT disabled
+				AND (expires_at IS NULL OR expires_at > NOW())
+				AND (max_clicks IS NULL OR clicks < max_clicks)
+				AND id > $1
+			ORDER BY id
+			LIMIT $2
+		`, lastID, batchSize)
+		if err != nil {
+			return stats.result, err
+		}
+		if len(rows) == 0 {
+			break
+		}
+		lastID = rows[len(rows)-1].ID
+
+		checkBatch(ctx, rows, stats)
+		if ctx.Err() != nil {
+			return stats.result, ctx.Err()
+		}
+	}
+
+	if _, err := db.DB.ExecContext(ctx, "DELETE FROM link_checks WHERE checked_at < $1", time.Now().Add(-checkRetention)); err != nil {
+		log.Printf("Error pruning old link checks: %v", err)
+	}
+
+	return stats.result, nil
+}
+
+// checkBatch checks a page of links, one queue per host
+func checkBatch(ctx context.Context, rows []linkRow, stats *runStats) {
+	byHost := map[string][]linkRow{}
+	for _, row := range rows {
+		host := strings.ToLower(utils.GetDomainFromURL(row.Original))
+		byHost[host] = append(byHost[host], row)
+	}
+
+	global := make(chan struct{}, max(1, utils.AppConfig.HealthCheckConcurrency))
+	perHost := max(1, utils.AppConfig.HealthCheckDomainConcurrency)
+	delay := time.Duration(utils.AppConfig.HealthCheckDomainDelayMs) * time.Millisecond
+
+	var wg sync.WaitGroup
+	for _, links := range byHost {
+		queue := make(chan linkRow, len(links))
+		for _, link := range links {
+			queue <- link
+		}
+		close(queue)
+
+		for i := 0; i < min(perHost, len(links)); i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for link := range queue {
+					select {
+					case global <- struct{}{}:
+					case <-ctx.Done():
+						return
+					}
+					checkLink(ctx, link, stats)
+					<-global
+
+					// Be polite to the host before sending it the next request
+					select {
+					case <-time.After(delay):
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+// checkLink checks one link and records the outcome, alerting and disabling after
+// HEALTH_FAILURE_THRESHOLD consecutive failures when configured
+func checkLink(ctx context.Context, link linkRow, stats *runStats) {
+	res := check(ctx, link.Original)
+	if ctx.Err() != nil {
+		return
+	}
+
+	var errText *string
+	if res.err != "" {
+		errText = &res.err
+	}
+	chain := res.chain
+	if chain == nil {
+		chain = []string{}
+	}
+
+	_, err := db.DB.ExecContext(ctx, `
+		INSERT INTO link_checks (link_id, checked_at, healthy, status_code, latency_ms, redirect_chain, error)
+		VALUES ($1, NOW(), $2, $3, $4, $5, $6)
+	`, link.ID, res.healthy, res.statusCode, res.latency.Milliseconds(), pq.StringArray(chain), errText)
+	if err != nil {
+		log.Printf("Error recording health check for link %s: %v", link.ID, err)
+		return
+	}
+
+	stats.mu.Lock()
+	stats.result.Checked++
+	stats.mu.Unlock()
+
+	if res.healthy {
+		_, err = db.DB.ExecContext(ctx, `
+			UPDATE links SET health_status = $1, health_status_code = $2, health_failures = 0, health_checked_at = NOW()
+			WHERE id = $3
+		`, models.HealthOK, res.statusCode, link.ID)
+		if err != nil {
+			log.Printf("Error updating health of link %s: %v", link.ID, err)
+		}
+		return
+	}
+
+	var failures int
+	err = db.DB.GetContext(ctx, &failures, `
+		UPDATE links SET health_status = $1, health_status_code = $2, health_failures = health_failures + 1, health_checked_at = NOW()
+		WHERE id = $3
+		RETURNING health_failures
+	`, models.HealthBroken, res.statusCode, link.ID)
+	if err != nil {
+		log.Printf("Error updating health of link %s: %v", link.ID, err)
+		return
+	}
+
+	stats.mu.Lock()
+	stats.result.Broken++
+	stats.mu.Unlock()
+
+	// Act once, when the link first reaches the threshold
+	if failures != utils.AppConfig.HealthFailureThreshold {
+		return
+	}
+
+	alert := Alert{
+		LinkID:      link.ID,
+		Slug:        link.Slug,
+		Original:    link.Original,
+		UserID:      link.UserID,
+		WorkspaceID: link.WorkspaceID,
+		Failures:    failures,
+		StatusCode:  res.statusCode,
+		Error:       res.err,
+	}
+
+	if utils.AppConfig.HealthAutoDisable {
+		if err := disable(ctx, link.ID, failures); err != nil {
+			log.Printf("Error disabling broken link %s: %v", link.ID, err)
+		} else {
+			alert.Disabled = true
+			stats.mu.Lock()
+			stats.result.Disabled++
+			stats.mu.Unlock()
+		}
+	}
+
+	if utils.AppConfig.HealthNotifyOwner {
+		notify(ctx, alert)
+	}
+}
+
+// disable turns off a link whose destination keeps failing and records why
+func disable(ctx context.Context, linkID uuid.UUID, failures int) error {
+	_, err := db.DB.ExecContext(ctx,
+		"UPDATE links SET disabled = TRUE, last_updated = NOW() WHERE id = $1", linkID)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.DB.ExecContext(ctx, `
+		INSERT INTO audit_log (action, target, success, details)
+		VALUES ('health.disable', $1, TRUE, $2)
+	`, linkID.String(), fmt.Sprintf("destination failed %d consecutive health checks", failures))
+	return err
+}
+
+// runLockKey is the Postgres advisory lock held during a run, so replicas take turns
+const runLockKey int64 = 0x6865616c7468 // "health"
+
+// ErrRunInProgress is returned by Run when another replica is checking links
+var ErrRunInProgress = errors.New("a health check run is already in progress")
+
+// Run checks all active links unless another replica is doing so. Scheduled runs also skip
+// when links were checked within minGap, so replicas don't repeat each other's work.
+func Run(ctx context.Context, minGap time.Duration) (models.HealthCheckResult, error) {
+	conn, err := db.DB.Conn(ctx)
+	if err != nil {
+		return models.HealthCheckResult{}, err
+	}
+	defer conn.Close()
+
+	// Advisory locks belong to the session, so lock and unlock on the same connection
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", runLockKey).Scan(&locked); err != nil {
+		return models.HealthCheckResult{}, err
+	}
+	if !locked {
+		return models.HealthCheckResult{}, ErrRunInProgress
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", runLockKey)
+
+	if minGap > 0 {
+		var recent bool
+		err := conn.QueryRowContext(ctx,
+			"SELECT EXISTS (SELECT 1 FROM link_checks WHERE checked_at > $1)", time.Now().Add(-minGap)).Scan(&recent)
+		if err != nil {
+			return models.HealthCheckResult{}, err
+		}
+		if recent {
+			return models.HealthCheckResult{}, nil
+		}
+	}
+
+	return CheckLinks(ctx)
+}
+
+// requested wakes the check loop for a run outside the schedule
+var requested = make(chan struct{}, 1)
+
+// RequestRun asks the check loop to run as soon as possible. It reports false if a run is
+// already waiting.
+func RequestRun() bool {
+	select {
+	case requested <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// StartCheckLoop checks all active links every interval, and whenever RequestRun is called,
+// until ctx is cancelled. An interval of 0 only runs on request.
+func StartCheckLoop(ctx context.Context, interval time.Duration) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		tick = ticker.C
+		go func() {
+			<-ctx.Done()
+			ticker.Stop()
+		}()
+	}
+
+	go func() {
+		for {
+			var minGap time.Duration
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick:
+				minGap = interval / 2
+			case <-requested:
+			}
+
+			result, err := Run(ctx, minGap)
+			if errors.Is(err, ErrRunInProgress) {
+				continue
+			}
+			if err != nil {
+				log.Printf("Link health check failed: %v", err)
+				continue
+			}
+			if result.Checked > 0 {
+				log.Printf("Link health check: %d checked, %d broken, %d disabled",
+					result.Checked, result.Broken, result.Disabled)
+			}
+		}
+	}()
+}
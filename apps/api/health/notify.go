@@ -0,0 +1,109 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"html"
+	"log"
+	"sync"
+	"url-shortener-api/db"
+	"url-shortener-api/mailer"
+
+	"github.com/google/uuid"
+)
+
+// Alert describes a link whose destination has failed enough consecutive checks
+type Alert struct {
+	LinkID      uuid.UUID
+	Slug        string
+	Original    string
+	UserID      *uuid.UUID
+	WorkspaceID *uuid.UUID
+	Failures    int
+	StatusCode  *int
+	Error       string
+	Disabled    bool
+}
+
+// Notifier tells a link's owner that its destination is broken
+type Notifier interface {
+	LinkBroken(ctx context.Context, alert Alert) error
+}
+
+var (
+	notifiersMu sync.RWMutex
+	notifiers   = []Notifier{auditNotifier{}, mailNotifier{}}
+)
+
+// RegisterNotifier adds a notifier that is told about broken links when HEALTH_NOTIFY_OWNER is on
+func RegisterNotifier(notifier Notifier) {
+	notifiersMu.Lock()
+	defer notifiersMu.Unlock()
+	notifiers = append(notifiers, notifier)
+}
+
+func notify(ctx context.Context, alert Alert) {
+	notifiersMu.RLock()
+	defer notifiersMu.RUnlock()
+	for _, notifier := range notifiers {
+		if err := notifier.LinkBroken(ctx, alert); err != nil {
+			log.Printf("Error notifying owner of broken link %s: %v", alert.LinkID, err)
+		}
+	}
+}
+
+// auditNotifier records broken links in the audit log, where admins and owners' tools can find them
+type auditNotifier struct{}
+
+func (auditNotifier) LinkBroken(ctx context.Context, alert Alert) error {
+	owner := "anonymous"
+	if alert.UserID != nil {
+		owner = alert.UserID.String()
+	}
+	status := "none"
+	if alert.StatusCode != nil {
+		status = fmt.Sprint(*alert.StatusCode)
+	}
+
+	_, err := db.DB.ExecContext(ctx, `
+		INSERT INTO audit_log (action, target, success, details)
+		VALUES ('health.broken', $1, FALSE, $2)
+	`, alert.LinkID.String(), fmt.Sprintf("owner=%s failures=%d status=%s error=%s", owner, alert.Failures, status, alert.Error))
+	return err
+}
+
+// mailNotifier emails the user who created the link
+type mailNotifier struct{}
+
+func (mailNotifier) LinkBroken(ctx context.Context, alert Alert) error {
+	if alert.UserID == nil {
+		return nil
+	}
+
+	var email string
+	err := db.DB.GetContext(ctx, &email, "SELECT email FROM users WHERE id = $1 AND suspended_at IS NULL", *alert.UserID)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	problem := alert.Error
+	if alert.StatusCode != nil {
+		problem = fmt.Sprintf("HTTP status %d", *alert.StatusCode)
+	}
+	summary := fmt.Sprintf("The destination of your short link %q has failed %d checks in a row (%s):", alert.Slug, alert.Failures, problem)
+	action := "Update the destination or disable the link if it is no longer needed."
+	if alert.Disabled {
+		action = "The link has been disabled. Update the destination and enable it again to bring it back."
+	}
+
+	return mailer.Send(ctx, mailer.Message{
+		To:      email,
+		Subject: fmt.Sprintf("Your short link %q is broken", alert.Slug),
+		Text:    summary + "\n\n" + alert.Original + "\n\n" + action + "\n",
+		HTML: "<p>" + html.EscapeString(summary) + "</p><p><code>" + html.EscapeString(alert.Original) + "</code></p><p>" +
+			html.EscapeString(action) + "</p>",
+	})
+}
@@ -0,0 +1,80 @@
+package mailer
+
+import (
+	"context"
+	"log"
+	"strings"
+	"url-shortener-api/utils"
+)
+
+// Message is an email with HTML and plain text versions of the body
+type Message struct {
+	To      string
+	Subject string
+	HTML    string
+	Text    string
+}
+
+// Mailer sends email. Other providers can be plugged in with Use.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Default is the mailer used by the API, configured by Load
+var Default Mailer = LogMailer{}
+
+// Load selects the default mailer from the MAILER setting
+func Load() {
+	switch strings.ToLower(utils.AppConfig.Mailer) {
+	case "smtp":
+		if utils.AppConfig.SMTPHost == "" || utils.AppConfig.MailFrom == "" {
+			log.Fatal("MAILER=smtp requires SMTP_HOST and MAIL_FROM")
+		}
+		Default = &SMTPMailer{
+			Host:     utils.AppConfig.SMTPHost,
+			Port:     utils.AppConfig.SMTPPort,
+			Username: utils.AppConfig.SMTPUsername,
+			Password: utils.AppConfig.SMTPPassword,
+			From:     utils.AppConfig.MailFrom,
+		}
+	case "none":
+		Default = NoopMailer{}
+	default:
+		Default = LogMailer{}
+	}
+}
+
+// Use replaces the default mailer, e.g. with an HTTP API based provider
+func Use(m Mailer) {
+	Default = m
+}
+
+// Enabled reports whether the default mailer really delivers email, rather than logging or
+// dropping it. Features that only make sense by email, such as reports, are off otherwise.
+func Enabled() bool {
+	switch Default.(type) {
+	case LogMailer, NoopMailer, *LogMailer, *NoopMailer, nil:
+		return false
+	}
+	return true
+}
+
+// Send sends a message with the default mailer
+func Send(ctx context.Context, msg Message) error {
+	return Default.Send(ctx, msg)
+}
+
+// LogMailer writes messages to the log instead of sending them, for development
+type LogMailer struct{}
+
+func (LogMailer) Send(ctx context.Context, msg Message) error {
+	log.Printf("Email to %s: %s\n%s", msg.To, msg.Subject, msg.Text)
+	return nil
+}
+
+// NoopMailer drops every message
+type NoopMailer struct{}
+
+func (NoopMailer) Send(ctx context.Context, msg Message) error {
+	return nil
+}
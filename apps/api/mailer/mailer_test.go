@@ -0,0 +1,33 @@
+package mailer
+
+import (
+	"context"
+	"testing"
+)
+
+type stubMailer struct{}
+
+func (stubMailer) Send(ctx context.Context, msg Message) error { return nil }
+
+func TestEnabled(t *testing.T) {
+	tests := []struct {
+		name   string
+		mailer Mailer
+		want   bool
+	}{
+		{"log", LogMailer{}, false},
+		{"none", NoopMailer{}, false},
+		{"smtp", &SMTPMailer{Host: "smtp.example.com"}, true},
+		{"custom provider", stubMailer{}, true},
+	}
+
+	previous := Default
+	defer func() { Default = previous }()
+
+	for _, tt := range tests {
+		Use(tt.mailer)
+		if got := Enabled(); got != tt.want {
+			t.Errorf("Enabled() with %s mailer = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
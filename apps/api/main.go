@@ -1,10 +1,24 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net/http"
+	"os"
+	"time"
+	"url-shortener-api/certs"
 	"url-shortener-api/db"
+	"url-shortener-api/favicons"
+	"url-shortener-api/handlers"
+	"url-shortener-api/health"
+	"url-shortener-api/mailer"
+	"url-shortener-api/policy"
 	"url-shortener-api/routes"
+	"url-shortener-api/screening"
+	"url-shortener-api/stream"
+	"url-shortener-api/targeting"
 	"url-shortener-api/utils"
+	"url-shortener-api/webhooks"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,6 +27,17 @@ func main() {
 	// Load environment variables
 	utils.LoadEnv()
 
+	// Operator commands, like promote-admin, run instead of the server
+	if runCommand(os.Args[1:]) {
+		return
+	}
+
+	// Load anonymous link creation policy
+	policy.LoadAnonymousPolicy()
+
+	// Configure how visitor countries are resolved for targeting rules
+	targeting.LoadGeo()
+
 	// Set Gin mode
 	gin.SetMode(utils.AppConfig.GinMode)
 
@@ -25,11 +50,78 @@ func main() {
 	// Run database migrations
 	db.RunMigrations()
 
+	// Configure outgoing email for reports and notifications
+	mailer.Load()
+
+	// Fan live click events out to dashboards connected to any replica, and drop cached
+	// redirect targets when another replica changes them
+	handlers.WatchLinkTargets()
+	stream.Start(utils.AppConfig.DBURL)
+
+	// Set up URL screening and periodic rechecks of existing links; admins can also queue a recheck
+	screening.Load()
+	screening.StartRecheckLoop(context.Background(), time.Duration(utils.AppConfig.ScreeningRecheckHours)*time.Hour)
+
+	// Periodically check that link destinations still respond; admins can also queue a run
+	health.StartCheckLoop(context.Background(), time.Duration(utils.AppConfig.HealthCheckHours)*time.Hour)
+
+	// Download favicons and scrape page metadata of new link destinations in the background
+	favicons.StartWorker(context.Background())
+	handlers.StartMetadataWorker(context.Background())
+
+	// Record clicks off the redirect path
+	handlers.StartClickRecorders(context.Background())
+
+	// Deliver webhook events from the outbox and announce links as they expire
+	webhooks.StartWorkers(context.Background())
+	handlers.StartLinkExpiryEvents(context.Background(), time.Minute)
+
+	// Email weekly and monthly performance reports to users who opted in
+	if utils.AppConfig.ReportCheckMinutes > 0 && !mailer.Enabled() {
+		log.Printf("Not sending scheduled reports: MAILER=%q does not deliver email", utils.AppConfig.Mailer)
+	} else if utils.AppConfig.ReportCheckMinutes > 0 {
+		handlers.StartReportScheduler(context.Background(), time.Duration(utils.AppConfig.ReportCheckMinutes)*time.Minute)
+	}
+
 	r := gin.Default()
 
 	// Import routes package
 	routes.SetupRoutes(r)
 
+	// Serve redirects from their own host on the short domain and on verified custom domains
+	redirects := gin.Default()
+	routes.SetupRedirectRoutes(redirects)
+	shortDomain := utils.ShortDomain()
+	handler := routes.HostRouter(shortDomain, handlers.IsCustomDomainHost, redirects, r)
+	if shortDomain != "" {
+		log.Printf("Serving short links on %s", shortDomain)
+	}
+
+	// Optionally terminate TLS ourselves, issuing certificates on demand for verified domains.
+	// The plain listener keeps serving and also answers ACME HTTP-01 challenges.
+	if utils.AppConfig.TLSEnabled {
+		manager, err := certs.NewManager()
+		if err != nil {
+			log.Fatalf("TLS setup failed: %v", err)
+		}
+
+		server := &http.Server{
+			Addr:      ":" + utils.AppConfig.TLSPort,
+			Handler:   handler,
+			TLSConfig: manager.TLSConfig(),
+		}
+		go func() {
+			log.Printf("HTTPS server running on port %s", utils.AppConfig.TLSPort)
+			if err := server.ListenAndServeTLS("", ""); err != nil {
+				log.Fatalf("HTTPS server failed: %v", err)
+			}
+		}()
+
+		handler = manager.HTTPHandler(handler)
+	}
+
 	log.Printf("Server running on port %s", utils.AppConfig.Port)
-	r.Run(":" + utils.AppConfig.Port)
+	if err := http.ListenAndServe(":"+utils.AppConfig.Port, handler); err != nil {
+		log.Fatalf("Server failed: %v", err)
+	}
 }
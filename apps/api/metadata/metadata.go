@@ -0,0 +1,254 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"url-shortener-api/screening"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// Fetch limits. Pages are only read up to MaxBodySize since the tags we want live in <head>.
+const (
+	MaxBodySize    = 1 << 20
+	FetchTimeout   = 10 * time.Second
+	maxRedirects   = 5
+	maxTitle       = 300
+	maxDescription = 1000
+)
+
+// Metadata is what a page says about itself through <title>, <meta> and <link> tags
+type Metadata struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	ImageURL    string `json:"imageUrl,omitempty"`
+	SiteName    string `json:"siteName,omitempty"`
+	Type        string `json:"type,omitempty"`
+	TwitterCard string `json:"twitterCard,omitempty"`
+	Icons       []Icon `json:"icons,omitempty"`
+}
+
+// Icon is a <link rel="icon"> style candidate declared by a page
+type Icon struct {
+	URL   string `json:"url"`
+	Rel   string `json:"rel"`
+	Sizes string `json:"sizes,omitempty"`
+	Type  string `json:"type,omitempty"`
+}
+
+var client = newClient()
+
+func newClient() *http.Client {
+	c := screening.PublicClient(FetchTimeout)
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return errors.New("too many redirects")
+		}
+		if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+			return errors.New("redirect to a non-http URL")
+		}
+		return nil
+	}
+	return c
+}
+
+// Fetch downloads a page and extracts its metadata. Destinations that are not HTML give
+// empty metadata rather than an error.
+func Fetch(ctx context.Context, rawURL string) (*Metadata, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return nil, errors.New("only http and https URLs can be fetched")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, FetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; URL-Shortener-Bot/1.0)")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.5")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("destination returned status %d", resp.StatusCode)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType != "" && !strings.Contains(contentType, "html") {
+		return &Metadata{}, nil
+	}
+
+	body, err := charset.NewReader(io.LimitReader(resp.Body, MaxBodySize), contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	// Relative URLs resolve against the page we ended up on after redirects
+	return Parse(body, resp.Request.URL), nil
+}
+
+// Parse extracts metadata from an HTML document. Open Graph tags win over Twitter tags,
+// which win over plain <title> and description.
+func Parse(r io.Reader, base *url.URL) *Metadata {
+	tags := map[string]string{}
+	var title strings.Builder
+	var icons []Icon
+	inTitle := false
+
+	z := html.NewTokenizer(r)
+scan:
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			break scan
+		case html.TextToken:
+			if inTitle {
+				title.Write(z.Text())
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = false
+			case "head":
+				break scan
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			attrs := map[string]string{}
+			for hasAttr {
+				var key, value []byte
+				key, value, hasAttr = z.TagAttr()
+				attrs[string(key)] = string(value)
+			}
+
+			switch string(name) {
+			case "title":
+				inTitle = tt == html.StartTagToken && title.Len() == 0
+			case "body":
+				break scan
+			case "base":
+				if href, err := base.Parse(attrs["href"]); err == nil && attrs["href"] != "" {
+					base = href
+				}
+			case "meta":
+				key := strings.ToLower(attrs["property"])
+				if key == "" {
+					key = strings.ToLower(attrs["name"])
+				}
+				// The first value of a repeated tag wins, e.g. the primary og:image
+				if _, seen := tags[key]; key != "" && !seen {
+					tags[key] = attrs["content"]
+				}
+			case "link":
+				rel := strings.ToLower(strings.Join(strings.Fields(attrs["rel"]), " "))
+				if !isIconRel(rel) {
+					continue
+				}
+				if href := resolve(base, attrs["href"]); href != "" {
+					icons = append(icons, Icon{URL: href, Rel: rel, Sizes: attrs["sizes"], Type: attrs["type"]})
+				}
+			}
+		}
+	}
+
+	return &Metadata{
+		Title:       truncate(firstOf(tags["og:title"], tags["twitter:title"], title.String()), maxTitle),
+		Description: truncate(firstOf(tags["og:description"], tags["twitter:description"], tags["description"]), maxDescription),
+		ImageURL: resolve(base, firstOf(tags["og:image:secure_url"], tags["og:image"], tags["og:image:url"],
+			tags["twitter:image"], tags["twitter:image:src"])),
+		SiteName:    truncate(tags["og:site_name"], maxTitle),
+		Type:        truncate(tags["og:type"], 50),
+		TwitterCard: truncate(tags["twitter:card"], 50),
+		Icons:       icons,
+	}
+}
+
+// BestIcon picks the icon to show next to a link: the smallest declared icon of at least
+// 32px, otherwise the first plain icon, otherwise any candidate.
+func (m *Metadata) BestIcon() string {
+	best, bestSize := "", 0
+	for _, icon := range m.Icons {
+		if !strings.Contains(icon.Rel, "icon") || strings.HasPrefix(icon.Rel, "apple") || icon.Rel == "mask-icon" {
+			continue
+		}
+		size := iconSize(icon.Sizes)
+		if best == "" || (size >= 32 && (bestSize < 32 || size < bestSize)) {
+			best, bestSize = icon.URL, size
+		}
+	}
+	if best == "" && len(m.Icons) > 0 {
+		best = m.Icons[0].URL
+	}
+	return best
+}
+
+func isIconRel(rel string) bool {
+	switch rel {
+	case "icon", "shortcut icon", "apple-touch-icon", "apple-touch-icon-precomposed", "mask-icon":
+		return true
+	}
+	return false
+}
+
+// iconSize returns the largest width in a sizes attribute such as "16x16 32x32"; "any" counts as large
+func iconSize(sizes string) int {
+	largest := 0
+	for _, size := range strings.Fields(strings.ToLower(sizes)) {
+		if size == "any" {
+			return 1 << 16
+		}
+		width, _, _ := strings.Cut(size, "x")
+		if n, err := strconv.Atoi(width); err == nil && n > largest {
+			largest = n
+		}
+	}
+	return largest
+}
+
+// resolve turns a possibly relative reference into an absolute http(s) URL, or "" if it is not one
+func resolve(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	u, err := base.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return u.String()
+}
+
+func firstOf(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// truncate collapses whitespace and cuts s to at most max characters
+func truncate(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if runes := []rune(s); len(runes) > max {
+		return strings.TrimSpace(string(runes[:max-1])) + "…"
+	}
+	return s
+}
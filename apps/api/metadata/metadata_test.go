@@ -0,0 +1,363 @@
+package metadata
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func mustParseURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestParse(t *testing.T) {
+	longTitle := strings.Repeat("a", 400)
+
+	tests := []struct {
+		name string
+		html string
+		want Metadata
+	}{
+		{
+			name: "open graph wins",
+			html: `<html><head>
+				<title>Plain title</title>
+				<meta name="description" content="Plain description">
+				<meta name="twitter:title" content="Twitter title">
+				<meta name="twitter:description" content="Twitter description">
+				<meta property="og:title" content="OG title">
+				<meta property="og:description" content="OG description">
+				<meta property="og:site_name" content="Example">
+				<meta property="og:type" content="article">
+				<meta name="twitter:card" content="summary_large_image">
+			</head></html>`,
+			want: Metadata{Title: "OG title", Description: "OG description", SiteName: "Example", Type: "article", TwitterCard: "summary_large_image"},
+		},
+		{
+			name: "twitter fallback",
+			html: `<head>
+				<title>Plain title</title>
+				<meta name="description" content="Plain description">
+				<meta name="twitter:title" content="Twitter title">
+				<meta name="twitter:description" content="Twitter description">
+				<meta name="twitter:image" content="https://cdn.example.com/card.png">
+			</head>`,
+			want: Metadata{Title: "Twitter title", Description: "Twitter description", ImageURL: "https://cdn.example.com/card.png"},
+		},
+		{
+			name: "plain title and description",
+			html: `<head><title>Fish &amp; Chips</title><meta name="Description" content="Fried"></head>`,
+			want: Metadata{Title: "Fish & Chips", Description: "Fried"},
+		},
+		{
+			name: "empty open graph values fall through",
+			html: `<head><title>Plain title</title><meta property="og:title" content="  "></head>`,
+			want: Metadata{Title: "Plain title"},
+		},
+		{
+			name: "property names are case-insensitive",
+			html: `<head><meta property="OG:Title" content="Upper"></head>`,
+			want: Metadata{Title: "Upper"},
+		},
+		{
+			name: "first repeated tag wins",
+			html: `<head>
+				<meta property="og:image" content="/first.png">
+				<meta property="og:image" content="/second.png">
+			</head>`,
+			want: Metadata{ImageURL: "https://example.com/first.png"},
+		},
+		{
+			name: "secure image preferred",
+			html: `<head>
+				<meta property="og:image" content="http://example.com/image.png">
+				<meta property="og:image:secure_url" content="https://example.com/image.png">
+			</head>`,
+			want: Metadata{ImageURL: "https://example.com/image.png"},
+		},
+		{
+			name: "relative image resolved against the page",
+			html: `<head><meta property="og:image" content="../img/card.png"></head>`,
+			want: Metadata{ImageURL: "https://example.com/img/card.png"},
+		},
+		{
+			name: "base element changes resolution",
+			html: `<head>
+				<base href="https://static.example.net/assets/">
+				<meta property="og:image" content="card.png">
+				<link rel="icon" href="favicon.ico">
+			</head>`,
+			want: Metadata{
+				ImageURL: "https://static.example.net/assets/card.png",
+				Icons:    []Icon{{URL: "https://static.example.net/assets/favicon.ico", Rel: "icon"}},
+			},
+		},
+		{
+			name: "non-http image dropped",
+			html: `<head><meta property="og:image" content="javascript:alert(1)"></head>`,
+			want: Metadata{},
+		},
+		{
+			name: "icons",
+			html: `<head>
+				<link rel="Shortcut  Icon" href="/favicon.ico">
+				<link rel="icon" href="//cdn.example.com/icon-32.png" sizes="32x32" type="image/png">
+				<link rel="apple-touch-icon" href="touch.png" sizes="180x180">
+				<link rel="stylesheet" href="/style.css">
+				<link rel="icon" href="data:image/png;base64,AAAA">
+				<link rel="icon">
+			</head>`,
+			want: Metadata{Icons: []Icon{
+				{URL: "https://example.com/favicon.ico", Rel: "shortcut icon"},
+				{URL: "https://cdn.example.com/icon-32.png", Rel: "icon", Sizes: "32x32", Type: "image/png"},
+				{URL: "https://example.com/blog/touch.png", Rel: "apple-touch-icon", Sizes: "180x180"},
+			}},
+		},
+		{
+			name: "only the first title counts",
+			html: `<head><title>First</title><title>Second</title></head>`,
+			want: Metadata{Title: "First"},
+		},
+		{
+			name: "tags after the head are ignored",
+			html: `<head><title>Head</title></head><body><meta property="og:title" content="Body"></body>`,
+			want: Metadata{Title: "Head"},
+		},
+		{
+			name: "body without a head",
+			html: `<title>Early</title><body><meta name="description" content="Too late"><title>Late</title></body>`,
+			want: Metadata{Title: "Early"},
+		},
+		{
+			name: "whitespace collapsed",
+			html: "<head><title>\n  Spread\t over \n lines  </title></head>",
+			want: Metadata{Title: "Spread over lines"},
+		},
+		{
+			name: "long title truncated",
+			html: `<head><title>` + longTitle + `</title></head>`,
+			want: Metadata{Title: strings.Repeat("a", maxTitle-1) + "…"},
+		},
+		{
+			name: "truncation counts characters, not bytes",
+			html: `<head><meta property="og:site_name" content="` + strings.Repeat("é", maxTitle) + `"></head>`,
+			want: Metadata{SiteName: strings.Repeat("é", maxTitle)},
+		},
+		{
+			name: "long type truncated",
+			html: `<head><meta property="og:type" content="` + strings.Repeat("t", 60) + `"></head>`,
+			want: Metadata{Type: strings.Repeat("t", 49) + "…"},
+		},
+		{
+			name: "not html",
+			html: `{"title": "JSON"}`,
+			want: Metadata{},
+		},
+	}
+
+	base := mustParseURL(t, "https://example.com/blog/post")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(strings.NewReader(tt.html), base)
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("Parse()\n got %+v\nwant %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTruncatesDescription(t *testing.T) {
+	html := `<head><meta name="description" content="` + strings.Repeat("word ", 300) + `"></head>`
+	got := Parse(strings.NewReader(html), mustParseURL(t, "https://example.com/"))
+	if runes := []rune(got.Description); len(runes) != maxDescription || runes[len(runes)-1] != '…' {
+		t.Errorf("description has %d characters, want %d ending in an ellipsis", len(runes), maxDescription)
+	}
+	if strings.Contains(got.Description, " …") {
+		t.Error("description keeps the space before the ellipsis")
+	}
+}
+
+func TestBestIcon(t *testing.T) {
+	tests := []struct {
+		name  string
+		icons []Icon
+		want  string
+	}{
+		{"none", nil, ""},
+		{"single", []Icon{{URL: "a", Rel: "icon"}}, "a"},
+		{
+			"smallest of at least 32px",
+			[]Icon{{URL: "16", Rel: "icon", Sizes: "16x16"}, {URL: "64", Rel: "icon", Sizes: "64x64"}, {URL: "32", Rel: "icon", Sizes: "32x32"}},
+			"32",
+		},
+		{
+			"sized beats unsized",
+			[]Icon{{URL: "plain", Rel: "shortcut icon"}, {URL: "48", Rel: "icon", Sizes: "48x48"}},
+			"48",
+		},
+		{
+			"first plain icon when all are small",
+			[]Icon{{URL: "first", Rel: "icon", Sizes: "16x16"}, {URL: "second", Rel: "icon", Sizes: "24x24"}},
+			"first",
+		},
+		{
+			"largest of several sizes counts",
+			[]Icon{{URL: "ico", Rel: "icon", Sizes: "16x16 48x48"}, {URL: "64", Rel: "icon", Sizes: "64x64"}},
+			"ico",
+		},
+		{
+			"any is large",
+			[]Icon{{URL: "svg", Rel: "icon", Sizes: "any"}, {URL: "16", Rel: "icon", Sizes: "16x16"}},
+			"svg",
+		},
+		{
+			"apple and mask icons skipped",
+			[]Icon{{URL: "apple", Rel: "apple-touch-icon", Sizes: "180x180"}, {URL: "mask", Rel: "mask-icon"}, {URL: "plain", Rel: "icon"}},
+			"plain",
+		},
+		{
+			"apple icon when nothing else",
+			[]Icon{{URL: "apple", Rel: "apple-touch-icon", Sizes: "180x180"}},
+			"apple",
+		},
+		{
+			"malformed sizes ignored",
+			[]Icon{{URL: "bad", Rel: "icon", Sizes: "large"}, {URL: "32", Rel: "icon", Sizes: "32X32"}},
+			"32",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Metadata{Icons: tt.icons}
+			if got := m.BestIcon(); got != tt.want {
+				t.Errorf("BestIcon() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	base := mustParseURL(t, "https://example.com/a/b/page.html?q=1")
+
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"", ""},
+		{"   ", ""},
+		{"icon.png", "https://example.com/a/b/icon.png"},
+		{"  icon.png  ", "https://example.com/a/b/icon.png"},
+		{"/icon.png", "https://example.com/icon.png"},
+		{"../icon.png", "https://example.com/a/icon.png"},
+		{"//cdn.example.net/icon.png", "https://cdn.example.net/icon.png"},
+		{"http://other.example/icon.png", "http://other.example/icon.png"},
+		{"?v=2", "https://example.com/a/b/page.html?v=2"},
+		{"javascript:alert(1)", ""},
+		{"data:image/png;base64,AAAA", ""},
+		{"ftp://example.com/icon.png", ""},
+		{"http://[::1", ""},
+	}
+
+	for _, tt := range tests {
+		if got := resolve(base, tt.ref); got != tt.want {
+			t.Errorf("resolve(%q) = %q, want %q", tt.ref, got, tt.want)
+		}
+	}
+}
+
+// useLocalClient lets Fetch reach test servers on loopback, keeping the redirect policy
+func useLocalClient(t *testing.T) {
+	t.Helper()
+	previous := client
+	client = newClient()
+	client.Transport = http.DefaultTransport
+	t.Cleanup(func() { client = previous })
+}
+
+func TestFetch(t *testing.T) {
+	useLocalClient(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/latin1-header", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=iso-8859-1")
+		w.Write([]byte("<head><title>Caf\xe9</title></head>"))
+	})
+	mux.HandleFunc("/latin1-meta", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<head><meta charset="windows-1252"><title>Price: 5` + "\x80" + `</title></head>`))
+	})
+	mux.HandleFunc("/utf8", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<head><title>Café</title></head>"))
+	})
+	mux.HandleFunc("/no-type", func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Type"] = nil
+		w.Write([]byte("<head><title>Untyped</title></head>"))
+	})
+	mux.HandleFunc("/image", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("<head><title>Not parsed</title></head>"))
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/docs/final", http.StatusFound)
+	})
+	mux.HandleFunc("/docs/final", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<head><link rel="icon" href="icon.png"></head>`))
+	})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		path    string
+		want    Metadata
+		wantErr bool
+	}{
+		{path: "/latin1-header", want: Metadata{Title: "Café"}},
+		{path: "/latin1-meta", want: Metadata{Title: "Price: 5€"}},
+		{path: "/utf8", want: Metadata{Title: "Café"}},
+		{path: "/no-type", want: Metadata{Title: "Untyped"}},
+		{path: "/image", want: Metadata{}},
+		{path: "/redirect", want: Metadata{Icons: []Icon{{URL: server.URL + "/docs/icon.png", Rel: "icon"}}}},
+		{path: "/missing", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := Fetch(context.Background(), server.URL+tt.path)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Fetch() = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Fetch() error: %v", err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("Fetch()\n got %+v\nwant %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchRejectsOtherSchemes(t *testing.T) {
+	for _, raw := range []string{"ftp://example.com/", "file:///etc/passwd", "javascript:alert(1)", "::"} {
+		if _, err := Fetch(context.Background(), raw); err == nil {
+			t.Errorf("Fetch(%q) succeeded", raw)
+		}
+	}
+}
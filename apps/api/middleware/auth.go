@@ -3,6 +3,8 @@ package middleware
 import (
 	"net/http"
 	"strings"
+	"time"
+	"url-shortener-api/db"
 	"url-shortener-api/utils"
 
 	"github.com/gin-gonic/gin"
@@ -12,8 +14,11 @@ import (
 
 // JWTClaims represents the JWT token claims
 type JWTClaims struct {
-	UserID uuid.UUID `json:"user_id"`
-	Email  string    `json:"email"`
+	UserID  uuid.UUID `json:"user_id"`
+	Email   string    `json:"email"`
+	IsAdmin bool      `json:"is_admin,omitempty"`
+	// ImpersonatorID is set when an admin is acting as this user for support
+	ImpersonatorID *uuid.UUID `json:"impersonator_id,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -52,11 +57,17 @@ func JWTAuth() gin.HandlerFunc {
 			return
 		}
 
-		// Extract claims
-		if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid {
+		// Extract claims. Tokens with an audience, such as stream tokens, only work on their own routes.
+		if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid && len(claims.Audience) == 0 {
+			// Reject tokens belonging to suspended accounts
+			if IsSuspended(claims.UserID) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Account suspended"})
+				c.Abort()
+				return
+			}
+
 			// Set user information in context
-			c.Set("userID", claims.UserID)
-			c.Set("email", claims.Email)
+			setClaims(c, claims)
 			c.Next()
 		} else {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
@@ -95,10 +106,9 @@ func OptionalJWTAuth() gin.HandlerFunc {
 
 		if err == nil {
 			// Extract claims if token is valid
-			if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid {
+			if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid && len(claims.Audience) == 0 && !IsSuspended(claims.UserID) {
 				// Set user information in context
-				c.Set("userID", claims.UserID)
-				c.Set("email", claims.Email)
+				setClaims(c, claims)
 			}
 		}
 		
@@ -106,6 +116,50 @@ func OptionalJWTAuth() gin.HandlerFunc {
 	}
 }
 
+// AdminAuth middleware requires an authenticated administrator. It must run after JWTAuth.
+// The admin flag is re-checked against the database so revoked admins lose access immediately,
+// and impersonation tokens are never allowed through.
+func AdminAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := GetUserID(c)
+		if userID == nil || !IsAdmin(c) || GetImpersonatorID(c) != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			c.Abort()
+			return
+		}
+
+		var isAdmin bool
+		err := db.DB.Get(&isAdmin, "SELECT is_admin FROM users WHERE id = $1", *userID)
+		if err != nil || !isAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// setClaims stores the token claims in the Gin context
+func setClaims(c *gin.Context, claims *JWTClaims) {
+	c.Set("userID", claims.UserID)
+	c.Set("email", claims.Email)
+	c.Set("isAdmin", claims.IsAdmin)
+	if claims.ImpersonatorID != nil {
+		c.Set("impersonatorID", *claims.ImpersonatorID)
+	}
+	if claims.ExpiresAt != nil {
+		c.Set("sessionExpiresAt", claims.ExpiresAt.Time)
+	}
+}
+
+// IsSuspended reports whether the user's account has been suspended by an admin
+func IsSuspended(userID uuid.UUID) bool {
+	var suspended bool
+	err := db.DB.Get(&suspended, "SELECT suspended_at IS NOT NULL FROM users WHERE id = $1", userID)
+	return err == nil && suspended
+}
+
 // GetUserID retrieves the user ID from the Gin context
 func GetUserID(c *gin.Context) *uuid.UUID {
 	if userID, exists := c.Get("userID"); exists {
@@ -124,4 +178,29 @@ func GetUserEmail(c *gin.Context) string {
 		}
 	}
 	return ""
-}
\ No newline at end of file
+}
+
+// IsAdmin reports whether the token in the Gin context carries the admin claim
+func IsAdmin(c *gin.Context) bool {
+	return c.GetBool("isAdmin")
+}
+
+// GetSessionExpiry returns when the token that authenticated the request expires, if it does
+func GetSessionExpiry(c *gin.Context) *time.Time {
+	if expiresAt, exists := c.Get("sessionExpiresAt"); exists {
+		if t, ok := expiresAt.(time.Time); ok {
+			return &t
+		}
+	}
+	return nil
+}
+
+// GetImpersonatorID retrieves the admin ID when the request is made through an impersonation token
+func GetImpersonatorID(c *gin.Context) *uuid.UUID {
+	if impersonatorID, exists := c.Get("impersonatorID"); exists {
+		if uid, ok := impersonatorID.(uuid.UUID); ok {
+			return &uid
+		}
+	}
+	return nil
+}
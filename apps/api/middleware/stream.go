@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+	"url-shortener-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// streamAudience scopes stream tokens so they can't be used as session tokens
+const streamAudience = "stream"
+
+// StreamTokenTTL is how long a stream token can be used to open a connection. Tokens travel in
+// the URL, where they may end up in logs, so they are short-lived.
+const StreamTokenTTL = time.Minute
+
+// StreamClaims authorize opening an event stream. SessionExpiresAt carries the expiry of the
+// session token the stream token was issued for, when open streams must close.
+type StreamClaims struct {
+	UserID           uuid.UUID        `json:"user_id"`
+	SessionExpiresAt *jwt.NumericDate `json:"session_exp,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// IssueStreamToken returns a stream token for the authenticated user of the request
+func IssueStreamToken(c *gin.Context) (string, time.Time, error) {
+	userID := GetUserID(c)
+	if userID == nil {
+		return "", time.Time{}, jwt.ErrTokenInvalidClaims
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(StreamTokenTTL)
+	claims := StreamClaims{
+		UserID: *userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{streamAudience},
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	if sessionExpiresAt := GetSessionExpiry(c); sessionExpiresAt != nil {
+		claims.SessionExpiresAt = jwt.NewNumericDate(*sessionExpiresAt)
+		if sessionExpiresAt.Before(expiresAt) {
+			claims.ExpiresAt = claims.SessionExpiresAt
+			expiresAt = *sessionExpiresAt
+		}
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(utils.AppConfig.JWTSecret))
+	return token, expiresAt, err
+}
+
+// StreamAuth authenticates event stream requests. Browser EventSource can't set headers, so
+// besides a Bearer token it accepts a stream token in ?token=.
+func StreamAuth() gin.HandlerFunc {
+	jwtAuth := JWTAuth()
+	return func(c *gin.Context) {
+		tokenString := c.Query("token")
+		if tokenString == "" {
+			jwtAuth(c)
+			return
+		}
+
+		claims := &StreamClaims{}
+		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, jwt.ErrSignatureInvalid
+			}
+			return []byte(utils.AppConfig.JWTSecret), nil
+		}, jwt.WithAudience(streamAudience), jwt.WithExpirationRequired())
+		if err != nil || !token.Valid {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			c.Abort()
+			return
+		}
+
+		if IsSuspended(claims.UserID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account suspended"})
+			c.Abort()
+			return
+		}
+
+		c.Set("userID", claims.UserID)
+		if claims.SessionExpiresAt != nil {
+			c.Set("sessionExpiresAt", claims.SessionExpiresAt.Time)
+		}
+		c.Next()
+	}
+}
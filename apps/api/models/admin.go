@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AdminUser struct {
+	User
+	LinkCount   int `json:"linkCount" db:"link_count"`
+	TotalClicks int `json:"totalClicks" db:"total_clicks"`
+}
+
+type AdminUpdateLinkRequest struct {
+	Disabled *bool   `json:"disabled" binding:"required"`
+	Reason   *string `json:"reason,omitempty"`
+}
+
+type SuspendUserRequest struct {
+	Reason *string `json:"reason,omitempty"`
+}
+
+type AuditLogEntry struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	Action    string     `json:"action" db:"action"`
+	ActorID   *uuid.UUID `json:"actorId,omitempty" db:"actor_id"`
+	Target    *string    `json:"target,omitempty" db:"target"`
+	IP        *string    `json:"ip,omitempty" db:"ip"`
+	UserAgent *string    `json:"userAgent,omitempty" db:"user_agent"`
+	Success   bool       `json:"success" db:"success"`
+	Details   *string    `json:"details,omitempty" db:"details"`
+	CreatedAt time.Time  `json:"createdAt" db:"created_at"`
+}
+
+type SystemStats struct {
+	TotalUsers     int `json:"totalUsers" db:"total_users"`
+	SuspendedUsers int `json:"suspendedUsers" db:"suspended_users"`
+	TotalLinks     int `json:"totalLinks" db:"total_links"`
+	AnonymousLinks int `json:"anonymousLinks" db:"anonymous_links"`
+	DisabledLinks  int `json:"disabledLinks" db:"disabled_links"`
+	TotalClicks    int `json:"totalClicks" db:"total_clicks"`
+	ClicksLast24h  int `json:"clicksLast24h" db:"clicks_last_24h"`
+	ClicksLast7d   int `json:"clicksLast7d" db:"clicks_last_7d"`
+	UniqueVisitors int `json:"uniqueVisitors" db:"unique_visitors"`
+}
+
+type ImpersonationResponse struct {
+	Token     string    `json:"token"`
+	User      User      `json:"user"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Domain struct {
+	ID                 uuid.UUID  `json:"id" db:"id"`
+	Hostname           string     `json:"hostname" db:"hostname"`
+	WorkspaceID        uuid.UUID  `json:"workspaceId" db:"workspace_id"`
+	CreatedBy          *uuid.UUID `json:"createdBy,omitempty" db:"created_by"`
+	VerificationToken  string     `json:"verificationToken" db:"verification_token"`
+	VerifiedAt         *time.Time `json:"verifiedAt,omitempty" db:"verified_at"`
+	DefaultRedirectURL *string    `json:"defaultRedirectUrl,omitempty" db:"default_redirect_url"`
+	NotFoundURL        *string    `json:"notFoundUrl,omitempty" db:"not_found_url"`
+	CreatedAt          time.Time  `json:"createdAt" db:"created_at"`
+
+	// DNS record the owner must publish to verify the domain (not stored)
+	VerificationRecord *DNSRecord `json:"verificationRecord,omitempty" db:"-"`
+}
+
+type DNSRecord struct {
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type CreateDomainRequest struct {
+	Hostname    string     `json:"hostname" binding:"required"`
+	WorkspaceID *uuid.UUID `json:"workspaceId,omitempty"`
+}
+
+// UpdateDomainRequest sets http(s) URLs to redirect to; "" clears a setting
+type UpdateDomainRequest struct {
+	DefaultRedirectURL *string `json:"defaultRedirectUrl,omitempty"`
+	NotFoundURL        *string `json:"notFoundUrl,omitempty"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Favicon is a site icon downloaded and stored by host
+type Favicon struct {
+	Domain      string    `json:"domain" db:"domain"`
+	ContentType string    `json:"contentType" db:"content_type"`
+	Data        []byte    `json:"-" db:"data"`
+	Hash        string    `json:"hash" db:"hash"`
+	SourceURL   string    `json:"sourceUrl" db:"source_url"`
+	FetchedAt   time.Time `json:"fetchedAt" db:"fetched_at"`
+}
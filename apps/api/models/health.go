@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Link health states
+const (
+	HealthOK     = "ok"
+	HealthBroken = "broken"
+)
+
+// LinkCheck is one health check of a link's destination
+type LinkCheck struct {
+	ID         uuid.UUID `json:"id" db:"id"`
+	LinkID     uuid.UUID `json:"linkId" db:"link_id"`
+	CheckedAt  time.Time `json:"checkedAt" db:"checked_at"`
+	Healthy    bool      `json:"healthy" db:"healthy"`
+	StatusCode *int      `json:"statusCode,omitempty" db:"status_code"`
+	LatencyMs  int       `json:"latencyMs" db:"latency_ms"`
+	// RedirectChain lists the URLs redirected to after the original, in order
+	RedirectChain pq.StringArray `json:"redirectChain" db:"redirect_chain"`
+	Error         *string        `json:"error,omitempty" db:"error"`
+}
+
+// LinkHealth is a link's current health with its recent checks
+type LinkHealth struct {
+	Status     *string     `json:"status"`
+	StatusCode *int        `json:"statusCode,omitempty"`
+	Failures   int         `json:"failures"`
+	CheckedAt  *time.Time  `json:"checkedAt,omitempty"`
+	Checks     []LinkCheck `json:"checks"`
+}
+
+// HealthCheckResult summarises a health check run over all active links
+type HealthCheckResult struct {
+	Checked  int `json:"checked"`
+	Broken   int `json:"broken"`
+	Disabled int `json:"disabled"`
+}
@@ -20,6 +20,54 @@ type Link struct {
 	UserID      *uuid.UUID `json:"userId,omitempty" db:"user_id"`
 	FaviconURL  *string    `json:"faviconUrl,omitempty" db:"favicon_url"`
 	Disabled    bool       `json:"disabled" db:"disabled"`
+	WorkspaceID *uuid.UUID `json:"workspaceId,omitempty" db:"workspace_id"`
+	// Set when an admin or URL screening takes the link down; only an admin can lift it
+	BlockedAt     *time.Time `json:"blockedAt,omitempty" db:"blocked_at"`
+	BlockedReason *string    `json:"blockedReason,omitempty" db:"blocked_reason"`
+	// Hash of the secret that lets anonymous creators manage the link
+	ManagementTokenHash *string `json:"-" db:"management_token_hash"`
+	// Set by URL screening when a destination is blocked or looks suspicious
+	FlaggedReason *string    `json:"flaggedReason,omitempty" db:"flagged_reason"`
+	ScreenedAt    *time.Time `json:"screenedAt,omitempty" db:"screened_at"`
+	// PreviewMode always shows the preview page before redirecting
+	PreviewMode bool `json:"previewMode" db:"preview_mode"`
+	// DomainID is the custom short domain the slug lives on; nil means the default domain
+	DomainID *uuid.UUID `json:"domainId,omitempty" db:"domain_id"`
+	// MaxClicks stops the link redirecting once it has been followed this many times
+	MaxClicks *int `json:"maxClicks,omitempty" db:"max_clicks"`
+	// OneTime links work once and hide their destination until then ("burn after reading")
+	OneTime bool `json:"oneTime" db:"one_time"`
+	// ForwardQuery merges the visitor's query string into the destination. QueryPrecedence is
+	// "destination" or "incoming" and decides which value wins for parameters set on both.
+	ForwardQuery    bool   `json:"forwardQuery" db:"forward_query"`
+	QueryPrecedence string `json:"queryPrecedence" db:"query_precedence"`
+	// ForwardPath makes a prefix link: extra path segments after the slug are appended to the destination
+	ForwardPath bool `json:"forwardPath" db:"forward_path"`
+	// UTMTemplateID adds a template's UTM parameters to the destination when redirecting
+	UTMTemplateID *uuid.UUID `json:"utmTemplateId,omitempty" db:"utm_template_id"`
+	// Deep link targets for mobile visitors, with app store fallbacks. Original is the web fallback.
+	IOSAppURL       *string `json:"iosAppUrl,omitempty" db:"ios_app_url"`
+	IOSStoreURL     *string `json:"iosStoreUrl,omitempty" db:"ios_store_url"`
+	AndroidAppURL   *string `json:"androidAppUrl,omitempty" db:"android_app_url"`
+	AndroidStoreURL *string `json:"androidStoreUrl,omitempty" db:"android_store_url"`
+	// Page metadata scraped from the destination's <title>, description and og:/twitter: tags
+	MetaTitle         *string    `json:"metaTitle,omitempty" db:"meta_title"`
+	MetaDescription   *string    `json:"metaDescription,omitempty" db:"meta_description"`
+	MetaImage         *string    `json:"metaImage,omitempty" db:"meta_image"`
+	MetaSiteName      *string    `json:"metaSiteName,omitempty" db:"meta_site_name"`
+	MetadataFetchedAt *time.Time `json:"metadataFetchedAt,omitempty" db:"metadata_fetched_at"`
+	// Social card overrides served to link preview crawlers instead of the destination's own tags
+	OGTitle       *string `json:"ogTitle,omitempty" db:"og_title"`
+	OGDescription *string `json:"ogDescription,omitempty" db:"og_description"`
+	OGImage       *string `json:"ogImage,omitempty" db:"og_image"`
+	// Latest destination health check: HealthStatus is "ok" or "broken", nil until first checked.
+	// HealthFailures counts consecutive failed checks.
+	HealthStatus     *string    `json:"healthStatus,omitempty" db:"health_status"`
+	HealthStatusCode *int       `json:"healthStatusCode,omitempty" db:"health_status_code"`
+	HealthFailures   int        `json:"healthFailures" db:"health_failures"`
+	HealthCheckedAt  *time.Time `json:"healthCheckedAt,omitempty" db:"health_checked_at"`
+	// ExpiredEventAt is when the link.expired webhook event was sent
+	ExpiredEventAt *time.Time `json:"-" db:"expired_event_at"`
 	
 	// Additional fields for API responses (not stored in DB)
 	ShortURL  string `json:"shortUrl,omitempty" db:"-"`
@@ -33,11 +81,42 @@ type CreateLinkRequest struct {
 	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
 	ActiveFrom *time.Time `json:"activeFrom,omitempty"`
 	Password   *string    `json:"password,omitempty"`
+	// WorkspaceID defaults to the user's personal workspace when omitted
+	WorkspaceID *uuid.UUID `json:"workspaceId,omitempty"`
+	// Verification is a CAPTCHA or proof-of-work token, required for anonymous links when configured
+	Verification *string `json:"verification,omitempty"`
+	PreviewMode  bool    `json:"previewMode,omitempty"`
+	// DomainID places the link on a verified custom domain of the same workspace
+	DomainID  *uuid.UUID `json:"domainId,omitempty"`
+	MaxClicks *int       `json:"maxClicks,omitempty" binding:"omitempty,min=1"`
+	// OneTime creates a link that can be followed once; it implies maxClicks of 1
+	OneTime         bool   `json:"oneTime,omitempty"`
+	ForwardQuery    bool   `json:"forwardQuery,omitempty"`
+	QueryPrecedence string `json:"queryPrecedence,omitempty" binding:"omitempty,oneof=destination incoming"`
+	ForwardPath     bool   `json:"forwardPath,omitempty"`
+	// UTMTemplateID must be a template the user can access
+	UTMTemplateID *uuid.UUID `json:"utmTemplateId,omitempty"`
+	// Deep link targets; app URLs may use a custom scheme, store URLs must be http(s)
+	IOSAppURL       *string `json:"iosAppUrl,omitempty"`
+	IOSStoreURL     *string `json:"iosStoreUrl,omitempty" binding:"omitempty,url"`
+	AndroidAppURL   *string `json:"androidAppUrl,omitempty"`
+	AndroidStoreURL *string `json:"androidStoreUrl,omitempty" binding:"omitempty,url"`
+	// Social card shown by chat apps and social networks when the short link is shared
+	OGTitle       *string `json:"ogTitle,omitempty" binding:"omitempty,max=300"`
+	OGDescription *string `json:"ogDescription,omitempty" binding:"omitempty,max=1000"`
+	OGImage       *string `json:"ogImage,omitempty" binding:"omitempty,url"`
 }
 
 type CreateLinkResponse struct {
 	ShortURL string `json:"shortUrl"`
 	Slug     string `json:"slug"`
+	// ManagementToken is only returned once, for anonymous links
+	ManagementToken string `json:"managementToken,omitempty"`
+}
+
+type ClaimLinksRequest struct {
+	Tokens      []string   `json:"tokens" binding:"required,min=1"`
+	WorkspaceID *uuid.UUID `json:"workspaceId,omitempty"`
 }
 
 type AccessLinkRequest struct {
@@ -45,13 +124,35 @@ type AccessLinkRequest struct {
 }
 
 type UpdateLinkRequest struct {
-	Name     *string `json:"name,omitempty"`
-	Disabled *bool   `json:"disabled,omitempty"`
+	Name        *string `json:"name,omitempty"`
+	Disabled    *bool   `json:"disabled,omitempty"`
+	PreviewMode *bool   `json:"previewMode,omitempty"`
+	// MaxClicks of 0 removes the click limit
+	MaxClicks       *int    `json:"maxClicks,omitempty" binding:"omitempty,min=0"`
+	ForwardQuery    *bool   `json:"forwardQuery,omitempty"`
+	QueryPrecedence *string `json:"queryPrecedence,omitempty" binding:"omitempty,oneof=destination incoming"`
+	ForwardPath     *bool   `json:"forwardPath,omitempty"`
+	// UTMTemplateID of "" removes the template
+	UTMTemplateID *string `json:"utmTemplateId,omitempty"`
+	// Deep link targets; "" removes a target
+	IOSAppURL       *string `json:"iosAppUrl,omitempty"`
+	IOSStoreURL     *string `json:"iosStoreUrl,omitempty"`
+	AndroidAppURL   *string `json:"androidAppUrl,omitempty"`
+	AndroidStoreURL *string `json:"androidStoreUrl,omitempty"`
+	// Social card overrides; "" removes an override
+	OGTitle       *string `json:"ogTitle,omitempty" binding:"omitempty,max=300"`
+	OGDescription *string `json:"ogDescription,omitempty" binding:"omitempty,max=1000"`
+	OGImage       *string `json:"ogImage,omitempty"`
 }
 
 type LinkStats struct {
 	TotalClicks  int `json:"totalClicks" db:"total_clicks"`
 	UniqueClicks int `json:"uniqueClicks" db:"unique_clicks"`
+	QRScans      int `json:"qrScans" db:"qr_scans"`
+	// Variants breaks clicks down per A/B variant
+	Variants []VariantStats `json:"variants" db:"-"`
+	// Campaigns breaks clicks down by UTM source, medium and campaign
+	Campaigns []CampaignStats `json:"campaigns" db:"-"`
 }
 
 type ClickEvent struct {
@@ -63,4 +164,32 @@ type ClickEvent struct {
 	Device    *string   `json:"device,omitempty" db:"device"`
 	Lat       *float64  `json:"lat,omitempty" db:"lat"`
 	Lng       *float64  `json:"lng,omitempty" db:"lng"`
+	// RuleID is the targeting rule that chose the destination, nil for the original URL
+	RuleID *uuid.UUID `json:"ruleId,omitempty" db:"rule_id"`
+	// VariantID is the A/B variant the visitor was assigned to
+	VariantID *uuid.UUID `json:"variantId,omitempty" db:"variant_id"`
+	// Campaign parameters of the destination the visitor was sent to
+	UTMSource   *string `json:"utmSource,omitempty" db:"utm_source"`
+	UTMMedium   *string `json:"utmMedium,omitempty" db:"utm_medium"`
+	UTMCampaign *string `json:"utmCampaign,omitempty" db:"utm_campaign"`
+	// Source is set from the ?src= marker, e.g. "qr" for scanned QR codes
+	Source *string `json:"source,omitempty" db:"source"`
+}
+
+// ClickStreamEvent is a click pushed live to dashboards over GET /api/stream/clicks
+type ClickStreamEvent struct {
+	ID          uuid.UUID  `json:"id"`
+	LinkID      uuid.UUID  `json:"linkId"`
+	WorkspaceID *uuid.UUID `json:"workspaceId,omitempty"`
+	Slug        string     `json:"slug"`
+	Timestamp   time.Time  `json:"timestamp"`
+	Country     *string    `json:"country,omitempty"`
+	OS          string     `json:"os"`
+	DeviceType  string     `json:"deviceType"`
+	Source      *string    `json:"source,omitempty"`
+	RuleID      *uuid.UUID `json:"ruleId,omitempty"`
+	VariantID   *uuid.UUID `json:"variantId,omitempty"`
+	UTMSource   *string    `json:"utmSource,omitempty"`
+	UTMMedium   *string    `json:"utmMedium,omitempty"`
+	UTMCampaign *string    `json:"utmCampaign,omitempty"`
 }
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Report frequencies
+const (
+	ReportOff     = "off"
+	ReportWeekly  = "weekly"
+	ReportMonthly = "monthly"
+)
+
+// ReportSettings is a user's opt-in to scheduled performance report emails
+type ReportSettings struct {
+	UserID uuid.UUID `json:"-" db:"user_id"`
+	// Frequency is "off", "weekly" or "monthly"
+	Frequency string `json:"frequency" db:"frequency"`
+	// WorkspaceID limits the report to one workspace; nil covers all of the user's workspaces
+	WorkspaceID   *uuid.UUID `json:"workspaceId,omitempty" db:"workspace_id"`
+	LastPeriodEnd *time.Time `json:"-" db:"last_period_end"`
+	LastSentAt    *time.Time `json:"lastSentAt,omitempty" db:"last_sent_at"`
+	CreatedAt     time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt     time.Time  `json:"updatedAt" db:"updated_at"`
+}
+
+type UpdateReportSettingsRequest struct {
+	Frequency string `json:"frequency" binding:"required,oneof=off weekly monthly"`
+	// WorkspaceID of "" covers all of the user's workspaces
+	WorkspaceID *string `json:"workspaceId,omitempty"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Link rule kinds
+const (
+	RuleCountry  = "country"
+	RuleDevice   = "device"
+	RuleLanguage = "language"
+	RuleTime     = "time"
+)
+
+type LinkRule struct {
+	ID       uuid.UUID `json:"id" db:"id"`
+	LinkID   uuid.UUID `json:"linkId" db:"link_id"`
+	Position int       `json:"position" db:"position"`
+	Kind     string    `json:"kind" db:"kind"`
+	// Values are country codes, devices, languages or, for time rules, weekdays (mon..sun)
+	Values pq.StringArray `json:"values" db:"match_values"`
+	// Time rules match between StartTime and EndTime (HH:MM) in Timezone
+	StartTime   *string   `json:"startTime,omitempty" db:"start_time"`
+	EndTime     *string   `json:"endTime,omitempty" db:"end_time"`
+	Timezone    *string   `json:"timezone,omitempty" db:"timezone"`
+	Destination string    `json:"destination" db:"destination"`
+	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
+}
+
+type LinkRuleInput struct {
+	Kind        string   `json:"kind" binding:"required,oneof=country device language time"`
+	Values      []string `json:"values"`
+	StartTime   *string  `json:"startTime,omitempty"`
+	EndTime     *string  `json:"endTime,omitempty"`
+	Timezone    *string  `json:"timezone,omitempty"`
+	Destination string   `json:"destination" binding:"required,url"`
+}
+
+// SetLinkRulesRequest replaces all rules of a link; rules are evaluated in the order given
+type SetLinkRulesRequest struct {
+	Rules []LinkRuleInput `json:"rules" binding:"dive"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type BlocklistEntry struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	Kind      string     `json:"kind" db:"kind"`
+	Pattern   string     `json:"pattern" db:"pattern"`
+	Reason    *string    `json:"reason,omitempty" db:"reason"`
+	CreatedBy *uuid.UUID `json:"createdBy,omitempty" db:"created_by"`
+	CreatedAt time.Time  `json:"createdAt" db:"created_at"`
+}
+
+type CreateBlocklistEntryRequest struct {
+	Kind    string  `json:"kind" binding:"required,oneof=domain regex"`
+	Pattern string  `json:"pattern" binding:"required"`
+	Reason  *string `json:"reason,omitempty"`
+}
+
+type RecheckResult struct {
+	Checked  int `json:"checked"`
+	Flagged  int `json:"flagged"`
+	Disabled int `json:"disabled"`
+}
@@ -7,8 +7,10 @@ import (
 )
 
 type User struct {
-	ID        uuid.UUID `json:"id" db:"id"`
-	Email     string    `json:"email" db:"email"`
-	Password  string    `json:"-" db:"password"` // Don't include in JSON responses
-	CreatedAt time.Time `json:"createdAt" db:"created_at"`
-}
\ No newline at end of file
+	ID          uuid.UUID  `json:"id" db:"id"`
+	Email       string     `json:"email" db:"email"`
+	Password    string     `json:"-" db:"password"` // Don't include in JSON responses
+	CreatedAt   time.Time  `json:"createdAt" db:"created_at"`
+	IsAdmin     bool       `json:"isAdmin" db:"is_admin"`
+	SuspendedAt *time.Time `json:"suspendedAt,omitempty" db:"suspended_at"`
+}
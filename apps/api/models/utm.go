@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type UTMTemplate struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	Name        string     `json:"name" db:"name"`
+	UserID      *uuid.UUID `json:"userId,omitempty" db:"user_id"`
+	WorkspaceID *uuid.UUID `json:"workspaceId,omitempty" db:"workspace_id"`
+	Source      *string    `json:"utmSource,omitempty" db:"utm_source"`
+	Medium      *string    `json:"utmMedium,omitempty" db:"utm_medium"`
+	Campaign    *string    `json:"utmCampaign,omitempty" db:"utm_campaign"`
+	Term        *string    `json:"utmTerm,omitempty" db:"utm_term"`
+	Content     *string    `json:"utmContent,omitempty" db:"utm_content"`
+	CreatedAt   time.Time  `json:"createdAt" db:"created_at"`
+}
+
+type CreateUTMTemplateRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+	// WorkspaceID shares the template with a workspace; omit it for a personal template
+	WorkspaceID *uuid.UUID `json:"workspaceId,omitempty"`
+	Source      *string    `json:"utmSource,omitempty"`
+	Medium      *string    `json:"utmMedium,omitempty"`
+	Campaign    *string    `json:"utmCampaign,omitempty"`
+	Term        *string    `json:"utmTerm,omitempty"`
+	Content     *string    `json:"utmContent,omitempty"`
+}
+
+// UpdateUTMTemplateRequest changes the given fields; empty strings clear a parameter
+type UpdateUTMTemplateRequest struct {
+	Name     *string `json:"name,omitempty" binding:"omitempty,min=1,max=100"`
+	Source   *string `json:"utmSource,omitempty"`
+	Medium   *string `json:"utmMedium,omitempty"`
+	Campaign *string `json:"utmCampaign,omitempty"`
+	Term     *string `json:"utmTerm,omitempty"`
+	Content  *string `json:"utmContent,omitempty"`
+}
+
+type CampaignStats struct {
+	Source   string `json:"utmSource" db:"utm_source"`
+	Medium   string `json:"utmMedium" db:"utm_medium"`
+	Campaign string `json:"utmCampaign" db:"utm_campaign"`
+	Clicks   int    `json:"clicks" db:"clicks"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type LinkVariant struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	LinkID      uuid.UUID `json:"linkId" db:"link_id"`
+	Position    int       `json:"position" db:"position"`
+	Name        *string   `json:"name,omitempty" db:"name"`
+	Destination string    `json:"destination" db:"destination"`
+	Weight      int       `json:"weight" db:"weight"`
+	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
+}
+
+type LinkVariantInput struct {
+	// ID keeps an existing variant, and its click history, when the list is replaced
+	ID          *uuid.UUID `json:"id,omitempty"`
+	Name        *string    `json:"name,omitempty"`
+	Destination string     `json:"destination" binding:"required,url"`
+	Weight      int        `json:"weight" binding:"required,min=1,max=1000"`
+}
+
+// SetLinkVariantsRequest replaces all variants of a link; an empty list turns the split off.
+// Variants left out of the list are deleted.
+type SetLinkVariantsRequest struct {
+	Variants []LinkVariantInput `json:"variants" binding:"dive"`
+}
+
+type VariantStats struct {
+	ID           uuid.UUID `json:"id" db:"id"`
+	Name         *string   `json:"name,omitempty" db:"name"`
+	Destination  string    `json:"destination" db:"destination"`
+	Weight       int       `json:"weight" db:"weight"`
+	Clicks       int       `json:"clicks" db:"clicks"`
+	UniqueClicks int       `json:"uniqueClicks" db:"unique_clicks"`
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Webhook event types
+const (
+	EventLinkCreated   = "link.created"
+	EventLinkUpdated   = "link.updated"
+	EventLinkDeleted   = "link.deleted"
+	EventLinkExpired   = "link.expired"
+	EventClickRecorded = "click.recorded"
+	// EventPing is sent by the test endpoint; webhooks don't subscribe to it
+	EventPing = "ping"
+)
+
+// WebhookEvents are the events a webhook can subscribe to
+var WebhookEvents = map[string]bool{
+	EventLinkCreated:   true,
+	EventLinkUpdated:   true,
+	EventLinkDeleted:   true,
+	EventLinkExpired:   true,
+	EventClickRecorded: true,
+}
+
+// Webhook delivery states
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+type Webhook struct {
+	ID          uuid.UUID      `json:"id" db:"id"`
+	WorkspaceID uuid.UUID      `json:"workspaceId" db:"workspace_id"`
+	CreatedBy   *uuid.UUID     `json:"createdBy,omitempty" db:"created_by"`
+	URL         string         `json:"url" db:"url"`
+	Secret      string         `json:"-" db:"secret"`
+	Events      pq.StringArray `json:"events" db:"events"`
+	// ClickSampleRate is the fraction of click.recorded events delivered
+	ClickSampleRate float64   `json:"clickSampleRate" db:"click_sample_rate"`
+	Active          bool      `json:"active" db:"active"`
+	CreatedAt       time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt       time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+// WebhookWithSecret is returned once, when a webhook is created, so the receiver can verify signatures
+type WebhookWithSecret struct {
+	Webhook
+	Secret string `json:"secret"`
+}
+
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" binding:"required,url"`
+	Events []string `json:"events" binding:"required,min=1"`
+	// ClickSampleRate defaults to 1, delivering every click
+	ClickSampleRate *float64 `json:"clickSampleRate,omitempty" binding:"omitempty,min=0,max=1"`
+	// WorkspaceID defaults to the user's personal workspace
+	WorkspaceID *uuid.UUID `json:"workspaceId,omitempty"`
+}
+
+type UpdateWebhookRequest struct {
+	URL             *string  `json:"url,omitempty" binding:"omitempty,url"`
+	Events          []string `json:"events,omitempty"`
+	ClickSampleRate *float64 `json:"clickSampleRate,omitempty" binding:"omitempty,min=0,max=1"`
+	Active          *bool    `json:"active,omitempty"`
+}
+
+// WebhookDelivery is one attempt-tracked delivery of an event to a webhook
+type WebhookDelivery struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	WebhookID      uuid.UUID  `json:"webhookId" db:"webhook_id"`
+	EventID        uuid.UUID  `json:"eventId" db:"event_id"`
+	Event          string     `json:"event" db:"event"`
+	Payload        string     `json:"payload" db:"payload"`
+	Status         string     `json:"status" db:"status"`
+	Attempts       int        `json:"attempts" db:"attempts"`
+	NextAttemptAt  time.Time  `json:"nextAttemptAt" db:"next_attempt_at"`
+	LastAttemptAt  *time.Time `json:"lastAttemptAt,omitempty" db:"last_attempt_at"`
+	ResponseStatus *int       `json:"responseStatus,omitempty" db:"response_status"`
+	ResponseBody   *string    `json:"responseBody,omitempty" db:"response_body"`
+	Error          *string    `json:"error,omitempty" db:"error"`
+	ReplayOf       *uuid.UUID `json:"replayOf,omitempty" db:"replay_of"`
+	CreatedAt      time.Time  `json:"createdAt" db:"created_at"`
+	DeliveredAt    *time.Time `json:"deliveredAt,omitempty" db:"delivered_at"`
+}
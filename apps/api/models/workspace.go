@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Workspace roles, from most to least privileged
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// RoleRank orders workspace roles so they can be compared
+var RoleRank = map[string]int{
+	RoleOwner:  4,
+	RoleAdmin:  3,
+	RoleEditor: 2,
+	RoleViewer: 1,
+}
+
+type Workspace struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	Name      string     `json:"name" db:"name"`
+	Personal  bool       `json:"personal" db:"personal"`
+	CreatedBy *uuid.UUID `json:"createdBy,omitempty" db:"created_by"`
+	CreatedAt time.Time  `json:"createdAt" db:"created_at"`
+
+	// Role of the requesting user (not stored on the workspace row)
+	Role string `json:"role,omitempty" db:"role"`
+}
+
+type WorkspaceMember struct {
+	WorkspaceID uuid.UUID `json:"workspaceId" db:"workspace_id"`
+	UserID      uuid.UUID `json:"userId" db:"user_id"`
+	Email       string    `json:"email" db:"email"`
+	Role        string    `json:"role" db:"role"`
+	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
+}
+
+type WorkspaceInvitation struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	WorkspaceID uuid.UUID  `json:"workspaceId" db:"workspace_id"`
+	Email       string     `json:"email" db:"email"`
+	Role        string     `json:"role" db:"role"`
+	TokenHash   string     `json:"-" db:"token_hash"`
+	InvitedBy   *uuid.UUID `json:"invitedBy,omitempty" db:"invited_by"`
+	ExpiresAt   time.Time  `json:"expiresAt" db:"expires_at"`
+	AcceptedAt  *time.Time `json:"acceptedAt,omitempty" db:"accepted_at"`
+	CreatedAt   time.Time  `json:"createdAt" db:"created_at"`
+}
+
+type CreateWorkspaceRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type InviteMemberRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required,oneof=admin editor viewer"`
+}
+
+type UpdateMemberRequest struct {
+	Role string `json:"role" binding:"required,oneof=owner admin editor viewer"`
+}
+
+type InviteMemberResponse struct {
+	Invitation WorkspaceInvitation `json:"invitation"`
+	Token      string              `json:"token"`
+	EmailSent  bool                `json:"emailSent"`
+}
@@ -0,0 +1,130 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+	"url-shortener-api/models"
+	"url-shortener-api/utils"
+)
+
+// Anonymous link creation modes
+const (
+	ModeAllow      = "allow"
+	ModeRestricted = "restricted"
+	ModeDisabled   = "disabled"
+)
+
+var (
+	// ErrAnonymousDisabled is returned when anonymous link creation is turned off
+	ErrAnonymousDisabled = errors.New("anonymous link creation is disabled")
+	// ErrDomainNotAllowed is returned when the destination is not on the anonymous allowlist
+	ErrDomainNotAllowed = errors.New("destination domain is not allowed for anonymous links")
+	// ErrPasswordNotAllowed is returned when an anonymous link asks for password protection
+	ErrPasswordNotAllowed = errors.New("password-protected links require an account")
+)
+
+// AnonymousPolicy controls how links without an owner may be created
+type AnonymousPolicy struct {
+	Mode           string
+	MaxExpiry      time.Duration
+	AllowedDomains []string
+	AllowPasswords bool
+	Verifier       Verifier
+}
+
+// Anonymous is the active policy, loaded from AppConfig by LoadAnonymousPolicy
+var Anonymous = AnonymousPolicy{Mode: ModeAllow, Verifier: NoopVerifier{}}
+
+// LoadAnonymousPolicy builds the anonymous link policy from the application config
+func LoadAnonymousPolicy() {
+	cfg := utils.AppConfig
+
+	mode := strings.ToLower(cfg.AnonymousLinks)
+	switch mode {
+	case ModeAllow, ModeRestricted, ModeDisabled:
+	default:
+		log.Printf("Unknown ANONYMOUS_LINKS mode %q, falling back to %q", cfg.AnonymousLinks, ModeAllow)
+		mode = ModeAllow
+	}
+
+	domains := []string{}
+	for _, domain := range strings.Split(cfg.AnonymousAllowedDomains, ",") {
+		if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+
+	var verifier Verifier
+	switch strings.ToLower(cfg.AnonymousVerifier) {
+	case "stub":
+		verifier = StubVerifier{Token: cfg.VerifierStubToken}
+	case "pow":
+		verifier = NewProofOfWorkVerifier([]byte(cfg.JWTSecret), cfg.ProofOfWorkDifficulty, PostgresReplayStore{})
+	default:
+		verifier = NoopVerifier{}
+	}
+
+	Anonymous = AnonymousPolicy{
+		Mode:           mode,
+		MaxExpiry:      time.Duration(cfg.AnonymousMaxExpiryHours) * time.Hour,
+		AllowedDomains: domains,
+		AllowPasswords: cfg.AnonymousAllowPasswords,
+		Verifier:       verifier,
+	}
+}
+
+// Apply checks an anonymous create request against the policy. In restricted mode it
+// also clamps the expiry to the configured maximum, modifying req in place. The policy's own
+// checks run before verification, so a request they reject doesn't use up its one-time token.
+func (p AnonymousPolicy) Apply(ctx context.Context, req *models.CreateLinkRequest, remoteIP string) error {
+	if p.Mode == ModeDisabled {
+		return ErrAnonymousDisabled
+	}
+
+	if p.Mode == ModeRestricted {
+		if !p.AllowPasswords && req.Password != nil && *req.Password != "" {
+			return ErrPasswordNotAllowed
+		}
+
+		if len(p.AllowedDomains) > 0 && !p.domainAllowed(req.URL) {
+			return ErrDomainNotAllowed
+		}
+	}
+
+	token := ""
+	if req.Verification != nil {
+		token = *req.Verification
+	}
+	if err := p.Verifier.Verify(ctx, token, remoteIP); err != nil {
+		return err
+	}
+
+	if p.Mode == ModeRestricted && p.MaxExpiry > 0 {
+		maxExpiry := time.Now().Add(p.MaxExpiry)
+		if req.ExpiresAt == nil || req.ExpiresAt.After(maxExpiry) {
+			req.ExpiresAt = &maxExpiry
+		}
+	}
+
+	return nil
+}
+
+// domainAllowed reports whether the URL's host is an allowed domain or a subdomain of one
+func (p AnonymousPolicy) domainAllowed(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+	for _, domain := range p.AllowedDomains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
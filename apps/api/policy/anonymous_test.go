@@ -0,0 +1,84 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"url-shortener-api/models"
+)
+
+// failingReplayStore stands in for a replay store whose database is unreachable
+type failingReplayStore struct{}
+
+func (failingReplayStore) Use(context.Context, string, time.Time) (bool, error) {
+	return false, errors.New("connection refused")
+}
+
+func TestApplyChecksPolicyBeforeVerifying(t *testing.T) {
+	verifier := newTestVerifier()
+	p := AnonymousPolicy{
+		Mode:           ModeRestricted,
+		AllowedDomains: []string{"example.com"},
+		Verifier:       verifier,
+	}
+	challenge, err := verifier.NewChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := SolveChallenge(challenge.Challenge, challenge.Difficulty)
+	password := "secret"
+
+	rejected := []struct {
+		req  models.CreateLinkRequest
+		want error
+	}{
+		{models.CreateLinkRequest{URL: "https://other.example.org/", Verification: &token}, ErrDomainNotAllowed},
+		{models.CreateLinkRequest{URL: "https://example.com/", Password: &password, Verification: &token}, ErrPasswordNotAllowed},
+	}
+	for _, tt := range rejected {
+		if err := p.Apply(context.Background(), &tt.req, "127.0.0.1"); !errors.Is(err, tt.want) {
+			t.Errorf("Apply(%s) = %v, want %v", tt.req.URL, err, tt.want)
+		}
+	}
+
+	// The rejected requests left the challenge unused
+	req := models.CreateLinkRequest{URL: "https://www.example.com/", Verification: &token}
+	if err := p.Apply(context.Background(), &req, "127.0.0.1"); err != nil {
+		t.Errorf("Apply(allowed request) = %v, want nil", err)
+	}
+	if err := p.Apply(context.Background(), &req, "127.0.0.1"); !errors.Is(err, ErrVerificationFailed) {
+		t.Errorf("Apply(replayed request) = %v, want ErrVerificationFailed", err)
+	}
+}
+
+func TestApplyClampsExpiry(t *testing.T) {
+	p := AnonymousPolicy{Mode: ModeRestricted, MaxExpiry: time.Hour, Verifier: NoopVerifier{}}
+
+	late := time.Now().Add(48 * time.Hour)
+	for _, expiresAt := range []*time.Time{nil, &late} {
+		req := models.CreateLinkRequest{URL: "https://example.com/", ExpiresAt: expiresAt}
+		if err := p.Apply(context.Background(), &req, "127.0.0.1"); err != nil {
+			t.Fatalf("Apply() = %v, want nil", err)
+		}
+		if req.ExpiresAt == nil || time.Until(*req.ExpiresAt) > time.Hour {
+			t.Errorf("ExpiresAt = %v, want at most an hour away", req.ExpiresAt)
+		}
+	}
+}
+
+func TestApplyReportsReplayStoreErrors(t *testing.T) {
+	verifier := NewProofOfWorkVerifier([]byte("test-secret"), testDifficulty, failingReplayStore{})
+	challenge, err := verifier.NewChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := SolveChallenge(challenge.Challenge, challenge.Difficulty)
+
+	p := AnonymousPolicy{Mode: ModeAllow, Verifier: verifier}
+	req := models.CreateLinkRequest{URL: "https://example.com/", Verification: &token}
+	err = p.Apply(context.Background(), &req, "127.0.0.1")
+	if err == nil || errors.Is(err, ErrVerificationFailed) {
+		t.Errorf("Apply() = %v, want a store error that isn't a verification failure", err)
+	}
+}
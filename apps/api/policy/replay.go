@@ -0,0 +1,75 @@
+package policy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+	"time"
+	"url-shortener-api/db"
+)
+
+// ReplayStore remembers redeemed one-time values, such as proof-of-work challenges
+type ReplayStore interface {
+	// Use records key until expiresAt and reports whether this was its first use
+	Use(ctx context.Context, key string, expiresAt time.Time) (bool, error)
+}
+
+// replayPruneInterval is how often expired keys are removed from Postgres
+const replayPruneInterval = time.Minute
+
+// PostgresReplayStore records used keys in the used_challenges table, so a key redeemed on
+// one replica is rejected on every other
+type PostgresReplayStore struct{}
+
+// lastReplayPrune holds the Unix time expired keys were last removed
+var lastReplayPrune atomic.Int64
+
+func (PostgresReplayStore) Use(ctx context.Context, key string, expiresAt time.Time) (bool, error) {
+	now := time.Now()
+	if last := lastReplayPrune.Load(); now.Unix()-last >= int64(replayPruneInterval/time.Second) &&
+		lastReplayPrune.CompareAndSwap(last, now.Unix()) {
+		if _, err := db.DB.ExecContext(ctx, "DELETE FROM used_challenges WHERE expires_at < $1", now); err != nil {
+			return false, err
+		}
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	result, err := db.DB.ExecContext(ctx, `
+		INSERT INTO used_challenges (key_hash, expires_at) VALUES ($1, $2)
+		ON CONFLICT (key_hash) DO NOTHING
+	`, hex.EncodeToString(sum[:]), expiresAt)
+	if err != nil {
+		return false, err
+	}
+	inserted, err := result.RowsAffected()
+	return inserted == 1, err
+}
+
+// MemoryReplayStore keeps used keys in process memory. It only protects a single instance,
+// so it is meant for tests and local development.
+type MemoryReplayStore struct {
+	mu   sync.Mutex
+	used map[string]time.Time
+}
+
+func (s *MemoryReplayStore) Use(ctx context.Context, key string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for k, exp := range s.used {
+		if now.After(exp) {
+			delete(s.used, k)
+		}
+	}
+	if _, seen := s.used[key]; seen {
+		return false, nil
+	}
+	if s.used == nil {
+		s.used = make(map[string]time.Time)
+	}
+	s.used[key] = expiresAt
+	return true, nil
+}
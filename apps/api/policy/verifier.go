@@ -0,0 +1,167 @@
+package policy
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrVerificationFailed is returned when a verification token is missing or invalid
+var ErrVerificationFailed = errors.New("verification failed")
+
+// Verifier checks a client-supplied token proving the request came from a human
+// (CAPTCHA) or paid a computational cost (proof of work)
+type Verifier interface {
+	Verify(ctx context.Context, token string, remoteIP string) error
+}
+
+// ChallengeIssuer is implemented by verifiers that hand out challenges before verification
+type ChallengeIssuer interface {
+	NewChallenge() (Challenge, error)
+}
+
+// Challenge is returned to clients that must solve a proof-of-work puzzle
+type Challenge struct {
+	Challenge  string    `json:"challenge"`
+	Difficulty int       `json:"difficulty"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+// NoopVerifier accepts every request
+type NoopVerifier struct{}
+
+func (NoopVerifier) Verify(ctx context.Context, token string, remoteIP string) error {
+	return nil
+}
+
+// StubVerifier accepts only a fixed token. It stands in for a real CAPTCHA
+// provider in local development and tests.
+type StubVerifier struct {
+	Token string
+}
+
+func (v StubVerifier) Verify(ctx context.Context, token string, remoteIP string) error {
+	if token == "" || !hmac.Equal([]byte(token), []byte(v.Token)) {
+		return ErrVerificationFailed
+	}
+	return nil
+}
+
+// challengeTTL is how long a proof-of-work challenge can be solved
+const challengeTTL = 10 * time.Minute
+
+// ProofOfWorkVerifier issues signed challenges and accepts "challenge:nonce" tokens
+// whose SHA-256 digest starts with Difficulty zero bits. Each challenge can only be used
+// once; Used records redeemed challenges and must be shared by all replicas.
+type ProofOfWorkVerifier struct {
+	Secret     []byte
+	Difficulty int
+	Used       ReplayStore
+}
+
+// NewProofOfWorkVerifier creates a proof-of-work verifier signing challenges with secret
+// and recording used challenges in used
+func NewProofOfWorkVerifier(secret []byte, difficulty int, used ReplayStore) *ProofOfWorkVerifier {
+	return &ProofOfWorkVerifier{
+		Secret:     secret,
+		Difficulty: difficulty,
+		Used:       used,
+	}
+}
+
+func (v *ProofOfWorkVerifier) NewChallenge() (Challenge, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return Challenge{}, err
+	}
+
+	expiresAt := time.Now().Add(challengeTTL)
+	payload := make([]byte, 8, 8+len(nonce))
+	binary.BigEndian.PutUint64(payload, uint64(expiresAt.Unix()))
+	payload = append(payload, nonce...)
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return Challenge{
+		Challenge:  encoded + "." + v.sign(encoded),
+		Difficulty: v.Difficulty,
+		ExpiresAt:  expiresAt,
+	}, nil
+}
+
+func (v *ProofOfWorkVerifier) Verify(ctx context.Context, token string, remoteIP string) error {
+	challenge, nonce, ok := strings.Cut(token, ":")
+	if !ok || nonce == "" {
+		return ErrVerificationFailed
+	}
+
+	encoded, signature, ok := strings.Cut(challenge, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(v.sign(encoded))) {
+		return ErrVerificationFailed
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(payload) < 8 {
+		return ErrVerificationFailed
+	}
+
+	expiresAt := time.Unix(int64(binary.BigEndian.Uint64(payload[:8])), 0)
+	if time.Now().After(expiresAt) {
+		return fmt.Errorf("%w: challenge expired", ErrVerificationFailed)
+	}
+
+	sum := sha256.Sum256([]byte(token))
+	if leadingZeroBits(sum[:]) < v.Difficulty {
+		return ErrVerificationFailed
+	}
+
+	// Reject replays of an already solved challenge
+	first, err := v.Used.Use(ctx, challenge, expiresAt)
+	if err != nil {
+		return fmt.Errorf("recording used challenge: %w", err)
+	}
+	if !first {
+		return fmt.Errorf("%w: challenge already used", ErrVerificationFailed)
+	}
+
+	return nil
+}
+
+func (v *ProofOfWorkVerifier) sign(data string) string {
+	mac := hmac.New(sha256.New, v.Secret)
+	mac.Write([]byte(data))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SolveChallenge brute-forces a nonce for a proof-of-work challenge. It is meant for
+// tests and reference clients; browsers should run the same loop in a worker.
+func SolveChallenge(challenge string, difficulty int) string {
+	for nonce := 0; ; nonce++ {
+		token := challenge + ":" + strconv.Itoa(nonce)
+		sum := sha256.Sum256([]byte(token))
+		if leadingZeroBits(sum[:]) >= difficulty {
+			return token
+		}
+	}
+}
+
+func leadingZeroBits(b []byte) int {
+	n := 0
+	for _, x := range b {
+		if x == 0 {
+			n += 8
+			continue
+		}
+		return n + bits.LeadingZeros8(x)
+	}
+	return n
+}
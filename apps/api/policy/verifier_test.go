@@ -0,0 +1,184 @@
+package policy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testDifficulty = 8
+
+func newTestVerifier() *ProofOfWorkVerifier {
+	return NewProofOfWorkVerifier([]byte("test-secret"), testDifficulty, &MemoryReplayStore{})
+}
+
+func TestStubVerifier(t *testing.T) {
+	v := StubVerifier{Token: "test-token"}
+	if err := v.Verify(context.Background(), "test-token", "127.0.0.1"); err != nil {
+		t.Errorf("Verify(correct token) = %v, want nil", err)
+	}
+	for _, token := range []string{"", "wrong"} {
+		if err := v.Verify(context.Background(), token, "127.0.0.1"); !errors.Is(err, ErrVerificationFailed) {
+			t.Errorf("Verify(%q) = %v, want ErrVerificationFailed", token, err)
+		}
+	}
+}
+
+func TestProofOfWorkVerify(t *testing.T) {
+	v := newTestVerifier()
+	challenge, err := v.NewChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if challenge.Difficulty != testDifficulty {
+		t.Errorf("Difficulty = %d, want %d", challenge.Difficulty, testDifficulty)
+	}
+
+	token := SolveChallenge(challenge.Challenge, challenge.Difficulty)
+	if err := v.Verify(context.Background(), token, "127.0.0.1"); err != nil {
+		t.Fatalf("Verify(solved token) = %v, want nil", err)
+	}
+}
+
+func TestProofOfWorkRejectsReplay(t *testing.T) {
+	v := newTestVerifier()
+	challenge, err := v.NewChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := SolveChallenge(challenge.Challenge, challenge.Difficulty)
+	if err := v.Verify(context.Background(), token, "127.0.0.1"); err != nil {
+		t.Fatalf("first Verify = %v, want nil", err)
+	}
+	if err := v.Verify(context.Background(), token, "127.0.0.1"); !errors.Is(err, ErrVerificationFailed) {
+		t.Errorf("replayed Verify = %v, want ErrVerificationFailed", err)
+	}
+
+	// A different solution of the same challenge is a replay too
+	other := solveFrom(challenge.Challenge, challenge.Difficulty, token)
+	if err := v.Verify(context.Background(), other, "127.0.0.1"); !errors.Is(err, ErrVerificationFailed) {
+		t.Errorf("Verify(second solution) = %v, want ErrVerificationFailed", err)
+	}
+}
+
+func TestProofOfWorkSharedReplayStore(t *testing.T) {
+	// Two replicas sharing a store must not both accept one challenge
+	store := &MemoryReplayStore{}
+	a := NewProofOfWorkVerifier([]byte("test-secret"), testDifficulty, store)
+	b := NewProofOfWorkVerifier([]byte("test-secret"), testDifficulty, store)
+
+	challenge, err := a.NewChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := SolveChallenge(challenge.Challenge, challenge.Difficulty)
+	if err := a.Verify(context.Background(), token, "127.0.0.1"); err != nil {
+		t.Fatalf("Verify on first replica = %v, want nil", err)
+	}
+	if err := b.Verify(context.Background(), token, "127.0.0.1"); !errors.Is(err, ErrVerificationFailed) {
+		t.Errorf("Verify on second replica = %v, want ErrVerificationFailed", err)
+	}
+}
+
+func TestProofOfWorkRejectsInvalidTokens(t *testing.T) {
+	v := newTestVerifier()
+	challenge, err := v.NewChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := NewProofOfWorkVerifier([]byte("other-secret"), testDifficulty, &MemoryReplayStore{})
+	forged, err := other.NewChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"no nonce", challenge.Challenge},
+		{"empty nonce", challenge.Challenge + ":"},
+		{"unsolved", unsolved(challenge.Challenge, testDifficulty)},
+		{"signed with another secret", SolveChallenge(forged.Challenge, testDifficulty)},
+		{"expired", SolveChallenge(expiredChallenge(v), testDifficulty)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := v.Verify(context.Background(), tt.token, "127.0.0.1"); !errors.Is(err, ErrVerificationFailed) {
+				t.Errorf("Verify() = %v, want ErrVerificationFailed", err)
+			}
+		})
+	}
+}
+
+func TestMemoryReplayStoreForgetsExpiredKeys(t *testing.T) {
+	store := &MemoryReplayStore{}
+	ctx := context.Background()
+
+	if first, _ := store.Use(ctx, "key", time.Now().Add(-time.Second)); !first {
+		t.Fatal("first Use = false, want true")
+	}
+	if first, _ := store.Use(ctx, "key", time.Now().Add(time.Minute)); !first {
+		t.Error("Use after expiry = false, want true")
+	}
+	if first, _ := store.Use(ctx, "key", time.Now().Add(time.Minute)); first {
+		t.Error("Use before expiry = true, want false")
+	}
+}
+
+// expiredChallenge signs a challenge that expired a minute ago
+func expiredChallenge(v *ProofOfWorkVerifier) string {
+	payload := make([]byte, 8, 24)
+	binary.BigEndian.PutUint64(payload, uint64(time.Now().Add(-time.Minute).Unix()))
+	payload = append(payload, make([]byte, 16)...)
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + v.sign(encoded)
+}
+
+// unsolved returns a token for challenge whose digest doesn't meet the difficulty
+func unsolved(challenge string, difficulty int) string {
+	for nonce := 0; ; nonce++ {
+		token := challenge + ":" + strconv.Itoa(nonce)
+		sum := sha256.Sum256([]byte(token))
+		if leadingZeroBits(sum[:]) < difficulty {
+			return token
+		}
+	}
+}
+
+// solveFrom finds a solution of challenge other than skip
+func solveFrom(challenge string, difficulty int, skip string) string {
+	for nonce := 0; ; nonce++ {
+		token := challenge + ":" + strconv.Itoa(nonce)
+		sum := sha256.Sum256([]byte(token))
+		if token != skip && leadingZeroBits(sum[:]) >= difficulty {
+			return token
+		}
+	}
+}
+
+func TestLeadingZeroBits(t *testing.T) {
+	tests := []struct {
+		in   []byte
+		want int
+	}{
+		{[]byte{0x80}, 0},
+		{[]byte{0x01}, 7},
+		{[]byte{0x00, 0x0f}, 12},
+		{[]byte{0x00, 0x00}, 16},
+	}
+	for _, tt := range tests {
+		if got := leadingZeroBits(tt.in); got != tt.want {
+			t.Errorf("leadingZeroBits(%x) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
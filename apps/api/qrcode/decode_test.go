@@ -0,0 +1,352 @@
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// formatWords are the masked format information words from table C.1 of the specification,
+// indexed by level and mask
+var formatWords = [4][8]int{
+	LevelL: {0x77C4, 0x72F3, 0x7DAA, 0x789D, 0x662F, 0x6318, 0x6C41, 0x6976},
+	LevelM: {0x5412, 0x5125, 0x5E7C, 0x5B4B, 0x45F9, 0x40CE, 0x4F97, 0x4AA0},
+	LevelQ: {0x355F, 0x3068, 0x3F31, 0x3A06, 0x24B4, 0x2183, 0x2EDA, 0x2BED},
+	LevelH: {0x1689, 0x13BE, 0x1CE7, 0x19D0, 0x0762, 0x0255, 0x0D0C, 0x083B},
+}
+
+// alignmentCentres are the alignment pattern row/column centres from annex E of the
+// specification, indexed by version
+var alignmentCentres = [41][]int{
+	{}, {},
+	{6, 18}, {6, 22}, {6, 26}, {6, 30}, {6, 34},
+	{6, 22, 38}, {6, 24, 42}, {6, 26, 46}, {6, 28, 50}, {6, 30, 54}, {6, 32, 58}, {6, 34, 62},
+	{6, 26, 46, 66}, {6, 26, 48, 70}, {6, 26, 50, 74}, {6, 30, 54, 78}, {6, 30, 56, 82}, {6, 30, 58, 86}, {6, 34, 62, 90},
+	{6, 28, 50, 72, 94}, {6, 26, 50, 74, 98}, {6, 30, 54, 78, 102}, {6, 28, 54, 80, 106}, {6, 32, 58, 84, 110}, {6, 30, 58, 86, 114}, {6, 34, 62, 90, 118},
+	{6, 26, 50, 74, 98, 122}, {6, 30, 54, 78, 102, 126}, {6, 26, 52, 78, 104, 130}, {6, 30, 56, 82, 108, 134}, {6, 34, 60, 86, 112, 138}, {6, 30, 58, 86, 114, 142}, {6, 34, 62, 90, 118, 146},
+	{6, 30, 54, 78, 102, 126, 150}, {6, 24, 50, 76, 102, 128, 154}, {6, 28, 54, 80, 106, 132, 158}, {6, 32, 58, 84, 110, 136, 162}, {6, 26, 54, 82, 110, 138, 166}, {6, 30, 58, 86, 114, 142, 170},
+}
+
+// decode reads a symbol back the way a scanner would: it looks the format information up in
+// the specification's table, removes the mask, reads the codewords, checks the Reed-Solomon
+// syndromes of every block and parses the byte mode segment, padding included
+func decode(code *Code) ([]byte, Level, int, error) {
+	size := code.Size
+	version := (size - 17) / 4
+	dark := func(x, y int) bool { return code.Modules[y][x] }
+
+	// Both copies of the format information must be the same valid word
+	var first, second int
+	for i := 0; i <= 5; i++ {
+		first |= b2i(dark(8, i)) << i
+	}
+	first |= b2i(dark(8, 7))<<6 | b2i(dark(8, 8))<<7 | b2i(dark(7, 8))<<8
+	for i := 9; i < 15; i++ {
+		first |= b2i(dark(14-i, 8)) << i
+	}
+	for i := 0; i < 8; i++ {
+		second |= b2i(dark(size-1-i, 8)) << i
+	}
+	for i := 8; i < 15; i++ {
+		second |= b2i(dark(8, size-15+i)) << i
+	}
+	if first != second {
+		return nil, 0, 0, fmt.Errorf("format copies differ: %015b and %015b", first, second)
+	}
+	level, mask := Level(-1), -1
+	for l, words := range formatWords {
+		for m, word := range words {
+			if word == first {
+				level, mask = Level(l), m
+			}
+		}
+	}
+	if mask < 0 {
+		return nil, 0, 0, fmt.Errorf("invalid format information %015b", first)
+	}
+	if !dark(8, size-8) {
+		return nil, 0, 0, errors.New("missing dark module")
+	}
+
+	// Version 7 and up carry the version twice, protected by the (18, 6) BCH code
+	if version >= 7 {
+		var a, b int
+		for i := 0; i < 18; i++ {
+			a |= b2i(dark(size-11+i%3, i/3)) << i
+			b |= b2i(dark(i/3, size-11+i%3)) << i
+		}
+		if a != b || a>>12 != version || bchRemainder(a, 0x1F25) != 0 {
+			return nil, 0, 0, fmt.Errorf("invalid version information %018b and %018b", a, b)
+		}
+	}
+
+	isFunction := func(x, y int) bool {
+		switch {
+		case x <= 8 && y <= 8, x >= size-8 && y <= 8, x <= 8 && y >= size-8:
+			return true // Finders, separators and format information
+		case x == 6 || y == 6:
+			return true // Timing patterns
+		case version >= 7 && (x >= size-11 && x <= size-9 && y <= 5 || y >= size-11 && y <= size-9 && x <= 5):
+			return true // Version information
+		}
+		centres := alignmentCentres[version]
+		for i, cy := range centres {
+			for j, cx := range centres {
+				last := len(centres) - 1
+				if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+					continue
+				}
+				if abs(x-cx) <= 2 && abs(y-cy) <= 2 {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	masked := func(x, y int) bool {
+		i, j := y, x
+		switch mask {
+		case 0:
+			return (i+j)%2 == 0
+		case 1:
+			return i%2 == 0
+		case 2:
+			return j%3 == 0
+		case 3:
+			return (i+j)%3 == 0
+		case 4:
+			return (i/2+j/3)%2 == 0
+		case 5:
+			return i*j%2+i*j%3 == 0
+		case 6:
+			return (i*j%2+i*j%3)%2 == 0
+		default:
+			return ((i+j)%2+i*j%3)%2 == 0
+		}
+	}
+
+	// Codewords run in two-module columns from the bottom right, alternating up and down
+	var bits []bool
+	upward := true
+	for right := size - 1; right > 0; right -= 2 {
+		if right == 6 {
+			right--
+		}
+		for i := 0; i < size; i++ {
+			y := i
+			if upward {
+				y = size - 1 - i
+			}
+			for x := right; x >= right-1; x-- {
+				if !isFunction(x, y) {
+					bits = append(bits, dark(x, y) != masked(x, y))
+				}
+			}
+		}
+		upward = !upward
+	}
+	codewords := make([]byte, len(bits)/8)
+	for i := range codewords {
+		for _, set := range bits[i*8 : i*8+8] {
+			codewords[i] = codewords[i]<<1 | byte(b2i(set))
+		}
+	}
+
+	// Undo the interleaving: data codewords first, long blocks holding one extra, then ECC
+	numBlocks := numErrorCorrectionBlocks[level][version]
+	eccLen := eccCodewordsPerBlock[level][version]
+	shortLen := len(codewords) / numBlocks
+	numShort := numBlocks - len(codewords)%numBlocks
+	blocks := make([][]byte, numBlocks)
+	next := 0
+	for i := 0; i <= shortLen-eccLen; i++ {
+		for b := range blocks {
+			if i == shortLen-eccLen && b < numShort {
+				continue
+			}
+			blocks[b] = append(blocks[b], codewords[next])
+			next++
+		}
+	}
+	for i := 0; i < eccLen; i++ {
+		for b := range blocks {
+			blocks[b] = append(blocks[b], codewords[next])
+			next++
+		}
+	}
+
+	var data []byte
+	for b, block := range blocks {
+		for i := 0; i < eccLen; i++ {
+			if syndrome(block, gfPower(i)) != 0 {
+				return nil, 0, 0, fmt.Errorf("block %d: syndrome %d is not zero", b, i)
+			}
+		}
+		data = append(data, block[:len(block)-eccLen]...)
+	}
+
+	// Byte mode segment, terminator and padding
+	reader := &bitReader{data: data}
+	if mode := reader.read(4); mode != 0x4 {
+		return nil, 0, 0, fmt.Errorf("mode %04b, want byte mode", mode)
+	}
+	countBits := 8
+	if version >= 10 {
+		countBits = 16
+	}
+	payload := make([]byte, reader.read(countBits))
+	for i := range payload {
+		payload[i] = byte(reader.read(8))
+	}
+	terminator := min(4, reader.remaining())
+	if reader.read(terminator) != 0 || reader.read(reader.remaining()%8) != 0 {
+		return nil, 0, 0, errors.New("terminator or bit padding is not zero")
+	}
+	for pad := 0xEC; reader.remaining() > 0; pad ^= 0xEC ^ 0x11 {
+		if got := reader.read(8); got != pad {
+			return nil, 0, 0, fmt.Errorf("pad codeword %#x, want %#x", got, pad)
+		}
+	}
+	return payload, level, mask, nil
+}
+
+func b2i(set bool) int {
+	if set {
+		return 1
+	}
+	return 0
+}
+
+// bchRemainder divides word by the generator polynomial
+func bchRemainder(word int, generator int) int {
+	degree := func(v int) int {
+		d := -1
+		for ; v > 0; v >>= 1 {
+			d++
+		}
+		return d
+	}
+	for degree(word) >= degree(generator) {
+		word ^= generator << (degree(word) - degree(generator))
+	}
+	return word
+}
+
+// gfPower returns 2^n in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1
+func gfPower(n int) byte {
+	result := 1
+	for i := 0; i < n; i++ {
+		result <<= 1
+		if result&0x100 != 0 {
+			result ^= 0x11D
+		}
+	}
+	return byte(result)
+}
+
+// syndrome evaluates the block, highest term first, at x; it is zero for every root of the
+// generator polynomial when the block is intact
+func syndrome(block []byte, x byte) byte {
+	var result byte
+	for _, c := range block {
+		var product byte
+		for a, b := result, x; b != 0; b >>= 1 {
+			if b&1 != 0 {
+				product ^= a
+			}
+			carry := a&0x80 != 0
+			a <<= 1
+			if carry {
+				a ^= 0x1D
+			}
+		}
+		result = product ^ c
+	}
+	return result
+}
+
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) remaining() int {
+	return len(r.data)*8 - r.pos
+}
+
+func (r *bitReader) read(n int) int {
+	value := 0
+	for i := 0; i < n; i++ {
+		value = value<<1 | int(r.data[r.pos>>3]>>uint(7-r.pos&7)&1)
+		r.pos++
+	}
+	return value
+}
+
+func TestDecodeGolden(t *testing.T) {
+	for _, tt := range goldenSymbols {
+		level, _ := ParseLevel(tt.level)
+		code, err := Encode(goldenData(tt.length), level)
+		if err != nil {
+			t.Fatalf("%s %d bytes: %v", tt.level, tt.length, err)
+		}
+		data, gotLevel, mask, err := decode(code)
+		if err != nil {
+			t.Errorf("%s %d bytes: decode: %v", tt.level, tt.length, err)
+			continue
+		}
+		if !bytes.Equal(data, goldenData(tt.length)) || gotLevel != level || mask != tt.mask {
+			t.Errorf("%s %d bytes: decoded level %d mask %d, want level %d mask %d", tt.level, tt.length, gotLevel, mask, level, tt.mask)
+		}
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	for level := LevelL; level <= LevelH; level++ {
+		for version := 1; version <= 40; version++ {
+			// The longest payload that fits, so each version is chosen once, plus a few short ones
+			longest := (numDataCodewords(version, level)*8 - 4 - charCountBits(version)) / 8
+			lengths := []int{longest}
+			if version == 1 {
+				lengths = []int{0, 1, 2, longest}
+			}
+
+			for _, length := range lengths {
+				data := make([]byte, length)
+				for i := range data {
+					data[i] = byte(i*31 + version)
+				}
+
+				code, err := Encode(data, level)
+				if err != nil {
+					t.Fatalf("level %d version %d: %v", level, version, err)
+				}
+				if code.Version != version || code.Size != version*4+17 {
+					t.Fatalf("level %d, %d bytes: version %d size %d, want version %d", level, length, code.Version, code.Size, version)
+				}
+
+				got, gotLevel, _, err := decode(code)
+				if err != nil {
+					t.Fatalf("level %d version %d: decode: %v", level, version, err)
+				}
+				if gotLevel != level || !bytes.Equal(got, data) {
+					t.Fatalf("level %d version %d: decoded level %d and %d bytes, want the %d bytes encoded", level, version, gotLevel, len(got), length)
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeDetectsDamage(t *testing.T) {
+	code, err := Encode([]byte("https://example.com/"), LevelM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The bottom right module is always the first bit of the first codeword
+	code.Modules[code.Size-1][code.Size-1] = !code.Modules[code.Size-1][code.Size-1]
+	if _, _, _, err := decode(code); err == nil {
+		t.Error("decode accepted a damaged symbol")
+	}
+}
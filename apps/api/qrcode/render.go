@@ -0,0 +1,184 @@
+package qrcode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strconv"
+	"strings"
+)
+
+// Options control how a code is rendered
+type Options struct {
+	// Size is the width and height of the image in pixels
+	Size int
+	// Margin is the quiet zone around the symbol, in modules. The specification asks for 4.
+	Margin     int
+	Foreground color.NRGBA
+	Background color.NRGBA
+	// Logo is drawn over the centre of the symbol. Use a high error correction level with it.
+	Logo *Logo
+}
+
+// Logo is an image placed in the centre of the code
+type Logo struct {
+	Image       image.Image
+	Data        []byte
+	ContentType string
+}
+
+// logoFraction is the share of the symbol width a logo may cover; with level H the code still scans
+const logoFraction = 0.22
+
+// ParseColor parses a hex color such as "#1a1a1a", "1a1a1a" or "1a1a1a80" with alpha
+func ParseColor(value string) (color.NRGBA, error) {
+	value = strings.TrimPrefix(value, "#")
+	if len(value) != 6 && len(value) != 8 {
+		return color.NRGBA{}, errors.New("colors must be 6 or 8 hex digits")
+	}
+	n, err := strconv.ParseUint(value, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, errors.New("colors must be 6 or 8 hex digits")
+	}
+	if len(value) == 6 {
+		n = n<<8 | 0xFF
+	}
+	return color.NRGBA{R: uint8(n >> 24), G: uint8(n >> 16), B: uint8(n >> 8), A: uint8(n)}, nil
+}
+
+// layout returns the module size in pixels and the offset of the quiet zone's top left corner
+func layout(code *Code, opts Options) (size int, scale int, offset int) {
+	modules := code.Size + opts.Margin*2
+	size = opts.Size
+	if size < modules {
+		size = modules
+	}
+	scale = size / modules
+	offset = (size - scale*modules) / 2
+	return size, scale, offset
+}
+
+// PNG renders the code as a PNG image
+func PNG(code *Code, opts Options) ([]byte, error) {
+	size, scale, offset := layout(code, opts)
+
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(img, img.Bounds(), &image.Uniform{opts.Background}, image.Point{}, draw.Src)
+
+	fg := &image.Uniform{opts.Foreground}
+	origin := offset + opts.Margin*scale
+	for y, row := range code.Modules {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			rect := image.Rect(origin+x*scale, origin+y*scale, origin+(x+1)*scale, origin+(y+1)*scale)
+			draw.Draw(img, rect, fg, image.Point{}, draw.Src)
+		}
+	}
+
+	if opts.Logo != nil && opts.Logo.Image != nil {
+		symbol := code.Size * scale
+		logoSize := int(float64(symbol) * logoFraction)
+		pad := scale
+		x0 := origin + (symbol-logoSize)/2
+		backdrop := image.Rect(x0-pad, x0-pad, x0+logoSize+pad, x0+logoSize+pad)
+		draw.Draw(img, backdrop, &image.Uniform{opts.Background}, image.Point{}, draw.Src)
+		draw.Draw(img, image.Rect(x0, x0, x0+logoSize, x0+logoSize),
+			scaleImage(opts.Logo.Image, logoSize), image.Point{}, draw.Over)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SVG renders the code as an SVG document. Dark modules are merged into one path per row run.
+func SVG(code *Code, opts Options) []byte {
+	modules := code.Size + opts.Margin*2
+	size := opts.Size
+	if size <= 0 {
+		size = modules
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		size, size, modules, modules)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="%s"%s/>`,
+		modules, modules, svgColor(opts.Background), svgOpacity(opts.Background))
+
+	buf.WriteString(`<path d="`)
+	for y, row := range code.Modules {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			run := 1
+			for x+run < len(row) && row[x+run] {
+				run++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", x+opts.Margin, y+opts.Margin, run, run)
+			x += run - 1
+		}
+	}
+	fmt.Fprintf(&buf, `" fill="%s"%s/>`, svgColor(opts.Foreground), svgOpacity(opts.Foreground))
+
+	if opts.Logo != nil && len(opts.Logo.Data) > 0 {
+		logoSize := float64(code.Size) * logoFraction
+		x0 := float64(opts.Margin) + (float64(code.Size)-logoSize)/2
+		fmt.Fprintf(&buf, `<rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="%s"/>`,
+			x0-1, x0-1, logoSize+2, logoSize+2, svgColor(opts.Background))
+		fmt.Fprintf(&buf, `<image x="%.2f" y="%.2f" width="%.2f" height="%.2f" href="data:%s;base64,%s"/>`,
+			x0, x0, logoSize, logoSize, opts.Logo.ContentType, base64.StdEncoding.EncodeToString(opts.Logo.Data))
+	}
+
+	buf.WriteString(`</svg>`)
+	return buf.Bytes()
+}
+
+// svgColor formats a color as #rrggbb
+func svgColor(c color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// svgOpacity returns a fill-opacity attribute for translucent colors
+func svgOpacity(c color.NRGBA) string {
+	if c.A == 0xFF {
+		return ""
+	}
+	return fmt.Sprintf(` fill-opacity="%.3f"`, float64(c.A)/255)
+}
+
+// scaleImage resizes src to a size x size square with nearest-neighbour sampling,
+// keeping its aspect ratio and centring it
+func scaleImage(src image.Image, size int) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w == 0 || h == 0 {
+		return dst
+	}
+
+	// Fit the longer side
+	dw, dh := size, size
+	if w > h {
+		dh = size * h / w
+	} else if h > w {
+		dw = size * w / h
+	}
+	ox, oy := (size-dw)/2, (size-dh)/2
+
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			dst.Set(ox+x, oy+y, src.At(bounds.Min.X+x*w/dw, bounds.Min.Y+y*h/dh))
+		}
+	}
+	return dst
+}
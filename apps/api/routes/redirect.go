@@ -0,0 +1,47 @@
+package routes
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"url-shortener-api/handlers"
+	"url-shortener-api/views"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupRedirectRoutes configures an engine that only serves short link redirects,
+// for use on a dedicated short domain or a custom domain
+func SetupRedirectRoutes(r *gin.Engine) {
+	r.SetHTMLTemplate(views.Templates())
+
+	r.GET("/health", handlers.Health)
+	r.GET("/ready", handlers.Ready)
+
+	r.GET("/", handlers.DomainRoot)
+
+	r.GET("/:slug", handlers.RedirectLink)
+	r.POST("/:slug", handlers.RedirectLink)
+	r.GET("/:slug/*path", handlers.RedirectLink)
+	r.POST("/:slug/*path", handlers.RedirectLink)
+
+	r.NoRoute(handlers.NotFound)
+}
+
+// HostRouter sends requests for shortDomain and verified custom domains to the redirect handler
+// and everything else to the API. shortDomain may be empty when no separate short domain is configured.
+func HostRouter(shortDomain string, isCustomDomain func(host string) bool, redirects http.Handler, api http.Handler) http.Handler {
+	shortDomain = strings.ToLower(shortDomain)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		host := req.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+
+		if (shortDomain != "" && strings.EqualFold(host, shortDomain)) || isCustomDomain(host) {
+			redirects.ServeHTTP(w, req)
+			return
+		}
+		api.ServeHTTP(w, req)
+	})
+}
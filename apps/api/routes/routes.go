@@ -3,11 +3,15 @@ package routes
 import (
 	"url-shortener-api/handlers"
 	"url-shortener-api/middleware"
+	"url-shortener-api/views"
 
 	"github.com/gin-gonic/gin"
 )
 
 func SetupRoutes(r *gin.Engine) {
+	// Embedded HTML templates for browser-facing pages
+	r.SetHTMLTemplate(views.Templates())
+
 	// Apply CORS middleware
 	r.Use(middleware.CORS())
 
@@ -18,6 +22,7 @@ func SetupRoutes(r *gin.Engine) {
 
 	// API routes
 	api := r.Group("/api")
+	api.Use(handlers.AuditImpersonation())
 	{
 		// Authentication endpoints
 		api.POST("/auth/register", handlers.Register)
@@ -30,12 +35,87 @@ func SetupRoutes(r *gin.Engine) {
 		api.GET("/links", middleware.OptionalJWTAuth(), handlers.GetLinks)
 		api.PATCH("/links/:id", middleware.OptionalJWTAuth(), handlers.UpdateLink)
 		api.DELETE("/links/:id", middleware.OptionalJWTAuth(), handlers.DeleteLink)
+		api.GET("/links/:id/rules", middleware.OptionalJWTAuth(), handlers.GetLinkRules)
+		api.PUT("/links/:id/rules", middleware.OptionalJWTAuth(), handlers.SetLinkRules)
+		api.GET("/links/:id/variants", middleware.OptionalJWTAuth(), handlers.GetLinkVariants)
+		api.PUT("/links/:id/variants", middleware.OptionalJWTAuth(), handlers.SetLinkVariants)
+		api.GET("/links/:id/stats", middleware.OptionalJWTAuth(), handlers.GetLinkStats)
+		api.GET("/links/:id/qr", middleware.OptionalJWTAuth(), handlers.GetLinkQRCode)
+		api.GET("/links/:id/health", middleware.OptionalJWTAuth(), handlers.GetLinkHealth)
+		// PUT rather than POST: POST /links/:slug/access already claims that wildcard
+		api.PUT("/links/:id/metadata", middleware.OptionalJWTAuth(), handlers.RefreshLinkMetadata)
 		api.POST("/links/:slug/access", handlers.CheckLinkAccess)
+		api.GET("/favicons/:domain", handlers.GetFavicon)
+		api.POST("/links/claim", middleware.JWTAuth(), handlers.ClaimLinks)
+		api.GET("/links/challenge", handlers.GetLinkChallenge)
+
+		// Workspace endpoints
+		api.GET("/workspaces", middleware.JWTAuth(), handlers.GetWorkspaces)
+		api.POST("/workspaces", middleware.JWTAuth(), handlers.CreateWorkspace)
+		api.DELETE("/workspaces/:id", middleware.JWTAuth(), handlers.DeleteWorkspace)
+		api.GET("/workspaces/:id/members", middleware.JWTAuth(), handlers.GetWorkspaceMembers)
+		api.PATCH("/workspaces/:id/members/:userId", middleware.JWTAuth(), handlers.UpdateWorkspaceMember)
+		api.DELETE("/workspaces/:id/members/:userId", middleware.JWTAuth(), handlers.RemoveWorkspaceMember)
+		api.POST("/workspaces/:id/invitations", middleware.JWTAuth(), handlers.InviteWorkspaceMember)
+		api.POST("/invitations/:token/accept", middleware.JWTAuth(), handlers.AcceptWorkspaceInvitation)
+
+		// Custom domain endpoints
+		api.GET("/domains", middleware.JWTAuth(), handlers.GetDomains)
+		api.POST("/domains", middleware.JWTAuth(), handlers.CreateDomain)
+		api.POST("/domains/:id/verify", middleware.JWTAuth(), handlers.VerifyDomain)
+		api.PATCH("/domains/:id", middleware.JWTAuth(), handlers.UpdateDomain)
+		api.DELETE("/domains/:id", middleware.JWTAuth(), handlers.DeleteDomain)
+
+		// UTM template endpoints
+		api.GET("/utm-templates", middleware.JWTAuth(), handlers.GetUTMTemplates)
+		api.POST("/utm-templates", middleware.JWTAuth(), handlers.CreateUTMTemplate)
+		api.PATCH("/utm-templates/:id", middleware.JWTAuth(), handlers.UpdateUTMTemplate)
+		api.DELETE("/utm-templates/:id", middleware.JWTAuth(), handlers.DeleteUTMTemplate)
+
+		// Webhook endpoints
+		api.POST("/webhooks", middleware.JWTAuth(), handlers.CreateWebhook)
+		api.GET("/webhooks", middleware.JWTAuth(), handlers.GetWebhooks)
+		api.PATCH("/webhooks/:id", middleware.JWTAuth(), handlers.UpdateWebhook)
+		api.DELETE("/webhooks/:id", middleware.JWTAuth(), handlers.DeleteWebhook)
+		api.POST("/webhooks/:id/test", middleware.JWTAuth(), handlers.TestWebhook)
+		api.GET("/webhooks/:id/deliveries", middleware.JWTAuth(), handlers.GetWebhookDeliveries)
+		api.POST("/webhooks/:id/deliveries/:deliveryId/replay", middleware.JWTAuth(), handlers.ReplayWebhookDelivery)
 
 		// Dashboard endpoints
 		api.GET("/dashboard/stats", middleware.JWTAuth(), handlers.GetDashboardStats)
+		api.POST("/stream/token", middleware.JWTAuth(), handlers.CreateStreamToken)
+		api.GET("/stream/clicks", middleware.StreamAuth(), handlers.StreamClicks)
+		api.GET("/reports/settings", middleware.JWTAuth(), handlers.GetReportSettings)
+		api.PUT("/reports/settings", middleware.JWTAuth(), handlers.UpdateReportSettings)
+		api.GET("/reports/preview", middleware.JWTAuth(), handlers.PreviewReport)
+
+		// Admin endpoints
+		admin := api.Group("/admin", middleware.JWTAuth(), middleware.AdminAuth())
+		{
+			admin.GET("/users", handlers.AdminListUsers)
+			admin.POST("/users/:id/suspend", handlers.AdminSuspendUser)
+			admin.POST("/users/:id/unsuspend", handlers.AdminUnsuspendUser)
+			admin.POST("/users/:id/impersonate", handlers.AdminImpersonateUser)
+			admin.GET("/links", handlers.AdminListLinks)
+			admin.PATCH("/links/:id", handlers.AdminUpdateLink)
+			admin.GET("/stats", handlers.AdminGetStats)
+			admin.GET("/audit-log", handlers.AdminGetAuditLog)
+			admin.GET("/blocklist", handlers.AdminListBlocklist)
+			admin.POST("/blocklist", handlers.AdminCreateBlocklistEntry)
+			admin.DELETE("/blocklist/:id", handlers.AdminDeleteBlocklistEntry)
+			admin.POST("/screening/recheck", handlers.AdminRecheckLinks)
+			admin.POST("/health/check", handlers.AdminCheckLinkHealth)
+		}
 
-		// Redirect route
+		// Legacy redirect route, kept so /api/:slug links keep working
 		api.GET("/:slug", handlers.RedirectLink)
+		api.POST("/:slug", handlers.RedirectLink)
 	}
+
+	// Root-level redirect route. POST accepts the password form from protected link pages.
+	// Reserved paths such as /api and /health are never treated as slugs.
+	r.GET("/:slug", handlers.RedirectLink)
+	r.POST("/:slug", handlers.RedirectLink)
+	r.GET("/:slug/*path", handlers.RedirectLink)
+	r.POST("/:slug/*path", handlers.RedirectLink)
 }
@@ -0,0 +1,134 @@
+package screening
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"url-shortener-api/db"
+	"url-shortener-api/models"
+)
+
+// blocklistRefresh is how often each replica reloads the blocklist from the database
+const blocklistRefresh = time.Minute
+
+// blocklistRetry is how long a replica waits to reload again after a failed reload
+const blocklistRetry = 10 * time.Second
+
+// BlocklistChecker blocks destinations matching admin-managed domain or regex entries.
+// Entries are cached in memory and reloaded periodically so every replica picks up changes.
+type BlocklistChecker struct {
+	mu          sync.RWMutex
+	domains     map[string]string
+	patterns    []blockPattern
+	loadedAt    time.Time
+	attemptedAt time.Time
+}
+
+type blockPattern struct {
+	re     *regexp.Regexp
+	reason string
+}
+
+// Blocklist is the shared blocklist checker
+var Blocklist = &BlocklistChecker{}
+
+func (b *BlocklistChecker) Name() string {
+	return "blocklist"
+}
+
+func (b *BlocklistChecker) Check(ctx context.Context, u *url.URL) (Verdict, error) {
+	if b.reloadDue() {
+		if err := b.Reload(ctx); err != nil {
+			// Keep screening against the last good list rather than letting everything through
+			if b.lastLoaded().IsZero() {
+				return Allow, err
+			}
+			log.Printf("Error reloading blocklist, using entries from %s: %v", b.lastLoaded().Format(time.RFC3339), err)
+		}
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	// Match the host and each parent domain
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	for candidate := host; candidate != ""; {
+		if reason, ok := b.domains[candidate]; ok {
+			return Verdict{Action: ActionBlock, Reason: reason}, nil
+		}
+		_, rest, found := strings.Cut(candidate, ".")
+		if !found {
+			break
+		}
+		candidate = rest
+	}
+
+	full := u.String()
+	for _, p := range b.patterns {
+		if p.re.MatchString(full) {
+			return Verdict{Action: ActionBlock, Reason: p.reason}, nil
+		}
+	}
+
+	return Allow, nil
+}
+
+// Reload replaces the cached entries with the current database contents. If the entries can't
+// be loaded the last good list stays in place and the error is returned.
+func (b *BlocklistChecker) Reload(ctx context.Context) error {
+	b.mu.Lock()
+	b.attemptedAt = time.Now()
+	b.mu.Unlock()
+
+	var entries []models.BlocklistEntry
+	if err := db.DB.SelectContext(ctx, &entries, "SELECT * FROM blocklist_entries"); err != nil {
+		return fmt.Errorf("loading blocklist entries: %w", err)
+	}
+
+	domains := make(map[string]string)
+	patterns := make([]blockPattern, 0)
+	for _, entry := range entries {
+		reason := "destination is blocklisted"
+		if entry.Reason != nil && *entry.Reason != "" {
+			reason = *entry.Reason
+		}
+
+		switch entry.Kind {
+		case "domain":
+			domains[strings.ToLower(entry.Pattern)] = reason
+		case "regex":
+			re, err := regexp.Compile(entry.Pattern)
+			if err != nil {
+				log.Printf("Skipping invalid blocklist regex %q: %v", entry.Pattern, err)
+				continue
+			}
+			patterns = append(patterns, blockPattern{re: re, reason: reason})
+		}
+	}
+
+	b.mu.Lock()
+	b.domains = domains
+	b.patterns = patterns
+	b.loadedAt = time.Now()
+	b.mu.Unlock()
+
+	return nil
+}
+
+// reloadDue reports whether the list is stale, waiting blocklistRetry between failed attempts
+func (b *BlocklistChecker) reloadDue() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return time.Since(b.loadedAt) > blocklistRefresh && time.Since(b.attemptedAt) > blocklistRetry
+}
+
+func (b *BlocklistChecker) lastLoaded() time.Time {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.loadedAt
+}
@@ -0,0 +1,111 @@
+package screening
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Resolver looks up the addresses of a host. net.DefaultResolver satisfies it.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// NetworkChecker blocks destinations that are not public web hosts: non-HTTP schemes,
+// IP literals, localhost, and hostnames resolving to private or loopback addresses
+type NetworkChecker struct {
+	Resolver Resolver
+}
+
+// NewNetworkChecker creates a network checker. A nil resolver uses the system resolver.
+func NewNetworkChecker(resolver Resolver) *NetworkChecker {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	return &NetworkChecker{Resolver: resolver}
+}
+
+func (n *NetworkChecker) Name() string {
+	return "network"
+}
+
+func (n *NetworkChecker) Check(ctx context.Context, u *url.URL) (Verdict, error) {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return Verdict{Action: ActionBlock, Reason: "only http and https destinations are allowed"}, nil
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return Verdict{Action: ActionBlock, Reason: "missing host"}, nil
+	}
+
+	if net.ParseIP(host) != nil {
+		return Verdict{Action: ActionBlock, Reason: "IP address destinations are not allowed"}, nil
+	}
+
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") ||
+		strings.HasSuffix(host, ".local") || strings.HasSuffix(host, ".internal") {
+		return Verdict{Action: ActionBlock, Reason: "local destinations are not allowed"}, nil
+	}
+
+	// Catch public names that point at internal addresses. Lookup failures are not
+	// treated as blocks since the domain may simply not resolve yet.
+	addrs, err := n.Resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return Allow, nil
+	}
+	for _, addr := range addrs {
+		if !IsPublicIP(addr.IP) {
+			return Verdict{Action: ActionBlock, Reason: "destination resolves to a private address"}, nil
+		}
+	}
+
+	return Allow, nil
+}
+
+// IsPublicIP reports whether ip is a globally routable unicast address
+func IsPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+
+	// Carrier-grade NAT range, which IsPrivate does not cover
+	if ip4 := ip.To4(); ip4 != nil && ip4[0] == 100 && ip4[1]&0xc0 == 64 {
+		return false
+	}
+
+	return true
+}
+
+// PublicClient returns an HTTP client for fetching user-supplied URLs. It refuses to connect
+// to addresses that are not public, checked after DNS resolution so rebinding is caught too.
+func PublicClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !IsPublicIP(ip) {
+				return errors.New("refusing to connect to non-public address " + host)
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+		},
+	}
+}
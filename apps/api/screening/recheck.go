@@ -0,0 +1,215 @@
+package screening
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/url"
+	"time"
+	"url-shortener-api/db"
+	"url-shortener-api/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// recheckBatchSize is how many links are screened per database page
+const recheckBatchSize = 500
+
+// recheckLockKey is the Postgres advisory lock held during a recheck, so replicas take turns
+const recheckLockKey int64 = 0x73637265656e // "screen"
+
+// ErrRecheckInProgress is returned by RecheckLinks when another replica is rechecking links
+var ErrRecheckInProgress = errors.New("a screening recheck is already in progress")
+
+// recheckRow is a link and every destination it can send visitors to
+type recheckRow struct {
+	ID                  uuid.UUID      `db:"id"`
+	Original            string         `db:"original"`
+	IOSAppURL           *string        `db:"ios_app_url"`
+	IOSStoreURL         *string        `db:"ios_store_url"`
+	AndroidAppURL       *string        `db:"android_app_url"`
+	AndroidStoreURL     *string        `db:"android_store_url"`
+	RuleDestinations    pq.StringArray `db:"rule_destinations"`
+	VariantDestinations pq.StringArray `db:"variant_destinations"`
+}
+
+// destinations returns the URLs a link redirects to: its original URL, the destinations of
+// its targeting rules and A/B variants, and its http(s) deep link targets. Custom app
+// schemes are left out since they never reach a web page.
+func (r recheckRow) destinations() []string {
+	destinations := []string{r.Original}
+	destinations = append(destinations, r.RuleDestinations...)
+	destinations = append(destinations, r.VariantDestinations...)
+	for _, target := range []*string{r.IOSAppURL, r.IOSStoreURL, r.AndroidAppURL, r.AndroidStoreURL} {
+		if target == nil {
+			continue
+		}
+		if parsed, err := url.Parse(*target); err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") {
+			destinations = append(destinations, *target)
+		}
+	}
+	return destinations
+}
+
+// screenDestinations returns the most severe verdict for a link's destinations
+func screenDestinations(ctx context.Context, destinations []string) Verdict {
+	result := Allow
+	for _, destination := range destinations {
+		verdict := Default.Screen(ctx, destination)
+		if verdict.Blocked() {
+			return verdict
+		}
+		if verdict.Flagged() && !result.Flagged() {
+			result = verdict
+		}
+	}
+	return result
+}
+
+// RecheckLinks screens every destination of every enabled link again unless another replica
+// is doing so. Links that are now blocked are blocked automatically, so only an admin can
+// enable them again, and the action is recorded in the audit log.
+func RecheckLinks(ctx context.Context) (models.RecheckResult, error) {
+	result := models.RecheckResult{}
+	lastID := uuid.Nil
+
+	conn, err := db.DB.Conn(ctx)
+	if err != nil {
+		return result, err
+	}
+	defer conn.Close()
+
+	// Advisory locks belong to the session, so lock and unlock on the same connection
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", recheckLockKey).Scan(&locked); err != nil {
+		return result, err
+	}
+	if !locked {
+		return result, ErrRecheckInProgress
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", recheckLockKey)
+
+	if err := Blocklist.Reload(ctx); err != nil {
+		return result, err
+	}
+
+	for {
+		var rows []recheckRow
+		err := db.DB.SelectContext(ctx, &rows, `
+			SELECT
+				l.id, l.original, l.ios_app_url, l.ios_store_url, l.android_app_url, l.android_store_url,
+				ARRAY(SELECT destination FROM link_rules WHERE link_id = l.id) AS rule_destinations,
+				ARRAY(SELECT destination FROM link_variants WHERE link_id = l.id) AS variant_destinations
+			FROM links l
+			WHERE NOT l.disabled AND l.blocked_at IS NULL AND l.id > $1
+			ORDER BY l.id
+			LIMIT $2
+		`, lastID, recheckBatchSize)
+		if err != nil {
+			return result, err
+		}
+		if len(rows) == 0 {
+			return result, nil
+		}
+
+		for _, row := range rows {
+			lastID = row.ID
+			result.Checked++
+
+			verdict := screenDestinations(ctx, row.destinations())
+			if err := applyVerdict(ctx, row.ID, verdict); err != nil {
+				log.Printf("Error applying screening verdict to link %s: %v", row.ID, err)
+				continue
+			}
+
+			if verdict.Blocked() {
+				result.Disabled++
+			} else if verdict.Flagged() {
+				result.Flagged++
+			}
+		}
+	}
+}
+
+// applyVerdict stores a screening verdict on a link, blocking it when blocked
+func applyVerdict(ctx context.Context, linkID uuid.UUID, verdict Verdict) error {
+	// Links that pass have any earlier flag cleared
+	var reason *string
+	if verdict.Blocked() || verdict.Flagged() {
+		reason = &verdict.Reason
+	}
+
+	if !verdict.Blocked() {
+		_, err := db.DB.ExecContext(ctx,
+			"UPDATE links SET flagged_reason = $1, screened_at = $2 WHERE id = $3",
+			reason, time.Now(), linkID)
+		return err
+	}
+
+	details := verdict.Checker + ": " + verdict.Reason
+	_, err := db.DB.ExecContext(ctx, `
+		UPDATE links
+		SET disabled = TRUE, blocked_at = $3, blocked_reason = $2, flagged_reason = $1, screened_at = $3, last_updated = $3
+		WHERE id = $4
+	`, reason, details, time.Now(), linkID)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.DB.ExecContext(ctx, `
+		INSERT INTO audit_log (action, target, success, details)
+		VALUES ('screening.disable', $1, TRUE, $2)
+	`, linkID.String(), details)
+	return err
+}
+
+// requested wakes the recheck loop for a run outside the schedule
+var requested = make(chan struct{}, 1)
+
+// RequestRecheck asks the recheck loop to run as soon as possible. It reports false if a
+// recheck is already waiting.
+func RequestRecheck() bool {
+	select {
+	case requested <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// StartRecheckLoop rechecks all links every interval, and whenever RequestRecheck is called,
+// until ctx is cancelled. An interval of 0 only runs on request.
+func StartRecheckLoop(ctx context.Context, interval time.Duration) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		tick = ticker.C
+		go func() {
+			<-ctx.Done()
+			ticker.Stop()
+		}()
+	}
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick:
+			case <-requested:
+			}
+
+			result, err := RecheckLinks(ctx)
+			if errors.Is(err, ErrRecheckInProgress) {
+				continue
+			}
+			if err != nil {
+				log.Printf("Link screening recheck failed: %v", err)
+				continue
+			}
+			log.Printf("Link screening recheck: %d checked, %d flagged, %d disabled",
+				result.Checked, result.Flagged, result.Disabled)
+		}
+	}()
+}
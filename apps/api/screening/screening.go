@@ -0,0 +1,127 @@
+package screening
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+)
+
+// Verdict actions, from least to most severe
+const (
+	ActionAllow = "allow"
+	ActionFlag  = "flag"
+	ActionBlock = "block"
+)
+
+// checkTimeout bounds the time spent screening a single URL across all checkers
+const checkTimeout = 5 * time.Second
+
+var actionSeverity = map[string]int{
+	ActionAllow: 0,
+	ActionFlag:  1,
+	ActionBlock: 2,
+}
+
+// Verdict is the outcome of screening a destination URL
+type Verdict struct {
+	Action  string `json:"action"`
+	Reason  string `json:"reason,omitempty"`
+	Checker string `json:"checker,omitempty"`
+}
+
+// Blocked reports whether the URL must be rejected
+func (v Verdict) Blocked() bool {
+	return v.Action == ActionBlock
+}
+
+// Flagged reports whether the URL is allowed but suspicious
+func (v Verdict) Flagged() bool {
+	return v.Action == ActionFlag
+}
+
+// Allow is the verdict for URLs that passed every check
+var Allow = Verdict{Action: ActionAllow}
+
+// Checker inspects a destination URL. Checkers return Allow when they have nothing to report.
+type Checker interface {
+	Name() string
+	Check(ctx context.Context, u *url.URL) (Verdict, error)
+}
+
+// Pipeline runs checkers in order and returns the most severe verdict.
+// A checker that errors is logged and skipped so an unavailable external
+// service cannot block all link creation.
+type Pipeline struct {
+	Checkers []Checker
+}
+
+// Default is the pipeline used by the API, configured by Load
+var Default = &Pipeline{}
+
+// Screen runs every checker against rawURL, stopping early on a block
+func (p *Pipeline) Screen(ctx context.Context, rawURL string) Verdict {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return Verdict{Action: ActionBlock, Reason: "invalid URL", Checker: "parser"}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	result := Allow
+	for _, checker := range p.Checkers {
+		verdict, err := checker.Check(ctx, u)
+		if err != nil {
+			log.Printf("URL screening checker %s failed: %v", checker.Name(), err)
+			continue
+		}
+
+		if actionSeverity[verdict.Action] > actionSeverity[result.Action] {
+			verdict.Checker = checker.Name()
+			result = verdict
+		}
+		if result.Blocked() {
+			break
+		}
+	}
+
+	return result
+}
+
+// Load assembles the default pipeline. External reputation services can be
+// appended with Register.
+func Load() {
+	Default = &Pipeline{
+		Checkers: []Checker{
+			NewNetworkChecker(nil),
+			Blocklist,
+		},
+	}
+}
+
+// Register appends a checker to the default pipeline
+func Register(checker Checker) {
+	Default.Checkers = append(Default.Checkers, checker)
+}
+
+// ReputationService is implemented by external URL reputation providers
+// such as Google Safe Browsing or PhishTank
+type ReputationService interface {
+	Lookup(ctx context.Context, rawURL string) (Verdict, error)
+}
+
+// ReputationChecker adapts a ReputationService to the Checker interface
+type ReputationChecker struct {
+	Provider string
+	Service  ReputationService
+}
+
+func (r ReputationChecker) Name() string {
+	return fmt.Sprintf("reputation:%s", r.Provider)
+}
+
+func (r ReputationChecker) Check(ctx context.Context, u *url.URL) (Verdict, error) {
+	return r.Service.Lookup(ctx, u.String())
+}
@@ -0,0 +1,145 @@
+package stream
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+	"url-shortener-api/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Channel is the Postgres NOTIFY channel clicks are published on. Every replica listens on it,
+// so a click recorded by one replica reaches dashboards connected to any of them.
+const Channel = "click_events"
+
+// subscriptionBuffer is how many events a slow client may fall behind before events are dropped
+const subscriptionBuffer = 64
+
+// Subscription receives the click events of a set of workspaces, optionally narrowed to some links
+type Subscription struct {
+	C chan models.ClickStreamEvent
+
+	mu         sync.RWMutex
+	workspaces map[uuid.UUID]bool
+	links      map[uuid.UUID]bool
+}
+
+// SetWorkspaces replaces the workspaces whose clicks the subscription receives
+func (s *Subscription) SetWorkspaces(ids []uuid.UUID) {
+	workspaces := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		workspaces[id] = true
+	}
+	s.mu.Lock()
+	s.workspaces = workspaces
+	s.mu.Unlock()
+}
+
+func (s *Subscription) wants(event *models.ClickStreamEvent) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if event.WorkspaceID == nil || !s.workspaces[*event.WorkspaceID] {
+		return false
+	}
+	return len(s.links) == 0 || s.links[event.LinkID]
+}
+
+var (
+	subscribersMu sync.RWMutex
+	subscribers   = map[*Subscription]bool{}
+)
+
+// Subscribe starts receiving click events of the given workspaces. A non-empty linkIDs only
+// passes clicks on those links. Close the subscription when done.
+func Subscribe(workspaceIDs []uuid.UUID, linkIDs []uuid.UUID) *Subscription {
+	sub := &Subscription{
+		C:     make(chan models.ClickStreamEvent, subscriptionBuffer),
+		links: map[uuid.UUID]bool{},
+	}
+	sub.SetWorkspaces(workspaceIDs)
+	for _, id := range linkIDs {
+		sub.links[id] = true
+	}
+
+	subscribersMu.Lock()
+	subscribers[sub] = true
+	subscribersMu.Unlock()
+	return sub
+}
+
+// Close stops the subscription
+func (s *Subscription) Close() {
+	subscribersMu.Lock()
+	delete(subscribers, s)
+	subscribersMu.Unlock()
+}
+
+// EncodeClick returns the NOTIFY payload for a click. Clicks are published with
+// pg_notify(Channel, payload) in the statement that records them.
+func EncodeClick(event models.ClickStreamEvent) (string, error) {
+	payload, err := json.Marshal(event)
+	return string(payload), err
+}
+
+// Start listens for published clicks on a dedicated connection and hands them to subscribers.
+// The listener reconnects by itself; clicks published while it is disconnected are missed.
+func Start(dbURL string) {
+	listener := pq.NewListener(dbURL, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Click stream listener: %v", err)
+		}
+	})
+	if err := listener.Listen(Channel); err != nil {
+		log.Printf("Error listening for click events: %v", err)
+	}
+	for _, channel := range listenChannels() {
+		if err := listener.Listen(channel); err != nil {
+			log.Printf("Error listening for %s notifications: %v", channel, err)
+		}
+	}
+
+	go func() {
+		for {
+			select {
+			case notification := <-listener.Notify:
+				// nil is sent after a reconnect
+				switch {
+				case notification == nil:
+					notify("", "")
+				case notification.Channel == Channel:
+					dispatch(notification.Extra)
+				default:
+					notify(notification.Channel, notification.Extra)
+				}
+			case <-time.After(90 * time.Second):
+				// Check the connection when it has been quiet for a while
+				go listener.Ping()
+			}
+		}
+	}()
+}
+
+// dispatch passes a published click to the subscriptions that want it, dropping it for
+// clients that are too far behind rather than blocking everyone else
+func dispatch(payload string) {
+	var event models.ClickStreamEvent
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		log.Printf("Error decoding click event notification: %v", err)
+		return
+	}
+
+	subscribersMu.RLock()
+	defer subscribersMu.RUnlock()
+	for sub := range subscribers {
+		if !sub.wants(&event) {
+			continue
+		}
+		select {
+		case sub.C <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package stream
+
+import "sync"
+
+var (
+	handlersMu sync.RWMutex
+	handlers   = map[string]func(payload string){}
+)
+
+// Listen has the stream listener pass notifications on another NOTIFY channel to handle.
+// Register before Start. handle is called with an empty payload after the listener
+// reconnects, since notifications sent while it was disconnected are missed.
+func Listen(channel string, handle func(payload string)) {
+	handlersMu.Lock()
+	handlers[channel] = handle
+	handlersMu.Unlock()
+}
+
+// listenChannels returns the channels registered with Listen
+func listenChannels() []string {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	channels := make([]string, 0, len(handlers))
+	for channel := range handlers {
+		channels = append(channels, channel)
+	}
+	return channels
+}
+
+// notify passes a notification to the handler of its channel; an empty channel
+// tells every handler that notifications may have been missed
+func notify(channel, payload string) {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	for name, handle := range handlers {
+		if channel == "" || channel == name {
+			handle(payload)
+		}
+	}
+}
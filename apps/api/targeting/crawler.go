@@ -0,0 +1,51 @@
+package targeting
+
+import "strings"
+
+// crawlerAgents are user agent fragments of the bots that fetch links to build chat and social previews
+var crawlerAgents = []string{
+	"facebookexternalhit",
+	"facebookcatalog",
+	"facebot",
+	"twitterbot",
+	"slackbot",
+	"slack-imgproxy",
+	"discordbot",
+	"linkedinbot",
+	"telegrambot",
+	"skypeuripreview",
+	"microsoftpreview",
+	"pinterestbot",
+	"redditbot",
+	"mastodon",
+	"embedly",
+	"iframely",
+	"vkshare",
+	"applebot",
+	"google-pagerenderer",
+	"bitlybot",
+	"snap url preview",
+}
+
+// crawlerPrefixes match bots only at the start of the user agent. WhatsApp's preview fetcher
+// sends a bare "WhatsApp/2.x" while its in-app browser has a full browser user agent that
+// also mentions WhatsApp, and real visitors must not get the card page.
+var crawlerPrefixes = []string{
+	"whatsapp/",
+}
+
+// IsCrawler reports whether a user agent belongs to a link preview crawler
+func IsCrawler(userAgent string) bool {
+	ua := strings.ToLower(userAgent)
+	for _, agent := range crawlerAgents {
+		if strings.Contains(ua, agent) {
+			return true
+		}
+	}
+	for _, prefix := range crawlerPrefixes {
+		if strings.HasPrefix(ua, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package targeting
+
+import "strings"
+
+// Operating systems and device types recognised in user agents
+const (
+	OSiOS     = "ios"
+	OSAndroid = "android"
+	OSWindows = "windows"
+	OSMacOS   = "macos"
+	OSLinux   = "linux"
+	OSOther   = "other"
+
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceDesktop = "desktop"
+)
+
+// knownDevices are the values a device rule may match on
+var knownDevices = map[string]bool{
+	OSiOS:         true,
+	OSAndroid:     true,
+	OSWindows:     true,
+	OSMacOS:       true,
+	OSLinux:       true,
+	OSOther:       true,
+	DeviceMobile:  true,
+	DeviceTablet:  true,
+	DeviceDesktop: true,
+}
+
+// ParseUserAgent returns the operating system and device type of a user agent
+func ParseUserAgent(userAgent string) (string, string) {
+	ua := strings.ToLower(userAgent)
+
+	switch {
+	case strings.Contains(ua, "ipad"):
+		return OSiOS, DeviceTablet
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipod"):
+		return OSiOS, DeviceMobile
+	case strings.Contains(ua, "android"):
+		// Android tablets leave "mobile" out of their user agent
+		if strings.Contains(ua, "mobile") {
+			return OSAndroid, DeviceMobile
+		}
+		return OSAndroid, DeviceTablet
+	case strings.Contains(ua, "windows"):
+		return OSWindows, DeviceDesktop
+	case strings.Contains(ua, "macintosh"), strings.Contains(ua, "mac os x"):
+		return OSMacOS, DeviceDesktop
+	case strings.Contains(ua, "linux"), strings.Contains(ua, "x11"):
+		return OSLinux, DeviceDesktop
+	}
+	return OSOther, DeviceDesktop
+}
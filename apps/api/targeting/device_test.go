@@ -0,0 +1,32 @@
+package targeting
+
+import "testing"
+
+func TestParseUserAgent(t *testing.T) {
+	tests := []struct {
+		name       string
+		userAgent  string
+		wantOS     string
+		wantDevice string
+	}{
+		{"iphone", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1", OSiOS, DeviceMobile},
+		{"ipad", "Mozilla/5.0 (iPad; CPU OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1", OSiOS, DeviceTablet},
+		{"ipod", "Mozilla/5.0 (iPod touch; CPU iPhone OS 12_5 like Mac OS X)", OSiOS, DeviceMobile},
+		{"android phone", "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0 Mobile Safari/537.36", OSAndroid, DeviceMobile},
+		{"android tablet", "Mozilla/5.0 (Linux; Android 13; SM-X200) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0 Safari/537.36", OSAndroid, DeviceTablet},
+		{"windows", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0 Safari/537.36", OSWindows, DeviceDesktop},
+		{"macos", "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_4) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Safari/605.1.15", OSMacOS, DeviceDesktop},
+		{"linux", "Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0", OSLinux, DeviceDesktop},
+		{"bot", "curl/8.5.0", OSOther, DeviceDesktop},
+		{"empty", "", OSOther, DeviceDesktop},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os, device := ParseUserAgent(tt.userAgent)
+			if os != tt.wantOS || device != tt.wantDevice {
+				t.Errorf("ParseUserAgent() = %q, %q, want %q, %q", os, device, tt.wantOS, tt.wantDevice)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package targeting
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"url-shortener-api/utils"
+)
+
+// Locator resolves the country of the client making a request
+type Locator interface {
+	Country(r *http.Request, clientIP string) string
+}
+
+// IPLocator resolves the country an IP address is registered in, e.g. from a GeoIP database
+type IPLocator interface {
+	CountryForIP(ip net.IP) string
+}
+
+// HeaderLocator reads the country from headers set by a CDN or load balancer in front of
+// the API, such as Cloudflare's CF-IPCountry. The first non-empty header wins. Clients can
+// send these headers themselves, so only use it behind a proxy that overwrites them.
+type HeaderLocator struct {
+	Headers []string
+}
+
+// Country returns the uppercase ISO 3166 country code, or an empty string if unknown
+func (l HeaderLocator) Country(r *http.Request, clientIP string) string {
+	for _, header := range l.Headers {
+		if country := normalizeCountry(r.Header.Get(header)); country != "" {
+			return country
+		}
+	}
+	return ""
+}
+
+// normalizeCountry uppercases a two-letter country code. Cloudflare uses XX for unknown and T1 for Tor.
+func normalizeCountry(value string) string {
+	country := strings.ToUpper(strings.TrimSpace(value))
+	if len(country) != 2 || country == "XX" || country == "T1" {
+		return ""
+	}
+	return country
+}
+
+// GeoLocator looks the client IP up in an IP database, preferring trusted proxy headers when set
+type GeoLocator struct {
+	// Headers are only consulted when non-nil, i.e. when GEO_TRUSTED_PROXY is on
+	Headers *HeaderLocator
+	IP      IPLocator
+}
+
+func (l GeoLocator) Country(r *http.Request, clientIP string) string {
+	if l.Headers != nil {
+		if country := l.Headers.Country(r, clientIP); country != "" {
+			return country
+		}
+	}
+	if l.IP != nil {
+		if ip := net.ParseIP(clientIP); ip != nil {
+			return l.IP.CountryForIP(ip)
+		}
+	}
+	return ""
+}
+
+// Geo is the locator used for country targeting and click analytics, configured by LoadGeo
+var Geo Locator = GeoLocator{}
+
+// LoadGeo configures country lookup: the IP database at GEOIP_DATABASE, plus the
+// GEO_COUNTRY_HEADERS of a trusted proxy when GEO_TRUSTED_PROXY is on
+func LoadGeo() {
+	locator := GeoLocator{}
+
+	if utils.AppConfig.GeoTrustedProxy {
+		headers := []string{}
+		for _, header := range strings.Split(utils.AppConfig.GeoCountryHeaders, ",") {
+			if header = strings.TrimSpace(header); header != "" {
+				headers = append(headers, header)
+			}
+		}
+		locator.Headers = &HeaderLocator{Headers: headers}
+	}
+
+	if path := utils.AppConfig.GeoIPDatabase; path != "" {
+		db, err := LoadRangeDatabase(path)
+		if err != nil {
+			log.Fatalf("Failed to load GeoIP database: %v", err)
+		}
+		log.Printf("Loaded %d GeoIP ranges from %s", len(db.ranges), path)
+		locator.IP = db
+	}
+
+	Geo = locator
+}
+
+// RegisterIPLocator replaces the IP database lookup, e.g. with a MaxMind reader
+func RegisterIPLocator(ip IPLocator) {
+	locator, _ := Geo.(GeoLocator)
+	locator.IP = ip
+	Geo = locator
+}
+
+type ipRange struct {
+	start   net.IP
+	end     net.IP
+	country string
+}
+
+// RangeDatabase maps IP ranges to countries. It reads the CSV layout of the free
+// DB-IP and IP2Location "lite" country databases: start IP, end IP, country code.
+type RangeDatabase struct {
+	// ranges are sorted by start address, as 16-byte IPs
+	ranges []ipRange
+}
+
+// LoadRangeDatabase reads a range database from a CSV file
+func LoadRangeDatabase(path string) (*RangeDatabase, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseRangeDatabase(file)
+}
+
+// ParseRangeDatabase reads "start,end,country" rows. Extra columns are ignored.
+func ParseRangeDatabase(r io.Reader) (*RangeDatabase, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	db := &RangeDatabase{}
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 3 {
+			return nil, fmt.Errorf("line %d: expected start IP, end IP and country", line)
+		}
+		start, end := net.ParseIP(strings.TrimSpace(record[0])), net.ParseIP(strings.TrimSpace(record[1]))
+		if start == nil || end == nil || bytes.Compare(start.To16(), end.To16()) > 0 {
+			return nil, fmt.Errorf("line %d: invalid IP range", line)
+		}
+		country := normalizeCountry(record[2])
+		if country == "" {
+			continue
+		}
+		db.ranges = append(db.ranges, ipRange{start: start.To16(), end: end.To16(), country: country})
+	}
+
+	sort.Slice(db.ranges, func(i, j int) bool {
+		return bytes.Compare(db.ranges[i].start, db.ranges[j].start) < 0
+	})
+	return db, nil
+}
+
+// CountryForIP returns the country of the range containing ip, or an empty string
+func (db *RangeDatabase) CountryForIP(ip net.IP) string {
+	ip = ip.To16()
+	if ip == nil {
+		return ""
+	}
+	// Find the last range starting at or before ip
+	i := sort.Search(len(db.ranges), func(i int) bool {
+		return bytes.Compare(db.ranges[i].start, ip) > 0
+	}) - 1
+	if i < 0 || bytes.Compare(ip, db.ranges[i].end) > 0 {
+		return ""
+	}
+	return db.ranges[i].country
+}
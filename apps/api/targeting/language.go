@@ -0,0 +1,54 @@
+package targeting
+
+import (
+	"strconv"
+	"strings"
+)
+
+// PreferredLanguage returns the lowercase language tag with the highest quality in an
+// Accept-Language header, or an empty string
+func PreferredLanguage(header string) string {
+	best := ""
+	bestQuality := 0.0
+
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					quality = q
+				}
+			}
+		}
+
+		// Earlier entries win ties
+		if quality > bestQuality {
+			best = tag
+			bestQuality = quality
+		}
+	}
+	return best
+}
+
+// matchLanguage matches a language tag against rule values. A value without a region,
+// like "en", also matches regional tags such as "en-gb".
+func matchLanguage(values []string, language string) bool {
+	if language == "" {
+		return false
+	}
+	primary, _, _ := strings.Cut(language, "-")
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == language || value == primary {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,29 @@
+package targeting
+
+import "testing"
+
+func TestPreferredLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"en-US", "en-us"},
+		{"fr-CH, fr;q=0.9, en;q=0.8, de;q=0.7, *;q=0.5", "fr-ch"},
+		{"de;q=0.7, en;q=0.8", "en"},
+		{"en;q=0.8, de;q=0.8", "en"},
+		{"*, nl;q=0.5", "nl"},
+		{"es; q=0.9 , pt", "pt"},
+		{"ja;q=abc", "ja"},
+		{"en;q=0", ""},
+		{" , ;q=1", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := PreferredLanguage(tt.header); got != tt.want {
+				t.Errorf("PreferredLanguage(%q) = %q, want %q", tt.header, got, tt.want)
+			}
+		})
+	}
+}
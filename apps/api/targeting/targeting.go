@@ -0,0 +1,196 @@
+package targeting
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"url-shortener-api/models"
+)
+
+// Visitor holds the request attributes that rules are matched against
+type Visitor struct {
+	Country  string
+	OS       string
+	Device   string
+	Language string
+	Time     time.Time
+}
+
+// NewVisitor describes the visitor making r from clientIP
+func NewVisitor(r *http.Request, clientIP string, now time.Time) Visitor {
+	os, device := ParseUserAgent(r.UserAgent())
+	return Visitor{
+		Country:  Geo.Country(r, clientIP),
+		OS:       os,
+		Device:   device,
+		Language: PreferredLanguage(r.Header.Get("Accept-Language")),
+		Time:     now,
+	}
+}
+
+// Select returns the first rule matching the visitor, or nil if the link's original URL should be used
+func Select(rules []models.LinkRule, v Visitor) *models.LinkRule {
+	for i := range rules {
+		if Match(&rules[i], v) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// Match reports whether a single rule applies to the visitor
+func Match(rule *models.LinkRule, v Visitor) bool {
+	switch rule.Kind {
+	case models.RuleCountry:
+		return v.Country != "" && containsFold(rule.Values, v.Country)
+	case models.RuleDevice:
+		return containsFold(rule.Values, v.OS) || containsFold(rule.Values, v.Device)
+	case models.RuleLanguage:
+		return matchLanguage(rule.Values, v.Language)
+	case models.RuleTime:
+		return matchTime(rule, v.Time)
+	}
+	return false
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// locations caches loaded timezones by name. Validate loads a rule's timezone when it is
+// saved, so redirects don't read the zone database on every match.
+var locations sync.Map
+
+// location returns the named timezone, loading it once
+func location(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// matchTime checks the weekday and time-of-day window of a time rule. Windows whose end is
+// before their start wrap past midnight.
+func matchTime(rule *models.LinkRule, now time.Time) bool {
+	loc := time.UTC
+	if rule.Timezone != nil && *rule.Timezone != "" {
+		l, err := location(*rule.Timezone)
+		if err != nil {
+			return false
+		}
+		loc = l
+	}
+	now = now.In(loc)
+
+	if len(rule.Values) > 0 {
+		matched := false
+		for _, day := range rule.Values {
+			if weekday, ok := weekdays[strings.ToLower(day)]; ok && weekday == now.Weekday() {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	start, end := 0, 24*60
+	if rule.StartTime != nil {
+		start, _ = parseClock(*rule.StartTime)
+	}
+	if rule.EndTime != nil {
+		end, _ = parseClock(*rule.EndTime)
+	}
+
+	minute := now.Hour()*60 + now.Minute()
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// parseClock parses an HH:MM time into minutes after midnight
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Validate checks that a rule's values make sense for its kind
+func Validate(rule models.LinkRuleInput) error {
+	switch rule.Kind {
+	case models.RuleCountry:
+		if len(rule.Values) == 0 {
+			return errors.New("country rules need at least one country code")
+		}
+		for _, code := range rule.Values {
+			if len(code) != 2 {
+				return fmt.Errorf("invalid country code %q", code)
+			}
+		}
+	case models.RuleDevice:
+		if len(rule.Values) == 0 {
+			return errors.New("device rules need at least one device")
+		}
+		for _, device := range rule.Values {
+			if !knownDevices[strings.ToLower(device)] {
+				return fmt.Errorf("unknown device %q", device)
+			}
+		}
+	case models.RuleLanguage:
+		if len(rule.Values) == 0 {
+			return errors.New("language rules need at least one language")
+		}
+	case models.RuleTime:
+		if rule.StartTime == nil && rule.EndTime == nil && len(rule.Values) == 0 {
+			return errors.New("time rules need a time window or weekdays")
+		}
+		for _, clock := range []*string{rule.StartTime, rule.EndTime} {
+			if clock == nil {
+				continue
+			}
+			if _, err := parseClock(*clock); err != nil {
+				return err
+			}
+		}
+		if rule.Timezone != nil && *rule.Timezone != "" {
+			if _, err := location(*rule.Timezone); err != nil {
+				return fmt.Errorf("unknown timezone %q", *rule.Timezone)
+			}
+		}
+		for _, day := range rule.Values {
+			if _, ok := weekdays[strings.ToLower(day)]; !ok {
+				return fmt.Errorf("invalid weekday %q, expected mon..sun", day)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown rule kind %q", rule.Kind)
+	}
+	return nil
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package targeting
+
+import (
+	"testing"
+	"time"
+	"url-shortener-api/models"
+)
+
+func strPtr(s string) *string { return &s }
+
+func TestMatch(t *testing.T) {
+	visitor := Visitor{
+		Country:  "US",
+		OS:       OSAndroid,
+		Device:   DeviceMobile,
+		Language: "en-gb",
+		Time:     time.Date(2026, 3, 2, 14, 30, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name    string
+		rule    models.LinkRule
+		visitor Visitor
+		want    bool
+	}{
+		{"country case-insensitive", models.LinkRule{Kind: models.RuleCountry, Values: []string{"de", "us"}}, visitor, true},
+		{"other country", models.LinkRule{Kind: models.RuleCountry, Values: []string{"DE"}}, visitor, false},
+		{"unknown country", models.LinkRule{Kind: models.RuleCountry, Values: []string{"US"}}, Visitor{}, false},
+		{"device by os", models.LinkRule{Kind: models.RuleDevice, Values: []string{"Android"}}, visitor, true},
+		{"device by type", models.LinkRule{Kind: models.RuleDevice, Values: []string{DeviceMobile}}, visitor, true},
+		{"other device", models.LinkRule{Kind: models.RuleDevice, Values: []string{OSiOS, DeviceDesktop}}, visitor, false},
+		{"language by primary subtag", models.LinkRule{Kind: models.RuleLanguage, Values: []string{"EN"}}, visitor, true},
+		{"language by full tag", models.LinkRule{Kind: models.RuleLanguage, Values: []string{"en-GB"}}, visitor, true},
+		{"other region", models.LinkRule{Kind: models.RuleLanguage, Values: []string{"en-us"}}, visitor, false},
+		{"no language", models.LinkRule{Kind: models.RuleLanguage, Values: []string{"en"}}, Visitor{}, false},
+		{"time", models.LinkRule{Kind: models.RuleTime, StartTime: strPtr("09:00"), EndTime: strPtr("17:00")}, visitor, true},
+		{"unknown kind", models.LinkRule{Kind: "referrer", Values: []string{"US"}}, visitor, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Match(&tt.rule, tt.visitor); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	rules := []models.LinkRule{
+		{Kind: models.RuleCountry, Values: []string{"DE"}, Destination: "https://example.de"},
+		{Kind: models.RuleDevice, Values: []string{OSiOS}, Destination: "https://example.com/ios"},
+		{Kind: models.RuleDevice, Values: []string{DeviceMobile}, Destination: "https://example.com/mobile"},
+	}
+
+	if rule := Select(rules, Visitor{Country: "DE", OS: OSiOS}); rule == nil || rule.Destination != "https://example.de" {
+		t.Errorf("Select() = %v, want the first matching rule", rule)
+	}
+	if rule := Select(rules, Visitor{OS: OSiOS, Device: DeviceMobile}); rule == nil || rule.Destination != "https://example.com/ios" {
+		t.Errorf("Select() = %v, want the first matching rule", rule)
+	}
+	if rule := Select(rules, Visitor{OS: OSWindows, Device: DeviceDesktop}); rule != nil {
+		t.Errorf("Select() = %v, want nil", rule)
+	}
+}
+
+func TestMatchTime(t *testing.T) {
+	// 2 March 2026 is a Monday; New York is on UTC-5 until 8 March
+	monday := func(hour, minute int) time.Time { return time.Date(2026, 3, 2, hour, minute, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name     string
+		days     []string
+		start    *string
+		end      *string
+		timezone *string
+		now      time.Time
+		want     bool
+	}{
+		{"no window", nil, nil, nil, nil, monday(3, 0), true},
+		{"inside window", nil, strPtr("09:00"), strPtr("17:00"), nil, monday(14, 30), true},
+		{"at start", nil, strPtr("09:00"), strPtr("17:00"), nil, monday(9, 0), true},
+		{"before start", nil, strPtr("09:00"), strPtr("17:00"), nil, monday(8, 59), false},
+		{"end is exclusive", nil, strPtr("09:00"), strPtr("17:00"), nil, monday(17, 0), false},
+		{"only start", nil, strPtr("12:00"), nil, nil, monday(23, 59), true},
+		{"only end", nil, nil, strPtr("12:00"), nil, monday(12, 0), false},
+		{"wraps past midnight, late", nil, strPtr("22:00"), strPtr("06:00"), nil, monday(23, 30), true},
+		{"wraps past midnight, early", nil, strPtr("22:00"), strPtr("06:00"), nil, monday(5, 59), true},
+		{"wraps past midnight, at end", nil, strPtr("22:00"), strPtr("06:00"), nil, monday(6, 0), false},
+		{"wraps past midnight, midday", nil, strPtr("22:00"), strPtr("06:00"), nil, monday(12, 0), false},
+		{"weekday", []string{"Mon"}, nil, nil, nil, monday(12, 0), true},
+		{"other weekdays", []string{"sat", "sun"}, nil, nil, nil, monday(12, 0), false},
+		{"window in timezone", nil, strPtr("09:00"), strPtr("10:00"), strPtr("America/New_York"), monday(14, 30), true},
+		{"window in UTC", nil, strPtr("09:00"), strPtr("10:00"), nil, monday(14, 30), false},
+		{"weekday in timezone", []string{"mon"}, nil, nil, strPtr("America/New_York"), monday(27, 0), true},
+		{"weekday in UTC", []string{"mon"}, nil, nil, nil, monday(27, 0), false},
+		{"empty timezone is UTC", nil, strPtr("14:00"), strPtr("15:00"), strPtr(""), monday(14, 30), true},
+		{"unknown timezone", nil, nil, nil, strPtr("Mars/Olympus_Mons"), monday(12, 0), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := &models.LinkRule{Kind: models.RuleTime, Values: tt.days, StartTime: tt.start, EndTime: tt.end, Timezone: tt.timezone}
+			if got := matchTime(rule, tt.now); got != tt.want {
+				t.Errorf("matchTime() at %v = %v, want %v", tt.now, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateCachesTimezone(t *testing.T) {
+	rule := models.LinkRuleInput{Kind: models.RuleTime, Values: []string{"mon"}, Timezone: strPtr("Europe/Berlin"), Destination: "https://example.com"}
+	if err := Validate(rule); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if _, ok := locations.Load("Europe/Berlin"); !ok {
+		t.Error("timezone not cached when the rule was validated")
+	}
+
+	rule.Timezone = strPtr("Mars/Olympus_Mons")
+	if err := Validate(rule); err == nil {
+		t.Error("Validate() accepted an unknown timezone")
+	}
+}
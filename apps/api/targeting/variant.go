@@ -0,0 +1,30 @@
+package targeting
+
+import (
+	"hash/fnv"
+	"url-shortener-api/models"
+)
+
+// PickVariant chooses a variant in proportion to the weights. The choice is derived from
+// key, so the same visitor key always lands on the same variant while the variants are unchanged.
+func PickVariant(variants []models.LinkVariant, key string) *models.LinkVariant {
+	total := 0
+	for _, variant := range variants {
+		total += variant.Weight
+	}
+	if total <= 0 {
+		return nil
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	bucket := int(h.Sum32() % uint32(total))
+
+	for i := range variants {
+		bucket -= variants[i].Weight
+		if bucket < 0 {
+			return &variants[i]
+		}
+	}
+	return nil
+}
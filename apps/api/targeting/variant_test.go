@@ -0,0 +1,68 @@
+package targeting
+
+import (
+	"fmt"
+	"testing"
+	"url-shortener-api/models"
+)
+
+func weighted(weights ...int) []models.LinkVariant {
+	variants := make([]models.LinkVariant, len(weights))
+	for i, weight := range weights {
+		variants[i] = models.LinkVariant{Position: i, Destination: fmt.Sprintf("https://example.com/%c", 'a'+i), Weight: weight}
+	}
+	return variants
+}
+
+func TestPickVariant(t *testing.T) {
+	// Expected positions follow from the FNV-1a hash of the key modulo the total weight:
+	// "a" hashes to bucket 20 of 100 and 0 of 4, "b" to 77 and 1, "visitor-4" to 0 and 0,
+	// "visitor-2" to 14 and 2, "visitor-3" to 33 and 1, "visitor-6" to 38 and 2
+	tests := []struct {
+		name     string
+		variants []models.LinkVariant
+		key      string
+		want     int
+	}{
+		{"first bucket", weighted(1, 3), "a", 0},
+		{"second bucket", weighted(1, 3), "b", 1},
+		{"zero bucket", weighted(1, 3), "visitor-4", 0},
+		{"bucket at weight boundary", weighted(20, 30, 50), "a", 1},
+		{"lowest bucket", weighted(20, 30, 50), "visitor-4", 0},
+		{"inside first weight", weighted(20, 30, 50), "visitor-2", 0},
+		{"inside second weight", weighted(20, 30, 50), "visitor-3", 1},
+		{"end of second weight", weighted(20, 30, 50), "visitor-6", 1},
+		{"last weight", weighted(20, 30, 50), "b", 2},
+		{"zero weight skipped", weighted(0, 1), "a", 1},
+		{"single variant", weighted(5), "b", 0},
+		{"no weight", weighted(0, 0), "a", -1},
+		{"no variants", nil, "a", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PickVariant(tt.variants, tt.key)
+			switch {
+			case tt.want < 0 && got != nil:
+				t.Errorf("PickVariant(%q) = %d, want nil", tt.key, got.Position)
+			case tt.want >= 0 && got == nil:
+				t.Errorf("PickVariant(%q) = nil, want %d", tt.key, tt.want)
+			case got != nil && got.Position != tt.want:
+				t.Errorf("PickVariant(%q) = %d, want %d", tt.key, got.Position, tt.want)
+			}
+		})
+	}
+}
+
+func TestPickVariantDistribution(t *testing.T) {
+	variants := weighted(1, 3)
+	counts := make([]int, len(variants))
+	for i := 0; i < 10000; i++ {
+		counts[PickVariant(variants, fmt.Sprintf("link|10.0.%d.%d", i/256, i%256)).Position]++
+	}
+
+	// A quarter of the visitors should land on the first variant
+	if counts[0] < 2300 || counts[0] > 2700 {
+		t.Errorf("first variant picked %d times out of 10000, want about 2500", counts[0])
+	}
+}
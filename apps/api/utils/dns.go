@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"context"
+	"strings"
+)
+
+// TXTResolver looks up DNS TXT records. net.DefaultResolver satisfies it; tests can swap in a stub.
+type TXTResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// DomainVerificationPrefix is the label the ownership TXT record is published under
+const DomainVerificationPrefix = "_trimr-verify"
+
+// DomainVerificationName returns the DNS name the verification TXT record must be published at
+func DomainVerificationName(hostname string) string {
+	return DomainVerificationPrefix + "." + hostname
+}
+
+// DomainVerificationValue returns the TXT record value proving ownership
+func DomainVerificationValue(token string) string {
+	return "trimr-verify=" + token
+}
+
+// VerifyDomainTXT reports whether hostname publishes the expected verification TXT record
+func VerifyDomainTXT(ctx context.Context, resolver TXTResolver, hostname string, token string) (bool, error) {
+	records, err := resolver.LookupTXT(ctx, DomainVerificationName(hostname))
+	if err != nil {
+		return false, err
+	}
+
+	expected := DomainVerificationValue(token)
+	for _, record := range records {
+		if strings.TrimSpace(record) == expected {
+			return true, nil
+		}
+	}
+	return false, nil
+}
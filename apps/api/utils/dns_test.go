@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// stubResolver answers TXT lookups from a fixed map
+type stubResolver map[string][]string
+
+func (r stubResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	records, ok := r[name]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return records, nil
+}
+
+func TestVerifyDomainTXT(t *testing.T) {
+	const token = "abc123"
+	name := DomainVerificationName("links.example.com")
+
+	tests := []struct {
+		name     string
+		resolver stubResolver
+		want     bool
+		wantErr  bool
+	}{
+		{"matching record", stubResolver{name: {DomainVerificationValue(token)}}, true, false},
+		{"matching record among others", stubResolver{name: {"v=spf1 -all", " " + DomainVerificationValue(token) + " "}}, true, false},
+		{"wrong token", stubResolver{name: {DomainVerificationValue("other")}}, false, false},
+		{"record on the bare hostname", stubResolver{"links.example.com": {DomainVerificationValue(token)}}, false, true},
+		{"no records", stubResolver{name: {}}, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyDomainTXT(context.Background(), tt.resolver, "links.example.com", token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyDomainTXT() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("VerifyDomainTXT() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDomainVerificationName(t *testing.T) {
+	if got := DomainVerificationName("example.com"); got != "_trimr-verify.example.com" {
+		t.Errorf("DomainVerificationName() = %q", got)
+	}
+}
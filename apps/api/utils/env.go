@@ -16,6 +16,69 @@ type Config struct {
 	GinMode             string
 	Port                string
 	BaseURL             string
+	ShortBaseURL        string
+	MaxFailedAttempts   int
+	LockoutMinutes      int
+	AttemptWindow       int
+
+	// Anonymous link creation policy
+	AnonymousLinks          string
+	AnonymousMaxExpiryHours int
+	AnonymousAllowedDomains string
+	AnonymousAllowPasswords bool
+	AnonymousVerifier       string
+	VerifierStubToken       string
+	ProofOfWorkDifficulty   int
+
+	// Hours between URL screening rechecks of existing links (0 disables)
+	ScreeningRecheckHours int
+
+	// Interstitial warning pages shown before redirecting
+	InterstitialFlagged        bool
+	InterstitialExternal       bool
+	InterstitialTrustedDomains string
+
+	// Built-in HTTPS listener with ACME certificates for the short and custom domains
+	TLSEnabled       bool
+	TLSPort          string
+	ACMEDirectoryURL string
+	ACMEEmail        string
+	ACMECACert       string
+
+	// Country lookup: GeoIPDatabase is a CSV of IP ranges and countries. GeoCountryHeaders
+	// are comma-separated headers carrying the client's country, only trusted when
+	// GeoTrustedProxy says a proxy in front of the API sets them.
+	GeoIPDatabase     string
+	GeoCountryHeaders string
+	GeoTrustedProxy   bool
+
+	// Destination health checks: hours between runs (0 disables), request concurrency overall
+	// and per host, delay between requests to one host, and what happens after
+	// HealthFailureThreshold consecutive failures
+	HealthCheckHours             int
+	HealthCheckConcurrency       int
+	HealthCheckDomainConcurrency int
+	HealthCheckDomainDelayMs     int
+	HealthFailureThreshold       int
+	HealthNotifyOwner            bool
+	HealthAutoDisable            bool
+
+	// Lets webhooks target private and loopback addresses, for testing against a local receiver
+	WebhookAllowPrivateURLs bool
+
+	// Outgoing email: Mailer is "log" (write messages to the log), "smtp" or "none"
+	Mailer       string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	MailFrom     string
+
+	// Link to the page that accepts workspace invitations, with {token} standing for the token
+	InvitationURL string
+
+	// Minutes between checks for performance reports that are due (0 disables sending)
+	ReportCheckMinutes int
 }
 
 var AppConfig Config
@@ -34,6 +97,55 @@ func LoadEnv() {
 		GinMode:             getEnv("GIN_MODE", "release"),
 		Port:                getEnv("PORT", "8080"),
 		BaseURL:             getEnv("BASE_URL", ""),
+		ShortBaseURL:        getEnv("SHORT_BASE_URL", ""),
+		MaxFailedAttempts:   getEnvAsInt("MAX_FAILED_ATTEMPTS", 5),
+		LockoutMinutes:      getEnvAsInt("LOCKOUT_MINUTES", 15),
+		AttemptWindow:       getEnvAsInt("FAILED_ATTEMPT_WINDOW_MINUTES", 15),
+
+		AnonymousLinks:          getEnv("ANONYMOUS_LINKS", "allow"),
+		AnonymousMaxExpiryHours: getEnvAsInt("ANONYMOUS_MAX_EXPIRY_HOURS", 24*7),
+		AnonymousAllowedDomains: getEnv("ANONYMOUS_ALLOWED_DOMAINS", ""),
+		AnonymousAllowPasswords: getEnvAsBool("ANONYMOUS_ALLOW_PASSWORDS", false),
+		AnonymousVerifier:       getEnv("ANONYMOUS_VERIFIER", "none"),
+		VerifierStubToken:       getEnv("VERIFIER_STUB_TOKEN", "test-token"),
+		ProofOfWorkDifficulty:   getEnvAsInt("POW_DIFFICULTY", 20),
+
+		ScreeningRecheckHours: getEnvAsInt("SCREENING_RECHECK_HOURS", 24),
+
+		InterstitialFlagged:        getEnvAsBool("INTERSTITIAL_FLAGGED", true),
+		InterstitialExternal:       getEnvAsBool("INTERSTITIAL_EXTERNAL", false),
+		InterstitialTrustedDomains: getEnv("INTERSTITIAL_TRUSTED_DOMAINS", ""),
+
+		TLSEnabled:       getEnvAsBool("TLS_ENABLED", false),
+		TLSPort:          getEnv("TLS_PORT", "443"),
+		ACMEDirectoryURL: getEnv("ACME_DIRECTORY_URL", "https://acme-v02.api.letsencrypt.org/directory"),
+		ACMEEmail:        getEnv("ACME_EMAIL", ""),
+		ACMECACert:       getEnv("ACME_CA_CERT", ""),
+
+		GeoIPDatabase:     getEnv("GEOIP_DATABASE", ""),
+		GeoCountryHeaders: getEnv("GEO_COUNTRY_HEADERS", "CF-IPCountry,X-Country-Code,X-AppEngine-Country"),
+		GeoTrustedProxy:   getEnvAsBool("GEO_TRUSTED_PROXY", false),
+
+		HealthCheckHours:             getEnvAsInt("HEALTH_CHECK_HOURS", 24),
+		HealthCheckConcurrency:       getEnvAsInt("HEALTH_CHECK_CONCURRENCY", 8),
+		HealthCheckDomainConcurrency: getEnvAsInt("HEALTH_CHECK_DOMAIN_CONCURRENCY", 1),
+		HealthCheckDomainDelayMs:     getEnvAsInt("HEALTH_CHECK_DOMAIN_DELAY_MS", 2000),
+		HealthFailureThreshold:       getEnvAsInt("HEALTH_FAILURE_THRESHOLD", 3),
+		HealthNotifyOwner:            getEnvAsBool("HEALTH_NOTIFY_OWNER", false),
+		HealthAutoDisable:            getEnvAsBool("HEALTH_AUTO_DISABLE", false),
+
+		WebhookAllowPrivateURLs: getEnvAsBool("WEBHOOK_ALLOW_PRIVATE_URLS", false),
+
+		Mailer:       getEnv("MAILER", "log"),
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnvAsInt("SMTP_PORT", 587),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		MailFrom:     getEnv("MAIL_FROM", ""),
+
+		InvitationURL: getEnv("INVITATION_URL", ""),
+
+		ReportCheckMinutes: getEnvAsInt("REPORT_CHECK_MINUTES", 60),
 	}
 
 	if AppConfig.DBURL == "" {
@@ -55,3 +167,11 @@ func getEnvAsInt(name string, defaultVal int) int {
 	}
 	return defaultVal
 }
+
+func getEnvAsBool(name string, defaultVal bool) bool {
+	valStr := getEnv(name, "")
+	if val, err := strconv.ParseBool(valStr); err == nil {
+		return val
+	}
+	return defaultVal
+}
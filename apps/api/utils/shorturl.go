@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ShortBaseURL returns the base URL short links are served from. SHORT_BASE_URL takes
+// precedence, then BASE_URL, then the local server address.
+func ShortBaseURL() string {
+	base := AppConfig.ShortBaseURL
+	if base == "" {
+		base = AppConfig.BaseURL
+	}
+	if base == "" {
+		base = fmt.Sprintf("http://localhost:%s", AppConfig.Port)
+	}
+	return strings.TrimSuffix(base, "/")
+}
+
+// ShortURL builds the public short URL for a slug
+func ShortURL(slug string) string {
+	return ShortBaseURL() + "/" + slug
+}
+
+// ShortDomain returns the host of SHORT_BASE_URL, or an empty string when redirects
+// are served from the API host
+func ShortDomain() string {
+	if AppConfig.ShortBaseURL == "" {
+		return ""
+	}
+	parsed, err := url.Parse(AppConfig.ShortBaseURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
@@ -0,0 +1,94 @@
+package views
+
+import (
+	"embed"
+	"html/template"
+)
+
+//go:embed templates/*.html
+var files embed.FS
+
+// Templates parses the embedded HTML templates used for browser-facing pages.
+// Templates are referenced by file name, e.g. c.HTML(200, "preview.html", data).
+func Templates() *template.Template {
+	return template.Must(template.New("").ParseFS(files, "templates/*.html"))
+}
+
+// PreviewPage is the data for preview.html
+type PreviewPage struct {
+	PageTitle         string
+	Title             string
+	Destination       string
+	FaviconURL        string
+	ContinueURL       string
+	Warning           string
+	Status            string
+	PasswordProtected bool
+	OneTime           bool
+	ClickLimited      bool
+}
+
+// ErrorPage is the data for error.html
+type ErrorPage struct {
+	PageTitle string
+	Title     string
+	Message   string
+}
+
+// PasswordPage is the data for password.html
+type PasswordPage struct {
+	PageTitle string
+	Title     string
+	Action    string
+	Error     string
+}
+
+// ScheduledPage is the data for scheduled.html
+type ScheduledPage struct {
+	PageTitle         string
+	Title             string
+	ActiveFrom        string
+	ActiveFromDisplay string
+}
+
+// DeepLinkPage is the data for deeplink.html. AppURL may use a custom scheme, so it is
+// passed as a trusted template.URL after validation.
+type DeepLinkPage struct {
+	PageTitle   string
+	Title       string
+	AppURL      template.URL
+	FallbackURL string
+}
+
+// SocialCardPage is the data for social.html, the Open Graph card served to link preview crawlers
+type SocialCardPage struct {
+	Title       string
+	Description string
+	ImageURL    string
+	SiteName    string
+	URL         string
+}
+
+// ReportEmail is the data for report.html, the scheduled performance report email.
+// Values are formatted beforehand since email templates have no helper functions.
+type ReportEmail struct {
+	Title          string
+	Period         string
+	TotalClicks    int
+	PreviousClicks int
+	// Change is the click trend against the previous period, e.g. "+12%", empty when there is nothing to compare
+	Change         string
+	ChangeUp       bool
+	UniqueVisitors int
+	TopLinks       []ReportRow
+	NewCountries   []ReportRow
+	BrokenLinks    []ReportRow
+}
+
+// ReportRow is one line of a report table
+type ReportRow struct {
+	Label  string
+	Detail string
+	URL    string
+	Value  string
+}
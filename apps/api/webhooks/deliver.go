@@ -0,0 +1,235 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"url-shortener-api/db"
+	"url-shortener-api/models"
+	"url-shortener-api/screening"
+	"url-shortener-api/utils"
+)
+
+// Delivery tuning. Failed deliveries are retried after 30s, 1m, 2m, ... up to maxAttempts.
+const (
+	maxAttempts     = 10
+	baseBackoff     = 30 * time.Second
+	maxBackoff      = 6 * time.Hour
+	requestTimeout  = 10 * time.Second
+	claimTimeout    = 5 * time.Minute
+	pollInterval    = 5 * time.Second
+	maxResponseBody = 2048
+	workers         = 4
+)
+
+// Request headers sent with every delivery
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+var client *http.Client
+
+// Sign returns the signature header value for a delivery: "sha256=" followed by the hex
+// HMAC-SHA256 of the timestamp, a dot and the body, keyed with the webhook secret.
+// Receivers should recompute it and reject old timestamps to prevent replays.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// StartWorkers delivers queued events until ctx is cancelled. Webhooks may only target public
+// addresses unless WEBHOOK_ALLOW_PRIVATE_URLS is set, e.g. to test against a local receiver.
+func StartWorkers(ctx context.Context) {
+	if utils.AppConfig.WebhookAllowPrivateURLs {
+		client = &http.Client{Timeout: requestTimeout}
+	} else {
+		client = screening.PublicClient(requestTimeout)
+	}
+	// Redirects are reported as failures rather than followed
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			ticker := time.NewTicker(pollInterval)
+			defer ticker.Stop()
+			for {
+				for processNext(ctx) {
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				case <-wake:
+				}
+			}
+		}()
+	}
+}
+
+// processNext sends the next due delivery. It reports whether one was found.
+func processNext(ctx context.Context) bool {
+	// Claiming pushes next_attempt_at forward, so a worker that dies mid-request lets it be retried
+	var delivery models.WebhookDelivery
+	err := db.DB.GetContext(ctx, &delivery, `
+		UPDATE webhook_deliveries
+		SET attempts = attempts + 1, last_attempt_at = NOW(), next_attempt_at = $1
+		WHERE id = (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING *
+	`, time.Now().Add(claimTimeout))
+	if err == sql.ErrNoRows {
+		return false
+	} else if err != nil {
+		log.Printf("Error claiming webhook delivery: %v", err)
+		return false
+	}
+
+	var webhook models.Webhook
+	err = db.DB.GetContext(ctx, &webhook, "SELECT * FROM webhooks WHERE id = $1", delivery.WebhookID)
+	if err != nil {
+		log.Printf("Error loading webhook %s: %v", delivery.WebhookID, err)
+		return true
+	}
+
+	if !webhook.Active {
+		finish(ctx, &delivery, models.DeliveryFailed, nil, nil, "webhook is inactive")
+		return true
+	}
+
+	status, body, err := send(ctx, &webhook, &delivery)
+	switch outcome(status, err, delivery.Attempts) {
+	case models.DeliveryDelivered:
+		finish(ctx, &delivery, models.DeliveryDelivered, &status, body, "")
+	case models.DeliveryFailed:
+		finish(ctx, &delivery, models.DeliveryFailed, statusPtr(status), body, errorText(status, err))
+	default:
+		retry(ctx, &delivery, statusPtr(status), body, errorText(status, err))
+	}
+	return true
+}
+
+// outcome decides what happens to a delivery after an attempt: delivered on a 2xx response,
+// failed once maxAttempts is reached, and otherwise pending for another try
+func outcome(status int, err error, attempts int) string {
+	switch {
+	case err == nil && status >= 200 && status < 300:
+		return models.DeliveryDelivered
+	case attempts >= maxAttempts:
+		return models.DeliveryFailed
+	default:
+		return models.DeliveryPending
+	}
+}
+
+// backoff returns the wait before retrying a delivery that has failed attempts times
+func backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		return baseBackoff
+	}
+	wait := baseBackoff << (attempts - 1)
+	if wait > maxBackoff || wait <= 0 {
+		return maxBackoff
+	}
+	return wait
+}
+
+// send posts a delivery to its webhook and returns the response status and the start of its body
+func send(ctx context.Context, webhook *models.Webhook, delivery *models.WebhookDelivery) (int, *string, error) {
+	payload := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, strings.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "URL-Shortener-Webhooks/1.0")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, delivery.ID.String())
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(webhook.Secret, timestamp, payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	body := string(data)
+	return resp.StatusCode, &body, nil
+}
+
+// finish records the final outcome of a delivery
+func finish(ctx context.Context, delivery *models.WebhookDelivery, status string, responseStatus *int, body *string, errText string) {
+	var deliveredAt *time.Time
+	if status == models.DeliveryDelivered {
+		now := time.Now()
+		deliveredAt = &now
+	}
+
+	_, err := db.DB.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $1, response_status = $2, response_body = $3, error = $4, delivered_at = $5
+		WHERE id = $6
+	`, status, responseStatus, body, nullIfEmpty(errText), deliveredAt, delivery.ID)
+	if err != nil {
+		log.Printf("Error recording webhook delivery %s: %v", delivery.ID, err)
+	}
+}
+
+// retry schedules another attempt with exponential backoff
+func retry(ctx context.Context, delivery *models.WebhookDelivery, responseStatus *int, body *string, errText string) {
+	_, err := db.DB.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET next_attempt_at = $1, response_status = $2, response_body = $3, error = $4
+		WHERE id = $5
+	`, time.Now().Add(backoff(delivery.Attempts)), responseStatus, body, errText, delivery.ID)
+	if err != nil {
+		log.Printf("Error rescheduling webhook delivery %s: %v", delivery.ID, err)
+	}
+}
+
+func errorText(status int, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("receiver responded with status %d", status)
+}
+
+func statusPtr(status int) *int {
+	if status == 0 {
+		return nil
+	}
+	return &status
+}
+
+func nullIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
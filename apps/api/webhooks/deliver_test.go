@@ -0,0 +1,152 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+	"url-shortener-api/models"
+
+	"github.com/google/uuid"
+)
+
+func TestSendSignsDelivery(t *testing.T) {
+	const secret = "whsec_test"
+	webhook := &models.Webhook{ID: uuid.New(), Secret: secret}
+	delivery := &models.WebhookDelivery{
+		ID:      uuid.New(),
+		Event:   "link.created",
+		Payload: `{"event":"link.created","data":{"slug":"abc"}}`,
+	}
+
+	var received *http.Request
+	var receivedBody []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		receivedBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+	webhook.URL = receiver.URL
+
+	previous := client
+	client = receiver.Client()
+	defer func() { client = previous }()
+
+	status, _, err := send(context.Background(), webhook, delivery)
+	if err != nil {
+		t.Fatalf("send() error = %v", err)
+	}
+	if status != http.StatusNoContent {
+		t.Errorf("status = %d, want %d", status, http.StatusNoContent)
+	}
+
+	if got := received.Header.Get(HeaderEvent); got != delivery.Event {
+		t.Errorf("%s = %q, want %q", HeaderEvent, got, delivery.Event)
+	}
+	if got := received.Header.Get(HeaderDelivery); got != delivery.ID.String() {
+		t.Errorf("%s = %q, want %q", HeaderDelivery, got, delivery.ID)
+	}
+	if string(receivedBody) != delivery.Payload {
+		t.Errorf("body = %q, want %q", receivedBody, delivery.Payload)
+	}
+
+	// The receiver recomputes the signature from the timestamp header and the raw body
+	timestamp := received.Header.Get(HeaderTimestamp)
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || time.Since(time.Unix(sent, 0)) > time.Minute {
+		t.Errorf("%s = %q, want the current Unix time", HeaderTimestamp, timestamp)
+	}
+	want := Sign(secret, timestamp, receivedBody)
+	if got := received.Header.Get(HeaderSignature); !hmac.Equal([]byte(got), []byte(want)) {
+		t.Errorf("%s = %q, want %q", HeaderSignature, got, want)
+	}
+	if Sign("other secret", timestamp, receivedBody) == want {
+		t.Error("signature does not depend on the secret")
+	}
+	if Sign(secret, timestamp, append(receivedBody, ' ')) == want {
+		t.Error("signature does not depend on the body")
+	}
+}
+
+func TestSendDoesNotFollowRedirects(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/elsewhere", http.StatusFound)
+	}))
+	defer receiver.Close()
+
+	previous := client
+	client = receiver.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	defer func() { client = previous }()
+
+	status, _, err := send(context.Background(), &models.Webhook{URL: receiver.URL}, &models.WebhookDelivery{ID: uuid.New()})
+	if err != nil {
+		t.Fatalf("send() error = %v", err)
+	}
+	if got := outcome(status, err, 1); got != models.DeliveryPending {
+		t.Errorf("outcome after redirect = %q, want %q", got, models.DeliveryPending)
+	}
+}
+
+func TestSign(t *testing.T) {
+	// Computed independently with: printf '1700000000.{}' | openssl dgst -sha256 -hmac secret
+	want := "sha256=b8569b78799ff9e3cbff0fc2d63a33a2b57f3282abd07c37ae5e8e7d79a5f163"
+	if got := Sign("secret", "1700000000", []byte("{}")); got != want {
+		t.Errorf("Sign() = %q, want %q", got, want)
+	}
+}
+
+func TestOutcome(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		err      error
+		attempts int
+		want     string
+	}{
+		{"ok", http.StatusOK, nil, 1, models.DeliveryDelivered},
+		{"no content on last attempt", http.StatusNoContent, nil, maxAttempts, models.DeliveryDelivered},
+		{"server error", http.StatusInternalServerError, nil, 1, models.DeliveryPending},
+		{"client error", http.StatusNotFound, nil, 3, models.DeliveryPending},
+		{"connection error", 0, errors.New("connection refused"), 1, models.DeliveryPending},
+		{"server error on last attempt", http.StatusBadGateway, nil, maxAttempts, models.DeliveryFailed},
+		{"connection error on last attempt", 0, errors.New("timeout"), maxAttempts, models.DeliveryFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := outcome(tt.status, tt.err, tt.attempts); got != tt.want {
+				t.Errorf("outcome(%d, %v, %d) = %q, want %q", tt.status, tt.err, tt.attempts, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{5, 8 * time.Minute},
+		{9, 128 * time.Minute},
+		{10, 256 * time.Minute},
+		{11, maxBackoff},
+		{64, maxBackoff},
+		{0, baseBackoff},
+	}
+
+	for _, tt := range tests {
+		if got := backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+	"url-shortener-api/db"
+	"url-shortener-api/models"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// Event is the JSON body posted to webhooks
+type Event struct {
+	ID        uuid.UUID `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"createdAt"`
+	Data      any       `json:"data"`
+}
+
+// wake nudges the delivery workers when something is queued
+var wake = make(chan struct{}, 1)
+
+// Notify wakes the delivery workers. Call it once the transaction that emitted events has
+// committed; workers that aren't woken pick the deliveries up within pollInterval anyway.
+func Notify() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// NewPayload builds the body of an event and the event ID receivers use to spot duplicates
+func NewPayload(event string, data any) (uuid.UUID, string, error) {
+	id := uuid.New()
+	body, err := json.Marshal(Event{ID: id, Type: event, CreatedAt: time.Now().UTC(), Data: data})
+	if err != nil {
+		return id, "", err
+	}
+	return id, string(body), nil
+}
+
+// Emit queues an event for every active webhook of the workspace subscribed to it.
+// Click events are sampled per webhook. Links outside a workspace have no webhooks.
+//
+// Pass the transaction that makes the change the event describes, so the deliveries are
+// queued if and only if the change commits, then call Notify after committing.
+func Emit(ctx context.Context, tx sqlx.ExecerContext, workspaceID *uuid.UUID, event string, data any) error {
+	if workspaceID == nil {
+		return nil
+	}
+
+	eventID, payload, err := NewPayload(event, data)
+	if err != nil {
+		return fmt.Errorf("encoding %s webhook event: %w", event, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload)
+		SELECT id, $2, $3, $4 FROM webhooks
+		WHERE workspace_id = $1 AND active AND $3 = ANY(events)
+			AND ($3 <> $5 OR random() < click_sample_rate)
+	`, *workspaceID, eventID, event, payload, models.EventClickRecorded)
+	if err != nil {
+		return fmt.Errorf("queueing %s webhook deliveries: %w", event, err)
+	}
+	return nil
+}
+
+// Ping queues a test event for one webhook, whatever it subscribes to
+func Ping(ctx context.Context, webhookID uuid.UUID, data any) (uuid.UUID, error) {
+	eventID, payload, err := NewPayload(models.EventPing, data)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	var deliveryID uuid.UUID
+	err = db.DB.GetContext(ctx, &deliveryID, `
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, webhookID, eventID, models.EventPing, payload)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	Notify()
+	return deliveryID, nil
+}
+
+// Replay queues an earlier delivery again with the same event ID and payload, so receivers
+// can recognise it as a duplicate
+func Replay(ctx context.Context, deliveryID uuid.UUID) (uuid.UUID, error) {
+	var replayID uuid.UUID
+	err := db.DB.GetContext(ctx, &replayID, `
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload, replay_of)
+		SELECT webhook_id, event_id, event, payload, id FROM webhook_deliveries WHERE id = $1
+		RETURNING id
+	`, deliveryID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	Notify()
+	return replayID, nil
+}
@@ -3,6 +3,7 @@
 import { useState } from "react";
 import { motion } from "framer-motion";
 import { Globe } from "lucide-react";
+import { API_CONFIG } from "@/lib/constants";
 
 interface FaviconProps {
   url: string;
@@ -25,19 +26,12 @@ export function Favicon({ url, alt, size = "md", className = "" }: FaviconProps)
     }
   };
 
-  // Get favicon URL using Google's favicon service as fallback
+  // Favicons are downloaded and served by our API, so destinations aren't leaked to third parties
   const getFaviconUrl = (url: string) => {
     const domain = getDomain(url);
     if (!domain) return "";
     
-    // Try multiple favicon sources
-    const faviconSources = [
-      `https://${domain}/favicon.ico`,
-      `https://www.google.com/s2/favicons?domain=${domain}&sz=32`,
-      `https://icons.duckduckgo.com/ip3/${domain}.ico`,
-    ];
-    
-    return faviconSources[imageError ? 1 : 0]; // Use Google's service as fallback
+    return `${API_CONFIG.BASE_URL}/favicons/${encodeURIComponent(domain)}`;
   };
 
   const sizeClasses = {
@@ -78,19 +72,6 @@ export function Favicon({ url, alt, size = "md", className = "" }: FaviconProps)
           transition={{ duration: 0.2 }}
         />
       ) : (
-        <motion.img
-          src={`https://www.google.com/s2/favicons?domain=${domain}&sz=32`}
-          alt={alt || `${domain} favicon`}
-          className={`${sizeClasses[size]} object-cover rounded-sm`}
-          onLoad={() => setIsLoading(false)}
-          onError={() => setIsLoading(false)}
-          initial={{ opacity: 0 }}
-          animate={{ opacity: isLoading ? 0 : 1 }}
-          transition={{ duration: 0.2 }}
-        />
-      )}
-      
-      {imageError && isLoading && (
         <div className="absolute inset-0 flex items-center justify-center bg-slate-100 dark:bg-slate-700 rounded-sm">
           <Globe className="w-3 h-3 text-slate-400 dark:text-slate-500" />
         </div>
@@ -67,6 +67,8 @@ export interface AccessLinkResponse {
   slug: string;
   passwordRequired: boolean;
   passwordValid?: boolean;
+  // Limited links only reveal their destination by following the short link
+  clickLimited?: boolean;
   originalUrl?: string;
 }
 